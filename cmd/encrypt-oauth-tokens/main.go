@@ -0,0 +1,32 @@
+// Command encrypt-oauth-tokens encrypts the provider tokens stored in
+// oauth_accounts with the OAUTH_TOKEN_ACTIVE_KID key. Run it once after
+// enabling encryption and again after every key rotation.
+package main
+
+import (
+	"context"
+
+	"restorapp/db"
+	"restorapp/modules/auth"
+
+	"github.com/charmbracelet/log"
+)
+
+func main() {
+	auth.LoadConfig()
+
+	tokenCipher, err := auth.ParseTokenKeys(auth.AppConfig.OAuthTokenKeys, auth.AppConfig.OAuthTokenActiveKeyID)
+	if err != nil {
+		log.Fatal("Failed to load OAuth token keys", "error", err)
+	}
+
+	conn := db.InitDBClient()
+	defer conn.Close()
+
+	updated, err := auth.ReencryptOAuthTokens(context.Background(), db.Queries, tokenCipher)
+	if err != nil {
+		log.Fatal("Failed to encrypt OAuth tokens", "updated", updated, "error", err)
+	}
+
+	log.Info("OAuth tokens encrypted", "updated", updated)
+}
@@ -20,7 +20,7 @@ RETURNING id, product_id, user_id, parent_id, content, created_at, updated_at
 
 type CreateCommentParams struct {
 	ProductID uuid.UUID   `json:"product_id"`
-	UserID    uuid.UUID   `json:"user_id"`
+	UserID    pgtype.UUID `json:"user_id"`
 	ParentID  pgtype.UUID `json:"parent_id"`
 	Content   string      `json:"content"`
 }
@@ -45,18 +45,21 @@ func (q *Queries) CreateComment(ctx context.Context, arg CreateCommentParams) (C
 	return i, err
 }
 
-const deleteComment = `-- name: DeleteComment :exec
+const deleteComment = `-- name: DeleteComment :one
 DELETE FROM comments WHERE id = $1 AND user_id = $2
+RETURNING product_id
 `
 
 type DeleteCommentParams struct {
-	ID     uuid.UUID `json:"id"`
-	UserID uuid.UUID `json:"user_id"`
+	ID     uuid.UUID   `json:"id"`
+	UserID pgtype.UUID `json:"user_id"`
 }
 
-func (q *Queries) DeleteComment(ctx context.Context, arg DeleteCommentParams) error {
-	_, err := q.db.Exec(ctx, deleteComment, arg.ID, arg.UserID)
-	return err
+func (q *Queries) DeleteComment(ctx context.Context, arg DeleteCommentParams) (uuid.UUID, error) {
+	row := q.db.QueryRow(ctx, deleteComment, arg.ID, arg.UserID)
+	var productID uuid.UUID
+	err := row.Scan(&productID)
+	return productID, err
 }
 
 const deleteCommentVote = `-- name: DeleteCommentVote :exec
@@ -73,6 +76,65 @@ func (q *Queries) DeleteCommentVote(ctx context.Context, arg DeleteCommentVotePa
 	return err
 }
 
+const getCommentVoteCounts = `-- name: GetCommentVoteCounts :one
+SELECT
+    c.id,
+    c.product_id,
+    (SELECT COUNT(*) FROM comment_votes cv WHERE cv.comment_id = c.id AND cv.vote_type = 'like')::bigint AS likes,
+    (SELECT COUNT(*) FROM comment_votes cv WHERE cv.comment_id = c.id AND cv.vote_type = 'dislike')::bigint AS dislikes
+FROM comments c
+WHERE c.id = $1
+`
+
+type GetCommentVoteCountsRow struct {
+	ID        uuid.UUID `json:"id"`
+	ProductID uuid.UUID `json:"product_id"`
+	Likes     int64     `json:"likes"`
+	Dislikes  int64     `json:"dislikes"`
+}
+
+func (q *Queries) GetCommentVoteCounts(ctx context.Context, id uuid.UUID) (GetCommentVoteCountsRow, error) {
+	row := q.db.QueryRow(ctx, getCommentVoteCounts, id)
+	var i GetCommentVoteCountsRow
+	err := row.Scan(
+		&i.ID,
+		&i.ProductID,
+		&i.Likes,
+		&i.Dislikes,
+	)
+	return i, err
+}
+
+const getCommentVotesByUserId = `-- name: GetCommentVotesByUserId :many
+SELECT id, comment_id, user_id, vote_type, created_at FROM comment_votes WHERE user_id = $1 ORDER BY created_at ASC
+`
+
+func (q *Queries) GetCommentVotesByUserId(ctx context.Context, userID uuid.UUID) ([]CommentVote, error) {
+	rows, err := q.db.Query(ctx, getCommentVotesByUserId, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []CommentVote
+	for rows.Next() {
+		var i CommentVote
+		if err := rows.Scan(
+			&i.ID,
+			&i.CommentID,
+			&i.UserID,
+			&i.VoteType,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getCommentsByProductId = `-- name: GetCommentsByProductId :many
 SELECT
     c.id,
@@ -82,12 +144,12 @@ SELECT
     c.content,
     c.created_at,
     c.updated_at,
-    u.name AS author_name,
+    COALESCE(u.name, 'Usuario eliminado')::text AS author_name,
     u.image AS author_image,
     (SELECT COUNT(*) FROM comment_votes cv WHERE cv.comment_id = c.id AND cv.vote_type = 'like')::bigint AS likes,
     (SELECT COUNT(*) FROM comment_votes cv WHERE cv.comment_id = c.id AND cv.vote_type = 'dislike')::bigint AS dislikes
 FROM comments c
-JOIN users u ON c.user_id = u.id
+LEFT JOIN users u ON c.user_id = u.id
 WHERE c.product_id = $1
 ORDER BY c.created_at ASC
 `
@@ -95,7 +157,7 @@ ORDER BY c.created_at ASC
 type GetCommentsByProductIdRow struct {
 	ID          uuid.UUID        `json:"id"`
 	ProductID   uuid.UUID        `json:"product_id"`
-	UserID      uuid.UUID        `json:"user_id"`
+	UserID      pgtype.UUID      `json:"user_id"`
 	ParentID    pgtype.UUID      `json:"parent_id"`
 	Content     string           `json:"content"`
 	CreatedAt   pgtype.Timestamp `json:"created_at"`
@@ -147,13 +209,13 @@ SELECT
     c.content,
     c.created_at,
     c.updated_at,
-    u.name AS author_name,
+    COALESCE(u.name, 'Usuario eliminado')::text AS author_name,
     u.image AS author_image,
     (SELECT COUNT(*) FROM comment_votes cv WHERE cv.comment_id = c.id AND cv.vote_type = 'like')::bigint AS likes,
     (SELECT COUNT(*) FROM comment_votes cv WHERE cv.comment_id = c.id AND cv.vote_type = 'dislike')::bigint AS dislikes,
     COALESCE((SELECT cv.vote_type FROM comment_votes cv WHERE cv.comment_id = c.id AND cv.user_id = $2), '')::text AS user_vote
 FROM comments c
-JOIN users u ON c.user_id = u.id
+LEFT JOIN users u ON c.user_id = u.id
 WHERE c.product_id = $1
 ORDER BY c.created_at ASC
 `
@@ -166,7 +228,7 @@ type GetCommentsByProductIdWithUserVoteParams struct {
 type GetCommentsByProductIdWithUserVoteRow struct {
 	ID          uuid.UUID        `json:"id"`
 	ProductID   uuid.UUID        `json:"product_id"`
-	UserID      uuid.UUID        `json:"user_id"`
+	UserID      pgtype.UUID      `json:"user_id"`
 	ParentID    pgtype.UUID      `json:"parent_id"`
 	Content     string           `json:"content"`
 	CreatedAt   pgtype.Timestamp `json:"created_at"`
@@ -211,6 +273,38 @@ func (q *Queries) GetCommentsByProductIdWithUserVote(ctx context.Context, arg Ge
 	return items, nil
 }
 
+const getCommentsByUserId = `-- name: GetCommentsByUserId :many
+SELECT id, product_id, user_id, parent_id, content, created_at, updated_at FROM comments WHERE user_id = $1 ORDER BY created_at ASC
+`
+
+func (q *Queries) GetCommentsByUserId(ctx context.Context, userID pgtype.UUID) ([]Comment, error) {
+	rows, err := q.db.Query(ctx, getCommentsByUserId, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Comment
+	for rows.Next() {
+		var i Comment
+		if err := rows.Scan(
+			&i.ID,
+			&i.ProductID,
+			&i.UserID,
+			&i.ParentID,
+			&i.Content,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const upsertCommentVote = `-- name: UpsertCommentVote :one
 INSERT INTO comment_votes (comment_id, user_id, vote_type)
 VALUES ($1, $2, $3)
@@ -9,6 +9,25 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+type AccountDeletion struct {
+	UserID       uuid.UUID        `json:"user_id"`
+	RequestedAt  pgtype.Timestamp `json:"requested_at"`
+	ScheduledFor pgtype.Timestamp `json:"scheduled_for"`
+}
+
+type ApiKey struct {
+	ID         uuid.UUID        `json:"id"`
+	UserID     uuid.UUID        `json:"user_id"`
+	Name       string           `json:"name"`
+	Prefix     string           `json:"prefix"`
+	KeyHash    string           `json:"key_hash"`
+	Scopes     []string         `json:"scopes"`
+	ExpiresAt  pgtype.Timestamp `json:"expires_at"`
+	LastUsedAt pgtype.Timestamp `json:"last_used_at"`
+	RevokedAt  pgtype.Timestamp `json:"revoked_at"`
+	CreatedAt  pgtype.Timestamp `json:"created_at"`
+}
+
 type Category struct {
 	ID        uuid.UUID        `json:"id"`
 	Name      string           `json:"name"`
@@ -19,7 +38,7 @@ type Category struct {
 type Comment struct {
 	ID        uuid.UUID        `json:"id"`
 	ProductID uuid.UUID        `json:"product_id"`
-	UserID    uuid.UUID        `json:"user_id"`
+	UserID    pgtype.UUID      `json:"user_id"`
 	ParentID  pgtype.UUID      `json:"parent_id"`
 	Content   string           `json:"content"`
 	CreatedAt pgtype.Timestamp `json:"created_at"`
@@ -34,6 +53,60 @@ type CommentVote struct {
 	CreatedAt pgtype.Timestamp `json:"created_at"`
 }
 
+type Conversation struct {
+	ID               uuid.UUID        `json:"id"`
+	ProductID        uuid.UUID        `json:"product_id"`
+	BuyerID          uuid.UUID        `json:"buyer_id"`
+	SellerID         uuid.UUID        `json:"seller_id"`
+	BuyerLastReadAt  pgtype.Timestamp `json:"buyer_last_read_at"`
+	SellerLastReadAt pgtype.Timestamp `json:"seller_last_read_at"`
+	LastMessageAt    pgtype.Timestamp `json:"last_message_at"`
+	CreatedAt        pgtype.Timestamp `json:"created_at"`
+}
+
+type Favorite struct {
+	UserID    uuid.UUID        `json:"user_id"`
+	ProductID uuid.UUID        `json:"product_id"`
+	CreatedAt pgtype.Timestamp `json:"created_at"`
+}
+
+type FavoriteNotification struct {
+	ID        uuid.UUID        `json:"id"`
+	UserID    uuid.UUID        `json:"user_id"`
+	ProductID uuid.UUID        `json:"product_id"`
+	Kind      string           `json:"kind"`
+	OldPrice  int64            `json:"old_price"`
+	NewPrice  int64            `json:"new_price"`
+	CreatedAt pgtype.Timestamp `json:"created_at"`
+	SentAt    pgtype.Timestamp `json:"sent_at"`
+}
+
+type LoginAttempt struct {
+	ID        uuid.UUID        `json:"id"`
+	Email     string           `json:"email"`
+	IpAddress string           `json:"ip_address"`
+	UserID    pgtype.UUID      `json:"user_id"`
+	UserAgent pgtype.Text      `json:"user_agent"`
+	Success   bool             `json:"success"`
+	CreatedAt pgtype.Timestamp `json:"created_at"`
+}
+
+type Message struct {
+	ID             uuid.UUID        `json:"id"`
+	ConversationID uuid.UUID        `json:"conversation_id"`
+	SenderID       uuid.UUID        `json:"sender_id"`
+	Content        string           `json:"content"`
+	CreatedAt      pgtype.Timestamp `json:"created_at"`
+}
+
+type MfaRecoveryCode struct {
+	ID        uuid.UUID        `json:"id"`
+	UserID    uuid.UUID        `json:"user_id"`
+	CodeHash  string           `json:"code_hash"`
+	UsedAt    pgtype.Timestamp `json:"used_at"`
+	CreatedAt pgtype.Timestamp `json:"created_at"`
+}
+
 type OauthAccount struct {
 	ID             uuid.UUID        `json:"id"`
 	UserID         uuid.UUID        `json:"user_id"`
@@ -45,6 +118,21 @@ type OauthAccount struct {
 	CreatedAt      pgtype.Timestamp `json:"created_at"`
 }
 
+type OauthExchangeCode struct {
+	CodeHash  string           `json:"code_hash"`
+	ExpiresAt pgtype.Timestamp `json:"expires_at"`
+	CreatedAt pgtype.Timestamp `json:"created_at"`
+	UserID    uuid.UUID        `json:"user_id"`
+}
+
+type OauthState struct {
+	StateHash    string           `json:"state_hash"`
+	CodeVerifier string           `json:"code_verifier"`
+	ExpiresAt    pgtype.Timestamp `json:"expires_at"`
+	CreatedAt    pgtype.Timestamp `json:"created_at"`
+	LinkUserID   pgtype.UUID      `json:"link_user_id"`
+}
+
 type Product struct {
 	ID          uuid.UUID        `json:"id"`
 	Name        string           `json:"name"`
@@ -63,6 +151,33 @@ type ProductImage struct {
 	ProductID uuid.UUID        `json:"product_id"`
 	ImageUrl  string           `json:"image_url"`
 	CreatedAt pgtype.Timestamp `json:"created_at"`
+	Position  int32            `json:"position"`
+	IsCover   bool             `json:"is_cover"`
+}
+
+type ProductImport struct {
+	ID            uuid.UUID        `json:"id"`
+	UserID        uuid.UUID        `json:"user_id"`
+	Status        string           `json:"status"`
+	Items         []byte           `json:"items"`
+	TotalRows     int32            `json:"total_rows"`
+	ProcessedRows int32            `json:"processed_rows"`
+	CreatedRows   int32            `json:"created_rows"`
+	FailedRows    int32            `json:"failed_rows"`
+	RowErrors     []byte           `json:"row_errors"`
+	CreatedAt     pgtype.Timestamp `json:"created_at"`
+	StartedAt     pgtype.Timestamp `json:"started_at"`
+	UpdatedAt     pgtype.Timestamp `json:"updated_at"`
+	FinishedAt    pgtype.Timestamp `json:"finished_at"`
+}
+
+type ProductStateHistory struct {
+	ID        uuid.UUID        `json:"id"`
+	ProductID uuid.UUID        `json:"product_id"`
+	FromState pgtype.Text      `json:"from_state"`
+	ToState   string           `json:"to_state"`
+	ChangedBy pgtype.UUID      `json:"changed_by"`
+	CreatedAt pgtype.Timestamp `json:"created_at"`
 }
 
 type ProductsCategory struct {
@@ -79,6 +194,28 @@ type RefreshToken struct {
 	ExpiresAt pgtype.Timestamp `json:"expires_at"`
 	CreatedAt pgtype.Timestamp `json:"created_at"`
 	Revoked   pgtype.Bool      `json:"revoked"`
+	FamilyID  uuid.UUID        `json:"family_id"`
+}
+
+type RefreshTokenFamily struct {
+	ID         uuid.UUID        `json:"id"`
+	UserID     uuid.UUID        `json:"user_id"`
+	UserAgent  pgtype.Text      `json:"user_agent"`
+	IpAddress  pgtype.Text      `json:"ip_address"`
+	CreatedAt  pgtype.Timestamp `json:"created_at"`
+	LastUsedAt pgtype.Timestamp `json:"last_used_at"`
+	RevokedAt  pgtype.Timestamp `json:"revoked_at"`
+}
+
+type Review struct {
+	ID         uuid.UUID        `json:"id"`
+	ProductID  pgtype.UUID      `json:"product_id"`
+	ReviewerID uuid.UUID        `json:"reviewer_id"`
+	SellerID   uuid.UUID        `json:"seller_id"`
+	Rating     int16            `json:"rating"`
+	Content    string           `json:"content"`
+	CreatedAt  pgtype.Timestamp `json:"created_at"`
+	UpdatedAt  pgtype.Timestamp `json:"updated_at"`
 }
 
 type User struct {
@@ -92,6 +229,15 @@ type User struct {
 	UpdatedAt     pgtype.Timestamp `json:"updated_at"`
 	Region        pgtype.Text      `json:"region"`
 	City          pgtype.Text      `json:"city"`
+	Role          string           `json:"role"`
+}
+
+type UserMfa struct {
+	UserID       uuid.UUID        `json:"user_id"`
+	TotpSecret   string           `json:"totp_secret"`
+	EnabledAt    pgtype.Timestamp `json:"enabled_at"`
+	LastUsedStep int64            `json:"last_used_step"`
+	CreatedAt    pgtype.Timestamp `json:"created_at"`
 }
 
 type VerificationToken struct {
@@ -101,4 +247,5 @@ type VerificationToken struct {
 	Type      string           `json:"type"`
 	ExpiresAt pgtype.Timestamp `json:"expires_at"`
 	CreatedAt pgtype.Timestamp `json:"created_at"`
+	NewEmail  pgtype.Text      `json:"new_email"`
 }
@@ -12,6 +12,63 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const clearProductImageCover = `-- name: ClearProductImageCover :exec
+UPDATE product_images SET is_cover = FALSE WHERE product_id = $1 AND is_cover
+`
+
+func (q *Queries) ClearProductImageCover(ctx context.Context, productID uuid.UUID) error {
+	_, err := q.db.Exec(ctx, clearProductImageCover, productID)
+	return err
+}
+
+const compactProductImagePositions = `-- name: CompactProductImagePositions :exec
+UPDATE product_images pi
+SET position = o.position
+FROM (
+    SELECT id, ROW_NUMBER() OVER (ORDER BY position, created_at) - 1 AS position
+    FROM product_images
+    WHERE product_id = $1
+) o
+WHERE pi.id = o.id AND pi.position <> o.position
+`
+
+func (q *Queries) CompactProductImagePositions(ctx context.Context, productID uuid.UUID) error {
+	_, err := q.db.Exec(ctx, compactProductImagePositions, productID)
+	return err
+}
+
+const countUserProductsByState = `-- name: CountUserProductsByState :many
+SELECT state, COUNT(*) AS product_count FROM products
+WHERE user_id = $1
+GROUP BY state
+ORDER BY state
+`
+
+type CountUserProductsByStateRow struct {
+	State        string `json:"state"`
+	ProductCount int64  `json:"product_count"`
+}
+
+func (q *Queries) CountUserProductsByState(ctx context.Context, userID pgtype.UUID) ([]CountUserProductsByStateRow, error) {
+	rows, err := q.db.Query(ctx, countUserProductsByState, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []CountUserProductsByStateRow
+	for rows.Next() {
+		var i CountUserProductsByStateRow
+		if err := rows.Scan(&i.State, &i.ProductCount); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const createProduct = `-- name: CreateProduct :one
 INSERT INTO products
 (name, description, price, user_id, condition, state, negotiable)
@@ -77,26 +134,57 @@ func (q *Queries) CreateProductCategory(ctx context.Context, arg CreateProductCa
 }
 
 const createProductImage = `-- name: CreateProductImage :one
-INSERT INTO product_images (product_id, image_url) VALUES ($1, $2) RETURNING id, product_id, image_url, created_at
+INSERT INTO product_images (product_id, image_url, position, is_cover) VALUES ($1, $2, $3, $4) RETURNING id, product_id, image_url, created_at, position, is_cover
 `
 
 type CreateProductImageParams struct {
 	ProductID uuid.UUID `json:"product_id"`
 	ImageUrl  string    `json:"image_url"`
+	Position  int32     `json:"position"`
+	IsCover   bool      `json:"is_cover"`
 }
 
 func (q *Queries) CreateProductImage(ctx context.Context, arg CreateProductImageParams) (ProductImage, error) {
-	row := q.db.QueryRow(ctx, createProductImage, arg.ProductID, arg.ImageUrl)
+	row := q.db.QueryRow(ctx, createProductImage,
+		arg.ProductID,
+		arg.ImageUrl,
+		arg.Position,
+		arg.IsCover,
+	)
 	var i ProductImage
 	err := row.Scan(
 		&i.ID,
 		&i.ProductID,
 		&i.ImageUrl,
 		&i.CreatedAt,
+		&i.Position,
+		&i.IsCover,
 	)
 	return i, err
 }
 
+const createProductStateHistory = `-- name: CreateProductStateHistory :exec
+INSERT INTO product_state_history (product_id, from_state, to_state, changed_by)
+VALUES ($1, $2, $3, $4)
+`
+
+type CreateProductStateHistoryParams struct {
+	ProductID uuid.UUID   `json:"product_id"`
+	FromState pgtype.Text `json:"from_state"`
+	ToState   string      `json:"to_state"`
+	ChangedBy pgtype.UUID `json:"changed_by"`
+}
+
+func (q *Queries) CreateProductStateHistory(ctx context.Context, arg CreateProductStateHistoryParams) error {
+	_, err := q.db.Exec(ctx, createProductStateHistory,
+		arg.ProductID,
+		arg.FromState,
+		arg.ToState,
+		arg.ChangedBy,
+	)
+	return err
+}
+
 const deleteProduct = `-- name: DeleteProduct :many
 DELETE FROM products WHERE id = $1
 RETURNING id, name, description, price, created_at, updated_at, user_id, condition, state, negotiable
@@ -147,6 +235,65 @@ func (q *Queries) DeleteProductByOwner(ctx context.Context, arg DeleteProductByO
 	return err
 }
 
+const deleteProductImage = `-- name: DeleteProductImage :one
+DELETE FROM product_images WHERE id = $1 AND product_id = $2
+RETURNING id, product_id, image_url, created_at, position, is_cover
+`
+
+type DeleteProductImageParams struct {
+	ID        uuid.UUID `json:"id"`
+	ProductID uuid.UUID `json:"product_id"`
+}
+
+func (q *Queries) DeleteProductImage(ctx context.Context, arg DeleteProductImageParams) (ProductImage, error) {
+	row := q.db.QueryRow(ctx, deleteProductImage, arg.ID, arg.ProductID)
+	var i ProductImage
+	err := row.Scan(
+		&i.ID,
+		&i.ProductID,
+		&i.ImageUrl,
+		&i.CreatedAt,
+		&i.Position,
+		&i.IsCover,
+	)
+	return i, err
+}
+
+const ensureProductImageCover = `-- name: EnsureProductImageCover :exec
+UPDATE product_images SET is_cover = TRUE
+WHERE id = (
+    SELECT id FROM product_images
+    WHERE product_id = $1
+    ORDER BY position ASC, created_at ASC
+    LIMIT 1
+)
+AND NOT EXISTS (SELECT 1 FROM product_images WHERE product_id = $1 AND is_cover)
+`
+
+func (q *Queries) EnsureProductImageCover(ctx context.Context, productID uuid.UUID) error {
+	_, err := q.db.Exec(ctx, ensureProductImageCover, productID)
+	return err
+}
+
+const expireStaleProducts = `-- name: ExpireStaleProducts :execrows
+WITH expired AS (
+    UPDATE products
+    SET state = 'expired', updated_at = NOW()
+    WHERE state = 'available' AND updated_at < $1
+    RETURNING id
+)
+INSERT INTO product_state_history (product_id, from_state, to_state)
+SELECT id, 'available', 'expired' FROM expired
+`
+
+func (q *Queries) ExpireStaleProducts(ctx context.Context, updatedBefore pgtype.Timestamp) (int64, error) {
+	result, err := q.db.Exec(ctx, expireStaleProducts, updatedBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const getProductById = `-- name: GetProductById :one
 SELECT id, name, description, price, created_at, updated_at, user_id, condition, state, negotiable FROM products WHERE id = $1
 `
@@ -202,7 +349,8 @@ func (q *Queries) GetProductCategoriesById(ctx context.Context, productID uuid.U
 }
 
 const getProductImagesById = `-- name: GetProductImagesById :many
-SELECT id, product_id, image_url, created_at FROM product_images WHERE product_id = $1
+SELECT id, product_id, image_url, created_at, position, is_cover FROM product_images WHERE product_id = $1
+ORDER BY is_cover DESC, position ASC, created_at ASC
 `
 
 func (q *Queries) GetProductImagesById(ctx context.Context, productID uuid.UUID) ([]ProductImage, error) {
@@ -219,6 +367,8 @@ func (q *Queries) GetProductImagesById(ctx context.Context, productID uuid.UUID)
 			&i.ProductID,
 			&i.ImageUrl,
 			&i.CreatedAt,
+			&i.Position,
+			&i.IsCover,
 		); err != nil {
 			return nil, err
 		}
@@ -230,30 +380,28 @@ func (q *Queries) GetProductImagesById(ctx context.Context, productID uuid.UUID)
 	return items, nil
 }
 
-const getProducts = `-- name: GetProducts :many
-SELECT id, name, description, price, created_at, updated_at, user_id, condition, state, negotiable FROM products p
+const getProductStateHistory = `-- name: GetProductStateHistory :many
+SELECT id, product_id, from_state, to_state, changed_by, created_at FROM product_state_history
+WHERE product_id = $1
+ORDER BY created_at DESC
 `
 
-func (q *Queries) GetProducts(ctx context.Context) ([]Product, error) {
-	rows, err := q.db.Query(ctx, getProducts)
+func (q *Queries) GetProductStateHistory(ctx context.Context, productID uuid.UUID) ([]ProductStateHistory, error) {
+	rows, err := q.db.Query(ctx, getProductStateHistory, productID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []Product
+	var items []ProductStateHistory
 	for rows.Next() {
-		var i Product
+		var i ProductStateHistory
 		if err := rows.Scan(
 			&i.ID,
-			&i.Name,
-			&i.Description,
-			&i.Price,
+			&i.ProductID,
+			&i.FromState,
+			&i.ToState,
+			&i.ChangedBy,
 			&i.CreatedAt,
-			&i.UpdatedAt,
-			&i.UserID,
-			&i.Condition,
-			&i.State,
-			&i.Negotiable,
 		); err != nil {
 			return nil, err
 		}
@@ -300,26 +448,27 @@ func (q *Queries) GetProductsByUserId(ctx context.Context, userID pgtype.UUID) (
 	return items, nil
 }
 
-const getProductsCategories = `-- name: GetProductsCategories :many
+const getProductsCategoriesByProductIds = `-- name: GetProductsCategoriesByProductIds :many
 SELECT c.id, pc.product_id, c.name FROM products_category pc
 JOIN categories c ON pc.category_id = c.id
+WHERE pc.product_id = ANY($1::uuid[])
 `
 
-type GetProductsCategoriesRow struct {
+type GetProductsCategoriesByProductIdsRow struct {
 	ID        uuid.UUID `json:"id"`
 	ProductID uuid.UUID `json:"product_id"`
 	Name      string    `json:"name"`
 }
 
-func (q *Queries) GetProductsCategories(ctx context.Context) ([]GetProductsCategoriesRow, error) {
-	rows, err := q.db.Query(ctx, getProductsCategories)
+func (q *Queries) GetProductsCategoriesByProductIds(ctx context.Context, productIds []uuid.UUID) ([]GetProductsCategoriesByProductIdsRow, error) {
+	rows, err := q.db.Query(ctx, getProductsCategoriesByProductIds, productIds)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []GetProductsCategoriesRow
+	var items []GetProductsCategoriesByProductIdsRow
 	for rows.Next() {
-		var i GetProductsCategoriesRow
+		var i GetProductsCategoriesByProductIdsRow
 		if err := rows.Scan(&i.ID, &i.ProductID, &i.Name); err != nil {
 			return nil, err
 		}
@@ -331,12 +480,14 @@ func (q *Queries) GetProductsCategories(ctx context.Context) ([]GetProductsCateg
 	return items, nil
 }
 
-const getProductsImages = `-- name: GetProductsImages :many
-SELECT id, product_id, image_url, created_at FROM product_images
+const getProductsImagesByProductIds = `-- name: GetProductsImagesByProductIds :many
+SELECT id, product_id, image_url, created_at, position, is_cover FROM product_images
+WHERE product_id = ANY($1::uuid[])
+ORDER BY is_cover DESC, position ASC, created_at ASC
 `
 
-func (q *Queries) GetProductsImages(ctx context.Context) ([]ProductImage, error) {
-	rows, err := q.db.Query(ctx, getProductsImages)
+func (q *Queries) GetProductsImagesByProductIds(ctx context.Context, productIds []uuid.UUID) ([]ProductImage, error) {
+	rows, err := q.db.Query(ctx, getProductsImagesByProductIds, productIds)
 	if err != nil {
 		return nil, err
 	}
@@ -349,6 +500,8 @@ func (q *Queries) GetProductsImages(ctx context.Context) ([]ProductImage, error)
 			&i.ProductID,
 			&i.ImageUrl,
 			&i.CreatedAt,
+			&i.Position,
+			&i.IsCover,
 		); err != nil {
 			return nil, err
 		}
@@ -360,14 +513,57 @@ func (q *Queries) GetProductsImages(ctx context.Context) ([]ProductImage, error)
 	return items, nil
 }
 
-const searchProducts = `-- name: SearchProducts :many
-SELECT id, name, description, price, created_at, updated_at, user_id, condition, state, negotiable FROM products p
-WHERE p.name ILIKE '%' || $1 || '%'
-   OR p.description ILIKE '%' || $1 || '%'
+const listProducts = `-- name: ListProducts :many
+SELECT p.id, p.name, p.description, p.price, p.created_at, p.updated_at, p.user_id, p.condition, p.state, p.negotiable FROM products p
+LEFT JOIN users u ON u.id = p.user_id
+WHERE ($1::uuid[] IS NULL OR EXISTS (
+    SELECT 1 FROM products_category pc
+    WHERE pc.product_id = p.id AND pc.category_id = ANY($1::uuid[])
+  ))
+  AND ($2::bigint IS NULL OR p.price >= $2)
+  AND ($3::bigint IS NULL OR p.price <= $3)
+  AND ($4::text IS NULL OR p.condition = $4)
+  AND ($5::text IS NULL OR p.state = $5)
+  AND ($6::text IS NULL OR p.negotiable = $6)
+  AND ($7::text IS NULL OR u.region = $7)
+  AND ($8::text IS NULL OR u.city = $8)
+  AND ($9::uuid IS NULL OR p.user_id = $9)
+  AND ($10::timestamp IS NULL
+    OR (p.created_at, p.id) < ($10::timestamp, $11::uuid))
+ORDER BY p.created_at DESC, p.id DESC
+LIMIT $12
 `
 
-func (q *Queries) SearchProducts(ctx context.Context, dollar_1 pgtype.Text) ([]Product, error) {
-	rows, err := q.db.Query(ctx, searchProducts, dollar_1)
+type ListProductsParams struct {
+	CategoryIds     []uuid.UUID      `json:"category_ids"`
+	MinPrice        pgtype.Int8      `json:"min_price"`
+	MaxPrice        pgtype.Int8      `json:"max_price"`
+	Condition       pgtype.Text      `json:"condition"`
+	State           pgtype.Text      `json:"state"`
+	Negotiable      pgtype.Text      `json:"negotiable"`
+	Region          pgtype.Text      `json:"region"`
+	City            pgtype.Text      `json:"city"`
+	SellerID        pgtype.UUID      `json:"seller_id"`
+	CursorCreatedAt pgtype.Timestamp `json:"cursor_created_at"`
+	CursorID        pgtype.UUID      `json:"cursor_id"`
+	PageLimit       int32            `json:"page_limit"`
+}
+
+func (q *Queries) ListProducts(ctx context.Context, arg ListProductsParams) ([]Product, error) {
+	rows, err := q.db.Query(ctx, listProducts,
+		arg.CategoryIds,
+		arg.MinPrice,
+		arg.MaxPrice,
+		arg.Condition,
+		arg.State,
+		arg.Negotiable,
+		arg.Region,
+		arg.City,
+		arg.SellerID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageLimit,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -397,6 +593,193 @@ func (q *Queries) SearchProducts(ctx context.Context, dollar_1 pgtype.Text) ([]P
 	return items, nil
 }
 
+const lockProductImages = `-- name: LockProductImages :many
+SELECT id, product_id, image_url, created_at, position, is_cover FROM product_images WHERE product_id = $1
+ORDER BY position ASC, created_at ASC
+FOR UPDATE
+`
+
+func (q *Queries) LockProductImages(ctx context.Context, productID uuid.UUID) ([]ProductImage, error) {
+	rows, err := q.db.Query(ctx, lockProductImages, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ProductImage
+	for rows.Next() {
+		var i ProductImage
+		if err := rows.Scan(
+			&i.ID,
+			&i.ProductID,
+			&i.ImageUrl,
+			&i.CreatedAt,
+			&i.Position,
+			&i.IsCover,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const reorderProductImages = `-- name: ReorderProductImages :execrows
+UPDATE product_images pi
+SET position = o.position - 1, is_cover = (pi.id = $1::uuid)
+FROM unnest($2::uuid[]) WITH ORDINALITY AS o(id, position)
+WHERE pi.id = o.id AND pi.product_id = $3
+`
+
+type ReorderProductImagesParams struct {
+	CoverID   uuid.UUID   `json:"cover_id"`
+	ImageIds  []uuid.UUID `json:"image_ids"`
+	ProductID uuid.UUID   `json:"product_id"`
+}
+
+func (q *Queries) ReorderProductImages(ctx context.Context, arg ReorderProductImagesParams) (int64, error) {
+	result, err := q.db.Exec(ctx, reorderProductImages, arg.CoverID, arg.ImageIds, arg.ProductID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const searchProducts = `-- name: SearchProducts :many
+SELECT p.id, p.name, p.description, p.price, p.created_at, p.updated_at, p.user_id, p.condition, p.state, p.negotiable,
+    ts_rank(products_search_vector(p.name, p.description), query)::real AS rank,
+    ts_headline('spanish_unaccent', p.name, query, 'StartSel=<mark>, StopSel=</mark>, HighlightAll=true')::text AS name_highlight,
+    ts_headline('spanish_unaccent', coalesce(p.description, ''), query, 'StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MaxWords=20, MinWords=8')::text AS description_highlight
+FROM products p
+CROSS JOIN to_tsquery('spanish_unaccent', $1) query
+LEFT JOIN users u ON u.id = p.user_id
+WHERE products_search_vector(p.name, p.description) @@ query
+  AND ($2::uuid[] IS NULL OR EXISTS (
+    SELECT 1 FROM products_category pc
+    WHERE pc.product_id = p.id AND pc.category_id = ANY($2::uuid[])
+  ))
+  AND ($3::bigint IS NULL OR p.price >= $3)
+  AND ($4::bigint IS NULL OR p.price <= $4)
+  AND ($5::text IS NULL OR p.condition = $5)
+  AND ($6::text IS NULL OR p.state = $6)
+  AND ($7::text IS NULL OR p.negotiable = $7)
+  AND ($8::text IS NULL OR u.region = $8)
+  AND ($9::text IS NULL OR u.city = $9)
+ORDER BY rank DESC, p.created_at DESC, p.id DESC
+LIMIT $10 OFFSET $11
+`
+
+type SearchProductsParams struct {
+	Query       string      `json:"query"`
+	CategoryIds []uuid.UUID `json:"category_ids"`
+	MinPrice    pgtype.Int8 `json:"min_price"`
+	MaxPrice    pgtype.Int8 `json:"max_price"`
+	Condition   pgtype.Text `json:"condition"`
+	State       pgtype.Text `json:"state"`
+	Negotiable  pgtype.Text `json:"negotiable"`
+	Region      pgtype.Text `json:"region"`
+	City        pgtype.Text `json:"city"`
+	PageLimit   int32       `json:"page_limit"`
+	PageOffset  int32       `json:"page_offset"`
+}
+
+type SearchProductsRow struct {
+	Product              Product `json:"product"`
+	Rank                 float32 `json:"rank"`
+	NameHighlight        string  `json:"name_highlight"`
+	DescriptionHighlight string  `json:"description_highlight"`
+}
+
+func (q *Queries) SearchProducts(ctx context.Context, arg SearchProductsParams) ([]SearchProductsRow, error) {
+	rows, err := q.db.Query(ctx, searchProducts,
+		arg.Query,
+		arg.CategoryIds,
+		arg.MinPrice,
+		arg.MaxPrice,
+		arg.Condition,
+		arg.State,
+		arg.Negotiable,
+		arg.Region,
+		arg.City,
+		arg.PageLimit,
+		arg.PageOffset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchProductsRow
+	for rows.Next() {
+		var i SearchProductsRow
+		if err := rows.Scan(
+			&i.Product.ID,
+			&i.Product.Name,
+			&i.Product.Description,
+			&i.Product.Price,
+			&i.Product.CreatedAt,
+			&i.Product.UpdatedAt,
+			&i.Product.UserID,
+			&i.Product.Condition,
+			&i.Product.State,
+			&i.Product.Negotiable,
+			&i.Rank,
+			&i.NameHighlight,
+			&i.DescriptionHighlight,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const suggestProducts = `-- name: SuggestProducts :many
+SELECT p.id, p.name,
+    ts_headline('spanish_unaccent', p.name, query, 'StartSel=<mark>, StopSel=</mark>, HighlightAll=true')::text AS name_highlight
+FROM products p
+CROSS JOIN to_tsquery('spanish_unaccent', $1) query
+WHERE products_search_vector(p.name, p.description) @@ query
+  AND p.state = 'available'
+ORDER BY ts_rank(products_search_vector(p.name, p.description), query) DESC, p.created_at DESC
+LIMIT $2
+`
+
+type SuggestProductsParams struct {
+	Query     string `json:"query"`
+	PageLimit int32  `json:"page_limit"`
+}
+
+type SuggestProductsRow struct {
+	ID            uuid.UUID `json:"id"`
+	Name          string    `json:"name"`
+	NameHighlight string    `json:"name_highlight"`
+}
+
+func (q *Queries) SuggestProducts(ctx context.Context, arg SuggestProductsParams) ([]SuggestProductsRow, error) {
+	rows, err := q.db.Query(ctx, suggestProducts, arg.Query, arg.PageLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SuggestProductsRow
+	for rows.Next() {
+		var i SuggestProductsRow
+		if err := rows.Scan(&i.ID, &i.Name, &i.NameHighlight); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const updateProduct = `-- name: UpdateProduct :many
 UPDATE products
 SET name=coalesce($2, name), description=coalesce($3,description), price=coalesce($4, price), condition=coalesce($5, condition), state=coalesce($6, state), negotiable=coalesce($7, negotiable), updated_at=NOW()
@@ -452,3 +835,34 @@ func (q *Queries) UpdateProduct(ctx context.Context, arg UpdateProductParams) ([
 	}
 	return items, nil
 }
+
+const updateProductState = `-- name: UpdateProductState :one
+UPDATE products
+SET state = $1, updated_at = NOW()
+WHERE id = $2 AND state = $3
+RETURNING id, name, description, price, created_at, updated_at, user_id, condition, state, negotiable
+`
+
+type UpdateProductStateParams struct {
+	ToState   string    `json:"to_state"`
+	ID        uuid.UUID `json:"id"`
+	FromState string    `json:"from_state"`
+}
+
+func (q *Queries) UpdateProductState(ctx context.Context, arg UpdateProductStateParams) (Product, error) {
+	row := q.db.QueryRow(ctx, updateProductState, arg.ToState, arg.ID, arg.FromState)
+	var i Product
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Description,
+		&i.Price,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Condition,
+		&i.State,
+		&i.Negotiable,
+	)
+	return i, err
+}
@@ -12,6 +12,82 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const consumeRefreshToken = `-- name: ConsumeRefreshToken :one
+UPDATE refresh_tokens rt SET revoked = TRUE
+FROM refresh_token_families f
+WHERE rt.token_hash = $1
+  AND rt.revoked = FALSE
+  AND rt.expires_at > CURRENT_TIMESTAMP
+  AND f.id = rt.family_id
+  AND f.revoked_at IS NULL
+RETURNING rt.id, rt.user_id, rt.token_hash, rt.expires_at, rt.created_at, rt.revoked, rt.family_id
+`
+
+func (q *Queries) ConsumeRefreshToken(ctx context.Context, tokenHash string) (RefreshToken, error) {
+	row := q.db.QueryRow(ctx, consumeRefreshToken, tokenHash)
+	var i RefreshToken
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.TokenHash,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+		&i.Revoked,
+		&i.FamilyID,
+	)
+	return i, err
+}
+
+const consumeVerificationToken = `-- name: ConsumeVerificationToken :one
+DELETE FROM verification_tokens
+WHERE token = $1 AND type = $2 AND expires_at > CURRENT_TIMESTAMP
+RETURNING id, user_id, token, type, expires_at, created_at, new_email
+`
+
+type ConsumeVerificationTokenParams struct {
+	Token string `json:"token"`
+	Type  string `json:"type"`
+}
+
+func (q *Queries) ConsumeVerificationToken(ctx context.Context, arg ConsumeVerificationTokenParams) (VerificationToken, error) {
+	row := q.db.QueryRow(ctx, consumeVerificationToken, arg.Token, arg.Type)
+	var i VerificationToken
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Token,
+		&i.Type,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+		&i.NewEmail,
+	)
+	return i, err
+}
+
+const createEmailChangeToken = `-- name: CreateEmailChangeToken :exec
+INSERT INTO verification_tokens (user_id, token, type, expires_at, new_email)
+VALUES ($1, $2, $3, $4, $5)
+`
+
+type CreateEmailChangeTokenParams struct {
+	UserID    uuid.UUID        `json:"user_id"`
+	Token     string           `json:"token"`
+	Type      string           `json:"type"`
+	ExpiresAt pgtype.Timestamp `json:"expires_at"`
+	NewEmail  pgtype.Text      `json:"new_email"`
+}
+
+func (q *Queries) CreateEmailChangeToken(ctx context.Context, arg CreateEmailChangeTokenParams) error {
+	_, err := q.db.Exec(ctx, createEmailChangeToken,
+		arg.UserID,
+		arg.Token,
+		arg.Type,
+		arg.ExpiresAt,
+		arg.NewEmail,
+	)
+	return err
+}
+
 const createOAuthAccount = `-- name: CreateOAuthAccount :one
 INSERT INTO oauth_accounts (user_id, provider, provider_user_id, access_token, refresh_token, expires_at)
 VALUES ($1, $2, $3, $4, $5, $6)
@@ -51,19 +127,25 @@ func (q *Queries) CreateOAuthAccount(ctx context.Context, arg CreateOAuthAccount
 }
 
 const createRefreshToken = `-- name: CreateRefreshToken :one
-INSERT INTO refresh_tokens (user_id, token_hash, expires_at)
-VALUES ($1, $2, $3)
-RETURNING id, user_id, token_hash, expires_at, created_at, revoked
+INSERT INTO refresh_tokens (user_id, token_hash, expires_at, family_id)
+VALUES ($1, $2, $3, $4)
+RETURNING id, user_id, token_hash, expires_at, created_at, revoked, family_id
 `
 
 type CreateRefreshTokenParams struct {
 	UserID    uuid.UUID        `json:"user_id"`
 	TokenHash string           `json:"token_hash"`
 	ExpiresAt pgtype.Timestamp `json:"expires_at"`
+	FamilyID  uuid.UUID        `json:"family_id"`
 }
 
 func (q *Queries) CreateRefreshToken(ctx context.Context, arg CreateRefreshTokenParams) (RefreshToken, error) {
-	row := q.db.QueryRow(ctx, createRefreshToken, arg.UserID, arg.TokenHash, arg.ExpiresAt)
+	row := q.db.QueryRow(ctx, createRefreshToken,
+		arg.UserID,
+		arg.TokenHash,
+		arg.ExpiresAt,
+		arg.FamilyID,
+	)
 	var i RefreshToken
 	err := row.Scan(
 		&i.ID,
@@ -72,6 +154,34 @@ func (q *Queries) CreateRefreshToken(ctx context.Context, arg CreateRefreshToken
 		&i.ExpiresAt,
 		&i.CreatedAt,
 		&i.Revoked,
+		&i.FamilyID,
+	)
+	return i, err
+}
+
+const createRefreshTokenFamily = `-- name: CreateRefreshTokenFamily :one
+INSERT INTO refresh_token_families (user_id, user_agent, ip_address)
+VALUES ($1, $2, $3)
+RETURNING id, user_id, user_agent, ip_address, created_at, last_used_at, revoked_at
+`
+
+type CreateRefreshTokenFamilyParams struct {
+	UserID    uuid.UUID   `json:"user_id"`
+	UserAgent pgtype.Text `json:"user_agent"`
+	IpAddress pgtype.Text `json:"ip_address"`
+}
+
+func (q *Queries) CreateRefreshTokenFamily(ctx context.Context, arg CreateRefreshTokenFamilyParams) (RefreshTokenFamily, error) {
+	row := q.db.QueryRow(ctx, createRefreshTokenFamily, arg.UserID, arg.UserAgent, arg.IpAddress)
+	var i RefreshTokenFamily
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.UserAgent,
+		&i.IpAddress,
+		&i.CreatedAt,
+		&i.LastUsedAt,
+		&i.RevokedAt,
 	)
 	return i, err
 }
@@ -79,7 +189,7 @@ func (q *Queries) CreateRefreshToken(ctx context.Context, arg CreateRefreshToken
 const createUser = `-- name: CreateUser :one
 INSERT INTO users (email, password_hash, name, email_verified, image)
 VALUES ($1, $2, $3, $4, $5)
-RETURNING id, email, password_hash, name, email_verified, image, created_at, updated_at, region, city
+RETURNING id, email, password_hash, name, email_verified, image, created_at, updated_at, region, city, role
 `
 
 type CreateUserParams struct {
@@ -110,6 +220,7 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.UpdatedAt,
 		&i.Region,
 		&i.City,
+		&i.Role,
 	)
 	return i, err
 }
@@ -117,7 +228,7 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 const createVerificationToken = `-- name: CreateVerificationToken :one
 INSERT INTO verification_tokens (user_id, token, type, expires_at)
 VALUES ($1, $2, $3, $4)
-RETURNING id, user_id, token, type, expires_at, created_at
+RETURNING id, user_id, token, type, expires_at, created_at, new_email
 `
 
 type CreateVerificationTokenParams struct {
@@ -142,10 +253,49 @@ func (q *Queries) CreateVerificationToken(ctx context.Context, arg CreateVerific
 		&i.Type,
 		&i.ExpiresAt,
 		&i.CreatedAt,
+		&i.NewEmail,
 	)
 	return i, err
 }
 
+const deleteOAuthAccountKeepingSignIn = `-- name: DeleteOAuthAccountKeepingSignIn :execrows
+DELETE FROM oauth_accounts oa
+WHERE oa.user_id = $1
+  AND oa.provider = $2
+  AND (
+    EXISTS (SELECT 1 FROM users u WHERE u.id = oa.user_id AND u.password_hash IS NOT NULL)
+    OR EXISTS (SELECT 1 FROM oauth_accounts o WHERE o.user_id = oa.user_id AND o.id <> oa.id)
+  )
+`
+
+type DeleteOAuthAccountKeepingSignInParams struct {
+	UserID   uuid.UUID `json:"user_id"`
+	Provider string    `json:"provider"`
+}
+
+// Only deletes when the user can still sign in with a password or another provider
+func (q *Queries) DeleteOAuthAccountKeepingSignIn(ctx context.Context, arg DeleteOAuthAccountKeepingSignInParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteOAuthAccountKeepingSignIn, arg.UserID, arg.Provider)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteUserVerificationTokensByType = `-- name: DeleteUserVerificationTokensByType :exec
+DELETE FROM verification_tokens WHERE user_id = $1 AND type = $2
+`
+
+type DeleteUserVerificationTokensByTypeParams struct {
+	UserID uuid.UUID `json:"user_id"`
+	Type   string    `json:"type"`
+}
+
+func (q *Queries) DeleteUserVerificationTokensByType(ctx context.Context, arg DeleteUserVerificationTokensByTypeParams) error {
+	_, err := q.db.Exec(ctx, deleteUserVerificationTokensByType, arg.UserID, arg.Type)
+	return err
+}
+
 const deleteVerificationToken = `-- name: DeleteVerificationToken :exec
 DELETE FROM verification_tokens WHERE token = $1
 `
@@ -205,12 +355,45 @@ func (q *Queries) GetOAuthAccountByUserId(ctx context.Context, arg GetOAuthAccou
 	return i, err
 }
 
-const getRefreshToken = `-- name: GetRefreshToken :one
-SELECT id, user_id, token_hash, expires_at, created_at, revoked FROM refresh_tokens WHERE token_hash = $1 AND revoked = FALSE AND expires_at > CURRENT_TIMESTAMP LIMIT 1
+const getRefreshTokenFamily = `-- name: GetRefreshTokenFamily :one
+SELECT id, user_id, user_agent, ip_address, created_at, last_used_at, revoked_at FROM refresh_token_families
+WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+LIMIT 1
 `
 
-func (q *Queries) GetRefreshToken(ctx context.Context, tokenHash string) (RefreshToken, error) {
-	row := q.db.QueryRow(ctx, getRefreshToken, tokenHash)
+type GetRefreshTokenFamilyParams struct {
+	ID     uuid.UUID `json:"id"`
+	UserID uuid.UUID `json:"user_id"`
+}
+
+func (q *Queries) GetRefreshTokenFamily(ctx context.Context, arg GetRefreshTokenFamilyParams) (RefreshTokenFamily, error) {
+	row := q.db.QueryRow(ctx, getRefreshTokenFamily, arg.ID, arg.UserID)
+	var i RefreshTokenFamily
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.UserAgent,
+		&i.IpAddress,
+		&i.CreatedAt,
+		&i.LastUsedAt,
+		&i.RevokedAt,
+	)
+	return i, err
+}
+
+const getRotatedRefreshToken = `-- name: GetRotatedRefreshToken :one
+SELECT rt.id, rt.user_id, rt.token_hash, rt.expires_at, rt.created_at, rt.revoked, rt.family_id FROM refresh_tokens rt
+WHERE rt.token_hash = $1
+  AND rt.revoked = TRUE
+  AND EXISTS (
+    SELECT 1 FROM refresh_tokens newer
+    WHERE newer.family_id = rt.family_id AND newer.id <> rt.id AND newer.created_at >= rt.created_at
+  )
+LIMIT 1
+`
+
+func (q *Queries) GetRotatedRefreshToken(ctx context.Context, tokenHash string) (RefreshToken, error) {
+	row := q.db.QueryRow(ctx, getRotatedRefreshToken, tokenHash)
 	var i RefreshToken
 	err := row.Scan(
 		&i.ID,
@@ -219,12 +402,13 @@ func (q *Queries) GetRefreshToken(ctx context.Context, tokenHash string) (Refres
 		&i.ExpiresAt,
 		&i.CreatedAt,
 		&i.Revoked,
+		&i.FamilyID,
 	)
 	return i, err
 }
 
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, email, password_hash, name, email_verified, image, created_at, updated_at, region, city FROM users WHERE email = $1 LIMIT 1
+SELECT id, email, password_hash, name, email_verified, image, created_at, updated_at, region, city, role FROM users WHERE LOWER(email) = LOWER($1) LIMIT 1
 `
 
 func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
@@ -241,12 +425,13 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.UpdatedAt,
 		&i.Region,
 		&i.City,
+		&i.Role,
 	)
 	return i, err
 }
 
 const getUserById = `-- name: GetUserById :one
-SELECT id, email, password_hash, name, email_verified, image, created_at, updated_at, region, city FROM users WHERE id = $1 LIMIT 1
+SELECT id, email, password_hash, name, email_verified, image, created_at, updated_at, region, city, role FROM users WHERE id = $1 LIMIT 1
 `
 
 func (q *Queries) GetUserById(ctx context.Context, id uuid.UUID) (User, error) {
@@ -263,12 +448,13 @@ func (q *Queries) GetUserById(ctx context.Context, id uuid.UUID) (User, error) {
 		&i.UpdatedAt,
 		&i.Region,
 		&i.City,
+		&i.Role,
 	)
 	return i, err
 }
 
 const getUserVerificationTokens = `-- name: GetUserVerificationTokens :many
-SELECT id, user_id, token, type, expires_at, created_at FROM verification_tokens
+SELECT id, user_id, token, type, expires_at, created_at, new_email FROM verification_tokens
 WHERE user_id = $1 AND type = 'email_verification'
 ORDER BY created_at DESC
 LIMIT 5
@@ -290,6 +476,47 @@ func (q *Queries) GetUserVerificationTokens(ctx context.Context, userID uuid.UUI
 			&i.Type,
 			&i.ExpiresAt,
 			&i.CreatedAt,
+			&i.NewEmail,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUserVerificationTokensByType = `-- name: GetUserVerificationTokensByType :many
+SELECT id, user_id, token, type, expires_at, created_at, new_email FROM verification_tokens
+WHERE user_id = $1 AND type = $2
+ORDER BY created_at DESC
+LIMIT 5
+`
+
+type GetUserVerificationTokensByTypeParams struct {
+	UserID uuid.UUID `json:"user_id"`
+	Type   string    `json:"type"`
+}
+
+func (q *Queries) GetUserVerificationTokensByType(ctx context.Context, arg GetUserVerificationTokensByTypeParams) ([]VerificationToken, error) {
+	rows, err := q.db.Query(ctx, getUserVerificationTokensByType, arg.UserID, arg.Type)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []VerificationToken
+	for rows.Next() {
+		var i VerificationToken
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Token,
+			&i.Type,
+			&i.ExpiresAt,
+			&i.CreatedAt,
+			&i.NewEmail,
 		); err != nil {
 			return nil, err
 		}
@@ -302,7 +529,7 @@ func (q *Queries) GetUserVerificationTokens(ctx context.Context, userID uuid.UUI
 }
 
 const getVerificationToken = `-- name: GetVerificationToken :one
-SELECT id, user_id, token, type, expires_at, created_at FROM verification_tokens WHERE token = $1 AND expires_at > CURRENT_TIMESTAMP LIMIT 1
+SELECT id, user_id, token, type, expires_at, created_at, new_email FROM verification_tokens WHERE token = $1 AND expires_at > CURRENT_TIMESTAMP LIMIT 1
 `
 
 func (q *Queries) GetVerificationToken(ctx context.Context, token string) (VerificationToken, error) {
@@ -315,10 +542,172 @@ func (q *Queries) GetVerificationToken(ctx context.Context, token string) (Verif
 		&i.Type,
 		&i.ExpiresAt,
 		&i.CreatedAt,
+		&i.NewEmail,
 	)
 	return i, err
 }
 
+const listActiveRefreshTokenFamilies = `-- name: ListActiveRefreshTokenFamilies :many
+SELECT f.id, f.user_id, f.user_agent, f.ip_address, f.created_at, f.last_used_at, f.revoked_at FROM refresh_token_families f
+WHERE f.user_id = $1
+  AND f.revoked_at IS NULL
+  AND EXISTS (
+    SELECT 1 FROM refresh_tokens rt
+    WHERE rt.family_id = f.id AND rt.revoked = FALSE AND rt.expires_at > CURRENT_TIMESTAMP
+  )
+ORDER BY f.last_used_at DESC
+`
+
+func (q *Queries) ListActiveRefreshTokenFamilies(ctx context.Context, userID uuid.UUID) ([]RefreshTokenFamily, error) {
+	rows, err := q.db.Query(ctx, listActiveRefreshTokenFamilies, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []RefreshTokenFamily
+	for rows.Next() {
+		var i RefreshTokenFamily
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.UserAgent,
+			&i.IpAddress,
+			&i.CreatedAt,
+			&i.LastUsedAt,
+			&i.RevokedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listOAuthAccountsAfter = `-- name: ListOAuthAccountsAfter :many
+SELECT id, user_id, provider, provider_user_id, access_token, refresh_token, expires_at, created_at FROM oauth_accounts WHERE id > $1 ORDER BY id LIMIT $2
+`
+
+type ListOAuthAccountsAfterParams struct {
+	ID    uuid.UUID `json:"id"`
+	Limit int32     `json:"limit"`
+}
+
+func (q *Queries) ListOAuthAccountsAfter(ctx context.Context, arg ListOAuthAccountsAfterParams) ([]OauthAccount, error) {
+	rows, err := q.db.Query(ctx, listOAuthAccountsAfter, arg.ID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []OauthAccount
+	for rows.Next() {
+		var i OauthAccount
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Provider,
+			&i.ProviderUserID,
+			&i.AccessToken,
+			&i.RefreshToken,
+			&i.ExpiresAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listOAuthAccountsByUserId = `-- name: ListOAuthAccountsByUserId :many
+SELECT id, user_id, provider, provider_user_id, access_token, refresh_token, expires_at, created_at FROM oauth_accounts WHERE user_id = $1 ORDER BY created_at ASC
+`
+
+func (q *Queries) ListOAuthAccountsByUserId(ctx context.Context, userID uuid.UUID) ([]OauthAccount, error) {
+	rows, err := q.db.Query(ctx, listOAuthAccountsByUserId, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []OauthAccount
+	for rows.Next() {
+		var i OauthAccount
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Provider,
+			&i.ProviderUserID,
+			&i.AccessToken,
+			&i.RefreshToken,
+			&i.ExpiresAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listStaleOAuthAccounts = `-- name: ListStaleOAuthAccounts :many
+SELECT id, user_id, provider, provider_user_id, access_token, refresh_token, expires_at, created_at FROM oauth_accounts
+WHERE refresh_token IS NOT NULL AND expires_at < $1
+ORDER BY expires_at DESC
+LIMIT $2
+`
+
+type ListStaleOAuthAccountsParams struct {
+	ExpiresAt pgtype.Timestamp `json:"expires_at"`
+	Limit     int32            `json:"limit"`
+}
+
+func (q *Queries) ListStaleOAuthAccounts(ctx context.Context, arg ListStaleOAuthAccountsParams) ([]OauthAccount, error) {
+	rows, err := q.db.Query(ctx, listStaleOAuthAccounts, arg.ExpiresAt, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []OauthAccount
+	for rows.Next() {
+		var i OauthAccount
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Provider,
+			&i.ProviderUserID,
+			&i.AccessToken,
+			&i.RefreshToken,
+			&i.ExpiresAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const revokeAllUserRefreshTokenFamilies = `-- name: RevokeAllUserRefreshTokenFamilies :exec
+UPDATE refresh_token_families SET revoked_at = CURRENT_TIMESTAMP
+WHERE user_id = $1 AND revoked_at IS NULL
+`
+
+func (q *Queries) RevokeAllUserRefreshTokenFamilies(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.Exec(ctx, revokeAllUserRefreshTokenFamilies, userID)
+	return err
+}
+
 const revokeAllUserRefreshTokens = `-- name: RevokeAllUserRefreshTokens :exec
 UPDATE refresh_tokens SET revoked = TRUE WHERE user_id = $1
 `
@@ -328,6 +717,21 @@ func (q *Queries) RevokeAllUserRefreshTokens(ctx context.Context, userID uuid.UU
 	return err
 }
 
+const revokeOtherUserRefreshTokenFamilies = `-- name: RevokeOtherUserRefreshTokenFamilies :exec
+UPDATE refresh_token_families SET revoked_at = CURRENT_TIMESTAMP
+WHERE user_id = $1 AND id <> $2 AND revoked_at IS NULL
+`
+
+type RevokeOtherUserRefreshTokenFamiliesParams struct {
+	UserID uuid.UUID `json:"user_id"`
+	ID     uuid.UUID `json:"id"`
+}
+
+func (q *Queries) RevokeOtherUserRefreshTokenFamilies(ctx context.Context, arg RevokeOtherUserRefreshTokenFamiliesParams) error {
+	_, err := q.db.Exec(ctx, revokeOtherUserRefreshTokenFamilies, arg.UserID, arg.ID)
+	return err
+}
+
 const revokeRefreshToken = `-- name: RevokeRefreshToken :exec
 UPDATE refresh_tokens SET revoked = TRUE WHERE token_hash = $1
 `
@@ -337,6 +741,51 @@ func (q *Queries) RevokeRefreshToken(ctx context.Context, tokenHash string) erro
 	return err
 }
 
+const revokeRefreshTokenFamily = `-- name: RevokeRefreshTokenFamily :exec
+UPDATE refresh_token_families SET revoked_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND revoked_at IS NULL
+`
+
+func (q *Queries) RevokeRefreshTokenFamily(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.Exec(ctx, revokeRefreshTokenFamily, id)
+	return err
+}
+
+const revokeUserRefreshTokenFamily = `-- name: RevokeUserRefreshTokenFamily :execrows
+UPDATE refresh_token_families SET revoked_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+`
+
+type RevokeUserRefreshTokenFamilyParams struct {
+	ID     uuid.UUID `json:"id"`
+	UserID uuid.UUID `json:"user_id"`
+}
+
+func (q *Queries) RevokeUserRefreshTokenFamily(ctx context.Context, arg RevokeUserRefreshTokenFamilyParams) (int64, error) {
+	result, err := q.db.Exec(ctx, revokeUserRefreshTokenFamily, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const touchRefreshTokenFamily = `-- name: TouchRefreshTokenFamily :exec
+UPDATE refresh_token_families
+SET last_used_at = CURRENT_TIMESTAMP, user_agent = $2, ip_address = $3
+WHERE id = $1
+`
+
+type TouchRefreshTokenFamilyParams struct {
+	ID        uuid.UUID   `json:"id"`
+	UserAgent pgtype.Text `json:"user_agent"`
+	IpAddress pgtype.Text `json:"ip_address"`
+}
+
+func (q *Queries) TouchRefreshTokenFamily(ctx context.Context, arg TouchRefreshTokenFamilyParams) error {
+	_, err := q.db.Exec(ctx, touchRefreshTokenFamily, arg.ID, arg.UserAgent, arg.IpAddress)
+	return err
+}
+
 const updateOAuthTokens = `-- name: UpdateOAuthTokens :exec
 UPDATE oauth_accounts SET access_token = $1, refresh_token = $2, expires_at = $3 WHERE id = $4
 `
@@ -358,6 +807,65 @@ func (q *Queries) UpdateOAuthTokens(ctx context.Context, arg UpdateOAuthTokensPa
 	return err
 }
 
+const updateOAuthTokensIfUnchanged = `-- name: UpdateOAuthTokensIfUnchanged :execrows
+UPDATE oauth_accounts
+SET access_token = $1, refresh_token = $2
+WHERE id = $3
+  AND access_token IS NOT DISTINCT FROM $4
+  AND refresh_token IS NOT DISTINCT FROM $5
+`
+
+type UpdateOAuthTokensIfUnchangedParams struct {
+	AccessToken     pgtype.Text `json:"access_token"`
+	RefreshToken    pgtype.Text `json:"refresh_token"`
+	ID              uuid.UUID   `json:"id"`
+	OldAccessToken  pgtype.Text `json:"old_access_token"`
+	OldRefreshToken pgtype.Text `json:"old_refresh_token"`
+}
+
+func (q *Queries) UpdateOAuthTokensIfUnchanged(ctx context.Context, arg UpdateOAuthTokensIfUnchangedParams) (int64, error) {
+	result, err := q.db.Exec(ctx, updateOAuthTokensIfUnchanged,
+		arg.AccessToken,
+		arg.RefreshToken,
+		arg.ID,
+		arg.OldAccessToken,
+		arg.OldRefreshToken,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const updateUserEmail = `-- name: UpdateUserEmail :one
+UPDATE users SET email = $1, email_verified = TRUE, updated_at = CURRENT_TIMESTAMP WHERE id = $2
+RETURNING id, email, password_hash, name, email_verified, image, created_at, updated_at, region, city, role
+`
+
+type UpdateUserEmailParams struct {
+	Email string    `json:"email"`
+	ID    uuid.UUID `json:"id"`
+}
+
+func (q *Queries) UpdateUserEmail(ctx context.Context, arg UpdateUserEmailParams) (User, error) {
+	row := q.db.QueryRow(ctx, updateUserEmail, arg.Email, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.PasswordHash,
+		&i.Name,
+		&i.EmailVerified,
+		&i.Image,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Region,
+		&i.City,
+		&i.Role,
+	)
+	return i, err
+}
+
 const updateUserEmailVerified = `-- name: UpdateUserEmailVerified :exec
 UPDATE users SET email_verified = TRUE, updated_at = CURRENT_TIMESTAMP WHERE id = $1
 `
@@ -423,3 +931,32 @@ func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPassword
 	_, err := q.db.Exec(ctx, updateUserPassword, arg.PasswordHash, arg.ID)
 	return err
 }
+
+const updateUserRole = `-- name: UpdateUserRole :one
+UPDATE users SET role = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2
+RETURNING id, email, password_hash, name, email_verified, image, created_at, updated_at, region, city, role
+`
+
+type UpdateUserRoleParams struct {
+	Role string    `json:"role"`
+	ID   uuid.UUID `json:"id"`
+}
+
+func (q *Queries) UpdateUserRole(ctx context.Context, arg UpdateUserRoleParams) (User, error) {
+	row := q.db.QueryRow(ctx, updateUserRole, arg.Role, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.PasswordHash,
+		&i.Name,
+		&i.EmailVerified,
+		&i.Image,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Region,
+		&i.City,
+		&i.Role,
+	)
+	return i, err
+}
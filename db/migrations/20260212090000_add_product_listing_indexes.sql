@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE INDEX idx_products_created_at_id ON products(created_at DESC, id DESC);
+CREATE INDEX idx_products_user_id ON products(user_id);
+CREATE INDEX idx_product_images_product_id ON product_images(product_id);
+CREATE INDEX idx_products_category_product_id ON products_category(product_id);
+CREATE INDEX idx_products_category_category_id ON products_category(category_id);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_products_category_category_id;
+DROP INDEX IF EXISTS idx_products_category_product_id;
+DROP INDEX IF EXISTS idx_product_images_product_id;
+DROP INDEX IF EXISTS idx_products_user_id;
+DROP INDEX IF EXISTS idx_products_created_at_id;
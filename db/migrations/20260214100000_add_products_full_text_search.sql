@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE EXTENSION IF NOT EXISTS unaccent;
+
+-- Spanish stemming with accent folding, so "camion" matches "camión"
+CREATE TEXT SEARCH CONFIGURATION spanish_unaccent (COPY = spanish);
+ALTER TEXT SEARCH CONFIGURATION spanish_unaccent
+    ALTER MAPPING FOR hword, hword_part, word WITH unaccent, spanish_stem;
+
+-- Name matches weigh more than description matches when ranking. The vector
+-- only lives in the index, so SELECT p.* and RETURNING * never read it.
+-- Queries match through products_search_vector(), which has to stay in sync
+-- with the index expression
+CREATE FUNCTION products_search_vector(name TEXT, description TEXT) RETURNS tsvector
+LANGUAGE SQL IMMUTABLE PARALLEL SAFE AS $$
+    SELECT setweight(to_tsvector('spanish_unaccent', coalesce(name, '')), 'A') ||
+        setweight(to_tsvector('spanish_unaccent', coalesce(description, '')), 'B')
+$$;
+
+CREATE INDEX idx_products_search_vector ON products USING GIN(products_search_vector(name, description));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_products_search_vector;
+DROP FUNCTION IF EXISTS products_search_vector(TEXT, TEXT);
+DROP TEXT SEARCH CONFIGURATION IF EXISTS spanish_unaccent;
+-- +goose StatementEnd
@@ -0,0 +1,31 @@
+-- +goose Up
+
+CREATE TABLE conversations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    buyer_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    seller_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    buyer_last_read_at TIMESTAMP,
+    seller_last_read_at TIMESTAMP,
+    last_message_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(product_id, buyer_id)
+);
+
+CREATE INDEX idx_conversations_buyer_id ON conversations(buyer_id, last_message_at DESC);
+CREATE INDEX idx_conversations_seller_id ON conversations(seller_id, last_message_at DESC);
+
+CREATE TABLE messages (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    conversation_id UUID NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+    sender_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    content TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_messages_conversation_id ON messages(conversation_id, created_at DESC, id DESC);
+
+-- +goose Down
+
+DROP TABLE IF EXISTS messages;
+DROP TABLE IF EXISTS conversations;
@@ -0,0 +1,31 @@
+-- +goose Up
+
+CREATE TABLE favorites (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, product_id)
+);
+
+CREATE INDEX idx_favorites_product_id ON favorites(product_id);
+CREATE INDEX idx_favorites_user_id ON favorites(user_id, created_at DESC);
+
+-- Pending price drop / sold notices, sent in batched digests by the favorites notifier
+CREATE TABLE favorite_notifications (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    kind TEXT NOT NULL CHECK (kind IN ('price_drop', 'sold')),
+    old_price BIGINT NOT NULL,
+    new_price BIGINT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    sent_at TIMESTAMP
+);
+
+CREATE INDEX idx_favorite_notifications_pending ON favorite_notifications(user_id) WHERE sent_at IS NULL;
+CREATE INDEX idx_favorite_notifications_sent ON favorite_notifications(user_id, sent_at DESC) WHERE sent_at IS NOT NULL;
+
+-- +goose Down
+
+DROP TABLE IF EXISTS favorite_notifications;
+DROP TABLE IF EXISTS favorites;
@@ -0,0 +1,35 @@
+-- +goose Up
+
+UPDATE products SET state = CASE state
+    WHEN 'Vendido' THEN 'sold'
+    WHEN 'Reservado' THEN 'reserved'
+    ELSE 'available'
+END;
+
+ALTER TABLE products ALTER COLUMN state SET DEFAULT 'available';
+ALTER TABLE products ADD CONSTRAINT products_state_check
+    CHECK (state IN ('draft', 'available', 'reserved', 'sold', 'paused', 'expired'));
+
+CREATE TABLE product_state_history (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    from_state TEXT,
+    to_state TEXT NOT NULL,
+    changed_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_product_state_history_product_id ON product_state_history(product_id, created_at DESC);
+
+-- +goose Down
+
+DROP TABLE IF EXISTS product_state_history;
+
+ALTER TABLE products DROP CONSTRAINT IF EXISTS products_state_check;
+ALTER TABLE products ALTER COLUMN state SET DEFAULT 'Disponible';
+
+UPDATE products SET state = CASE state
+    WHEN 'sold' THEN 'Vendido'
+    WHEN 'reserved' THEN 'Reservado'
+    ELSE 'Disponible'
+END;
@@ -0,0 +1,24 @@
+-- +goose Up
+
+-- Short-lived OAuth flow data, shared by every instance. Keys are stored hashed
+CREATE TABLE oauth_states (
+    state_hash TEXT PRIMARY KEY,
+    code_verifier TEXT NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE oauth_exchange_codes (
+    code_hash TEXT PRIMARY KEY,
+    payload JSONB NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_oauth_states_expires_at ON oauth_states(expires_at);
+CREATE INDEX idx_oauth_exchange_codes_expires_at ON oauth_exchange_codes(expires_at);
+
+-- +goose Down
+
+DROP TABLE IF EXISTS oauth_exchange_codes;
+DROP TABLE IF EXISTS oauth_states;
@@ -0,0 +1,35 @@
+-- +goose Up
+
+-- A token family is one signed-in session. Every rotated refresh token keeps
+-- the family of the token it replaced, so a rotated token coming back means
+-- the family was copied and the whole session gets revoked
+CREATE TABLE refresh_token_families (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    user_agent TEXT,
+    ip_address TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    revoked_at TIMESTAMP
+);
+
+CREATE INDEX idx_refresh_token_families_user_id ON refresh_token_families(user_id);
+
+ALTER TABLE refresh_tokens ADD COLUMN family_id UUID REFERENCES refresh_token_families(id) ON DELETE CASCADE;
+
+-- Existing tokens become a session of their own
+INSERT INTO refresh_token_families (id, user_id, created_at, last_used_at, revoked_at)
+SELECT id, user_id, COALESCE(created_at, CURRENT_TIMESTAMP), COALESCE(created_at, CURRENT_TIMESTAMP),
+       CASE WHEN revoked THEN CURRENT_TIMESTAMP END
+FROM refresh_tokens;
+
+UPDATE refresh_tokens SET family_id = id;
+
+ALTER TABLE refresh_tokens ALTER COLUMN family_id SET NOT NULL;
+
+CREATE INDEX idx_refresh_tokens_family_id ON refresh_tokens(family_id);
+
+-- +goose Down
+
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS family_id;
+DROP TABLE IF EXISTS refresh_token_families;
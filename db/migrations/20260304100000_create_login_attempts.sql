@@ -0,0 +1,20 @@
+-- +goose Up
+
+-- Every password sign-in attempt, kept for auditing and used to throttle by
+-- email and by IP. email is stored lowercased and may not belong to any user
+CREATE TABLE login_attempts (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    email TEXT NOT NULL,
+    ip_address TEXT NOT NULL,
+    user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    user_agent TEXT,
+    success BOOLEAN NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_login_attempts_email_created_at ON login_attempts(email, created_at);
+CREATE INDEX idx_login_attempts_ip_address_created_at ON login_attempts(ip_address, created_at);
+
+-- +goose Down
+
+DROP TABLE IF EXISTS login_attempts;
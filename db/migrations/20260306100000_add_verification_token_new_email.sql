@@ -0,0 +1,9 @@
+-- +goose Up
+
+-- Pending address of an 'email_change' token, the users row keeps the old
+-- email until the link sent to the new one is opened
+ALTER TABLE verification_tokens ADD COLUMN new_email VARCHAR(255);
+
+-- +goose Down
+
+ALTER TABLE verification_tokens DROP COLUMN IF EXISTS new_email;
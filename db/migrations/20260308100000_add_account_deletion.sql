@@ -0,0 +1,32 @@
+-- +goose Up
+
+-- Deleting a user keeps their comments on other listings, anonymized, so
+-- replies from other users stay in place
+ALTER TABLE comments ALTER COLUMN user_id DROP NOT NULL;
+ALTER TABLE comments DROP CONSTRAINT comments_user_id_fkey;
+ALTER TABLE comments ADD CONSTRAINT comments_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL;
+
+-- Listings go with the account, images and categories cascade from products
+ALTER TABLE products DROP CONSTRAINT products_user_id_fkey;
+ALTER TABLE products ADD CONSTRAINT products_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+
+-- Accounts waiting out the grace period before being deleted
+CREATE TABLE account_deletions (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    requested_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    scheduled_for TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_account_deletions_scheduled_for ON account_deletions(scheduled_for);
+
+-- +goose Down
+
+DROP TABLE IF EXISTS account_deletions;
+
+ALTER TABLE products DROP CONSTRAINT products_user_id_fkey;
+ALTER TABLE products ADD CONSTRAINT products_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id);
+
+DELETE FROM comments WHERE user_id IS NULL;
+ALTER TABLE comments DROP CONSTRAINT comments_user_id_fkey;
+ALTER TABLE comments ADD CONSTRAINT comments_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE comments ALTER COLUMN user_id SET NOT NULL;
@@ -0,0 +1,8 @@
+-- +goose Up
+
+-- Set when the flow links a provider to a signed-in user instead of signing in
+ALTER TABLE oauth_states ADD COLUMN link_user_id UUID REFERENCES users(id) ON DELETE CASCADE;
+
+-- +goose Down
+
+ALTER TABLE oauth_states DROP COLUMN IF EXISTS link_user_id;
@@ -0,0 +1,28 @@
+-- +goose Up
+
+-- Images are shown by position, the cover first. Existing images keep the
+-- order they were uploaded in and the first one becomes the cover
+ALTER TABLE product_images ADD COLUMN position INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE product_images ADD COLUMN is_cover BOOLEAN NOT NULL DEFAULT FALSE;
+
+UPDATE product_images pi
+SET position = o.position, is_cover = (o.position = 0)
+FROM (
+    SELECT id, ROW_NUMBER() OVER (PARTITION BY product_id ORDER BY created_at, id) - 1 AS position
+    FROM product_images
+) o
+WHERE pi.id = o.id;
+
+CREATE UNIQUE INDEX idx_product_images_cover ON product_images(product_id) WHERE is_cover;
+
+DROP INDEX IF EXISTS idx_product_images_product_id;
+CREATE INDEX idx_product_images_product_id_position ON product_images(product_id, position);
+
+-- +goose Down
+
+DROP INDEX IF EXISTS idx_product_images_product_id_position;
+CREATE INDEX idx_product_images_product_id ON product_images(product_id);
+DROP INDEX IF EXISTS idx_product_images_cover;
+
+ALTER TABLE product_images DROP COLUMN IF EXISTS is_cover;
+ALTER TABLE product_images DROP COLUMN IF EXISTS position;
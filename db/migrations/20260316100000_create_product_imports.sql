@@ -0,0 +1,27 @@
+-- +goose Up
+
+-- Bulk listing imports. The parsed rows are kept in items so a worker can pick
+-- the job up, and resume it from processed_rows if an instance stops midway
+CREATE TABLE product_imports (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status TEXT NOT NULL DEFAULT 'pending',
+    items JSONB NOT NULL,
+    total_rows INTEGER NOT NULL,
+    processed_rows INTEGER NOT NULL DEFAULT 0,
+    created_rows INTEGER NOT NULL DEFAULT 0,
+    failed_rows INTEGER NOT NULL DEFAULT 0,
+    row_errors JSONB NOT NULL DEFAULT '[]',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    started_at TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    finished_at TIMESTAMP,
+    CONSTRAINT chk_product_imports_status CHECK (status IN ('pending', 'running', 'completed'))
+);
+
+CREATE INDEX idx_product_imports_user_id ON product_imports(user_id);
+CREATE INDEX idx_product_imports_pending ON product_imports(created_at) WHERE status <> 'completed';
+
+-- +goose Down
+
+DROP TABLE IF EXISTS product_imports;
@@ -0,0 +1,13 @@
+-- +goose Up
+
+-- Exchange codes only point at the user, tokens are issued when the code is
+-- exchanged. Pending codes still carry tokens, so they are dropped
+DELETE FROM oauth_exchange_codes;
+ALTER TABLE oauth_exchange_codes DROP COLUMN payload;
+ALTER TABLE oauth_exchange_codes ADD COLUMN user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE;
+
+-- +goose Down
+
+DELETE FROM oauth_exchange_codes;
+ALTER TABLE oauth_exchange_codes DROP COLUMN user_id;
+ALTER TABLE oauth_exchange_codes ADD COLUMN payload JSONB NOT NULL;
@@ -0,0 +1,16 @@
+-- +goose Up
+
+-- Emails are stored and compared in lower case. Two accounts that only differ
+-- by case make the unique index fail and have to be merged by hand first
+UPDATE users SET email = LOWER(TRIM(email)) WHERE email <> LOWER(TRIM(email));
+UPDATE verification_tokens SET new_email = LOWER(TRIM(new_email)) WHERE new_email <> LOWER(TRIM(new_email));
+
+ALTER TABLE users DROP CONSTRAINT users_email_key;
+DROP INDEX idx_users_email;
+CREATE UNIQUE INDEX idx_users_email_lower ON users (LOWER(email));
+
+-- +goose Down
+
+DROP INDEX idx_users_email_lower;
+CREATE INDEX idx_users_email ON users(email);
+ALTER TABLE users ADD CONSTRAINT users_email_key UNIQUE (email);
@@ -0,0 +1,25 @@
+-- name: ScheduleAccountDeletion :one
+INSERT INTO account_deletions (user_id, scheduled_for)
+VALUES ($1, $2)
+ON CONFLICT (user_id) DO UPDATE
+SET requested_at = CURRENT_TIMESTAMP, scheduled_for = EXCLUDED.scheduled_for
+RETURNING *;
+
+-- name: GetAccountDeletion :one
+SELECT * FROM account_deletions WHERE user_id = $1 LIMIT 1;
+
+-- name: CancelAccountDeletion :execrows
+DELETE FROM account_deletions WHERE user_id = $1;
+
+-- name: ListDueAccountDeletions :many
+SELECT * FROM account_deletions WHERE scheduled_for <= CURRENT_TIMESTAMP
+ORDER BY scheduled_for ASC
+LIMIT $1;
+
+-- name: DeleteDueAccount :execrows
+DELETE FROM users u
+WHERE u.id = $1
+  AND EXISTS (
+    SELECT 1 FROM account_deletions d
+    WHERE d.user_id = u.id AND d.scheduled_for <= CURRENT_TIMESTAMP
+  );
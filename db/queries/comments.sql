@@ -7,12 +7,12 @@ SELECT
     c.content,
     c.created_at,
     c.updated_at,
-    u.name AS author_name,
+    COALESCE(u.name, 'Usuario eliminado')::text AS author_name,
     u.image AS author_image,
     (SELECT COUNT(*) FROM comment_votes cv WHERE cv.comment_id = c.id AND cv.vote_type = 'like')::bigint AS likes,
     (SELECT COUNT(*) FROM comment_votes cv WHERE cv.comment_id = c.id AND cv.vote_type = 'dislike')::bigint AS dislikes
 FROM comments c
-JOIN users u ON c.user_id = u.id
+LEFT JOIN users u ON c.user_id = u.id
 WHERE c.product_id = $1
 ORDER BY c.created_at ASC;
 
@@ -25,13 +25,13 @@ SELECT
     c.content,
     c.created_at,
     c.updated_at,
-    u.name AS author_name,
+    COALESCE(u.name, 'Usuario eliminado')::text AS author_name,
     u.image AS author_image,
     (SELECT COUNT(*) FROM comment_votes cv WHERE cv.comment_id = c.id AND cv.vote_type = 'like')::bigint AS likes,
     (SELECT COUNT(*) FROM comment_votes cv WHERE cv.comment_id = c.id AND cv.vote_type = 'dislike')::bigint AS dislikes,
     COALESCE((SELECT cv.vote_type FROM comment_votes cv WHERE cv.comment_id = c.id AND cv.user_id = $2), '')::text AS user_vote
 FROM comments c
-JOIN users u ON c.user_id = u.id
+LEFT JOIN users u ON c.user_id = u.id
 WHERE c.product_id = $1
 ORDER BY c.created_at ASC;
 
@@ -40,8 +40,9 @@ INSERT INTO comments (product_id, user_id, parent_id, content)
 VALUES ($1, $2, $3, $4)
 RETURNING *;
 
--- name: DeleteComment :exec
-DELETE FROM comments WHERE id = $1 AND user_id = $2;
+-- name: DeleteComment :one
+DELETE FROM comments WHERE id = $1 AND user_id = $2
+RETURNING product_id;
 
 -- name: UpsertCommentVote :one
 INSERT INTO comment_votes (comment_id, user_id, vote_type)
@@ -52,3 +53,18 @@ RETURNING *;
 
 -- name: DeleteCommentVote :exec
 DELETE FROM comment_votes WHERE comment_id = $1 AND user_id = $2;
+
+-- name: GetCommentVoteCounts :one
+SELECT
+    c.id,
+    c.product_id,
+    (SELECT COUNT(*) FROM comment_votes cv WHERE cv.comment_id = c.id AND cv.vote_type = 'like')::bigint AS likes,
+    (SELECT COUNT(*) FROM comment_votes cv WHERE cv.comment_id = c.id AND cv.vote_type = 'dislike')::bigint AS dislikes
+FROM comments c
+WHERE c.id = $1;
+
+-- name: GetCommentsByUserId :many
+SELECT * FROM comments WHERE user_id = $1 ORDER BY created_at ASC;
+
+-- name: GetCommentVotesByUserId :many
+SELECT * FROM comment_votes WHERE user_id = $1 ORDER BY created_at ASC;
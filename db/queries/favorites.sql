@@ -0,0 +1,57 @@
+-- name: AddFavorite :exec
+INSERT INTO favorites (user_id, product_id)
+VALUES ($1, $2)
+ON CONFLICT (user_id, product_id) DO NOTHING;
+
+-- name: RemoveFavorite :exec
+DELETE FROM favorites WHERE user_id = $1 AND product_id = $2;
+
+-- name: GetUserFavoriteProducts :many
+SELECT p.* FROM favorites f
+JOIN products p ON p.id = f.product_id
+WHERE f.user_id = $1
+ORDER BY f.created_at DESC;
+
+-- name: GetProductFavoriteCount :one
+SELECT COUNT(*) FROM favorites WHERE product_id = $1;
+
+-- name: GetFavoriteCountsByProductIds :many
+SELECT product_id, COUNT(*) AS favorite_count FROM favorites
+WHERE product_id = ANY(sqlc.arg('product_ids')::uuid[])
+GROUP BY product_id;
+
+-- name: ClaimFavoriteNotificationsByUser :many
+SELECT n.id, n.product_id, n.kind, n.old_price, n.new_price, n.created_at, p.name AS product_name
+FROM favorite_notifications n
+JOIN products p ON p.id = n.product_id
+WHERE n.user_id = sqlc.arg('user_id') AND n.sent_at IS NULL
+  AND NOT EXISTS (
+      SELECT 1 FROM favorite_notifications s
+      WHERE s.user_id = n.user_id AND s.sent_at > sqlc.arg('sent_since')
+  )
+ORDER BY n.created_at ASC
+FOR UPDATE OF n SKIP LOCKED;
+
+-- name: DeleteFavoriteNotifications :exec
+DELETE FROM favorite_notifications
+WHERE id = ANY(sqlc.arg('ids')::uuid[]);
+
+-- name: EnqueueFavoriteNotifications :exec
+INSERT INTO favorite_notifications (user_id, product_id, kind, old_price, new_price)
+SELECT f.user_id, f.product_id, sqlc.arg('kind')::text, sqlc.arg('old_price')::bigint, sqlc.arg('new_price')::bigint
+FROM favorites f
+WHERE f.product_id = sqlc.arg('product_id');
+
+-- name: GetUsersWithPendingFavoriteNotifications :many
+SELECT DISTINCT n.user_id FROM favorite_notifications n
+WHERE n.sent_at IS NULL
+  AND NOT EXISTS (
+      SELECT 1 FROM favorite_notifications s
+      WHERE s.user_id = n.user_id AND s.sent_at > sqlc.arg('sent_since')
+  )
+LIMIT sqlc.arg('max_users');
+
+
+-- name: MarkFavoriteNotificationsSent :exec
+UPDATE favorite_notifications SET sent_at = NOW()
+WHERE id = ANY(sqlc.arg('ids')::uuid[]);
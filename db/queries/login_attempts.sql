@@ -0,0 +1,29 @@
+-- name: CreateLoginAttempt :one
+INSERT INTO login_attempts (email, ip_address, user_id, user_agent, success)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING id;
+
+-- name: FinishLoginAttempt :exec
+UPDATE login_attempts SET user_id = $2, success = $3 WHERE id = $1;
+
+-- name: DeleteLoginAttempt :exec
+DELETE FROM login_attempts WHERE id = $1;
+
+-- name: GetEmailLoginFailures :one
+SELECT COUNT(*) AS failures, MAX(created_at)::timestamp AS last_failure_at
+FROM login_attempts la
+WHERE la.email = $1
+  AND la.success = FALSE
+  AND la.created_at > $2
+  AND la.created_at > COALESCE(
+    (SELECT MAX(s.created_at) FROM login_attempts s WHERE s.email = $1 AND s.success = TRUE),
+    '-infinity'::timestamp
+  );
+
+-- name: GetIPLoginFailures :one
+SELECT COUNT(*) AS failures, MAX(created_at)::timestamp AS last_failure_at
+FROM login_attempts
+WHERE ip_address = $1 AND success = FALSE AND created_at > $2;
+
+-- name: LockLoginEmail :exec
+SELECT pg_advisory_xact_lock(hashtext('login_attempts:' || sqlc.arg('email')::text));
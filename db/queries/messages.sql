@@ -0,0 +1,56 @@
+-- name: CreateConversation :one
+INSERT INTO conversations (product_id, buyer_id, seller_id)
+VALUES ($1, $2, $3)
+ON CONFLICT (product_id, buyer_id)
+DO UPDATE SET product_id = EXCLUDED.product_id
+RETURNING *;
+
+-- name: GetConversationById :one
+SELECT * FROM conversations WHERE id = $1 LIMIT 1;
+
+-- name: GetUserConversations :many
+SELECT
+    c.id,
+    c.product_id,
+    c.buyer_id,
+    c.seller_id,
+    c.last_message_at,
+    c.created_at,
+    p.name AS product_name,
+    u.id AS counterpart_id,
+    u.name AS counterpart_name,
+    u.image AS counterpart_image,
+    COALESCE((SELECT m.content FROM messages m WHERE m.conversation_id = c.id ORDER BY m.created_at DESC LIMIT 1), '')::text AS last_message,
+    (SELECT COUNT(*) FROM messages m
+        WHERE m.conversation_id = c.id
+          AND m.sender_id <> sqlc.arg('user_id')
+          AND m.created_at > COALESCE(
+              CASE WHEN c.buyer_id = sqlc.arg('user_id') THEN c.buyer_last_read_at ELSE c.seller_last_read_at END,
+              'epoch'::timestamp))::bigint AS unread_count
+FROM conversations c
+JOIN products p ON p.id = c.product_id
+JOIN users u ON u.id = CASE WHEN c.buyer_id = sqlc.arg('user_id') THEN c.seller_id ELSE c.buyer_id END
+WHERE c.buyer_id = sqlc.arg('user_id') OR c.seller_id = sqlc.arg('user_id')
+ORDER BY c.last_message_at DESC;
+
+-- name: GetConversationMessages :many
+SELECT * FROM messages
+WHERE conversation_id = sqlc.arg('conversation_id')
+  AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (created_at, id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid))
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('page_limit');
+
+-- name: CreateMessage :one
+INSERT INTO messages (conversation_id, sender_id, content)
+VALUES ($1, $2, $3)
+RETURNING *;
+
+-- name: UpdateConversationLastMessageAt :exec
+UPDATE conversations SET last_message_at = $1 WHERE id = $2;
+
+-- name: MarkConversationRead :exec
+UPDATE conversations
+SET buyer_last_read_at = CASE WHEN buyer_id = sqlc.arg('user_id') THEN NOW() ELSE buyer_last_read_at END,
+    seller_last_read_at = CASE WHEN seller_id = sqlc.arg('user_id') THEN NOW() ELSE seller_last_read_at END
+WHERE id = sqlc.arg('id');
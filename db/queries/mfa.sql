@@ -0,0 +1,35 @@
+-- name: UpsertPendingUserMFA :one
+INSERT INTO user_mfa (user_id, totp_secret)
+VALUES ($1, $2)
+ON CONFLICT (user_id) DO UPDATE
+SET totp_secret = EXCLUDED.totp_secret, last_used_step = 0, created_at = CURRENT_TIMESTAMP
+WHERE user_mfa.enabled_at IS NULL
+RETURNING *;
+
+-- name: GetUserMFA :one
+SELECT * FROM user_mfa WHERE user_id = $1 LIMIT 1;
+
+-- name: EnableUserMFA :execrows
+UPDATE user_mfa SET enabled_at = CURRENT_TIMESTAMP
+WHERE user_id = $1 AND enabled_at IS NULL;
+
+-- name: UseMFAStep :execrows
+UPDATE user_mfa SET last_used_step = $2
+WHERE user_id = $1 AND last_used_step < $2;
+
+-- name: DeleteUserMFA :exec
+DELETE FROM user_mfa WHERE user_id = $1;
+
+-- name: CreateMFARecoveryCode :exec
+INSERT INTO mfa_recovery_codes (user_id, code_hash)
+VALUES ($1, $2);
+
+-- name: DeleteUserMFARecoveryCodes :exec
+DELETE FROM mfa_recovery_codes WHERE user_id = $1;
+
+-- name: UseMFARecoveryCode :execrows
+UPDATE mfa_recovery_codes SET used_at = CURRENT_TIMESTAMP
+WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL;
+
+-- name: CountUnusedMFARecoveryCodes :one
+SELECT COUNT(*) FROM mfa_recovery_codes WHERE user_id = $1 AND used_at IS NULL;
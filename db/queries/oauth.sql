@@ -0,0 +1,23 @@
+-- name: CreateOAuthState :exec
+INSERT INTO oauth_states (state_hash, code_verifier, expires_at, link_user_id)
+VALUES ($1, $2, $3, $4);
+
+-- name: ConsumeOAuthState :one
+DELETE FROM oauth_states
+WHERE state_hash = $1 AND expires_at > NOW()
+RETURNING code_verifier, link_user_id;
+
+-- name: DeleteExpiredOAuthStates :exec
+DELETE FROM oauth_states WHERE expires_at <= NOW();
+
+-- name: CreateOAuthExchangeCode :exec
+INSERT INTO oauth_exchange_codes (code_hash, user_id, expires_at)
+VALUES ($1, $2, $3);
+
+-- name: ConsumeOAuthExchangeCode :one
+DELETE FROM oauth_exchange_codes
+WHERE code_hash = $1 AND expires_at > NOW()
+RETURNING user_id;
+
+-- name: DeleteExpiredOAuthExchangeCodes :exec
+DELETE FROM oauth_exchange_codes WHERE expires_at <= NOW();
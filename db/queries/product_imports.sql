@@ -0,0 +1,40 @@
+-- name: CreateProductImport :one
+INSERT INTO product_imports (user_id, items, total_rows)
+VALUES ($1, $2, $3)
+RETURNING id, user_id, status, total_rows, processed_rows, created_rows, failed_rows, row_errors, created_at, started_at, updated_at, finished_at;
+
+-- name: GetProductImportByUser :one
+SELECT id, user_id, status, total_rows, processed_rows, created_rows, failed_rows, row_errors, created_at, started_at, updated_at, finished_at
+FROM product_imports
+WHERE id = $1 AND user_id = $2;
+
+-- name: ClaimProductImport :one
+UPDATE product_imports
+SET status = 'running', started_at = COALESCE(started_at, CURRENT_TIMESTAMP), updated_at = CURRENT_TIMESTAMP
+WHERE id = (
+    SELECT id FROM product_imports
+    WHERE status = 'pending'
+       OR (status = 'running' AND updated_at < CURRENT_TIMESTAMP - INTERVAL '5 minutes')
+    ORDER BY created_at ASC
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: RecordProductImportRowCreated :execrows
+UPDATE product_imports
+SET processed_rows = processed_rows + 1, created_rows = created_rows + 1, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND processed_rows = $2;
+
+-- name: RecordProductImportRowFailed :execrows
+UPDATE product_imports
+SET processed_rows = processed_rows + 1,
+    failed_rows = failed_rows + 1,
+    row_errors = row_errors || sqlc.arg('row_error')::jsonb,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = sqlc.arg('id') AND processed_rows = sqlc.arg('processed_rows');
+
+-- name: FinishProductImport :exec
+UPDATE product_imports
+SET status = 'completed', finished_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1;
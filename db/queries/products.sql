@@ -1,17 +1,65 @@
--- name: GetProducts :many
-SELECT * FROM products p;
+-- name: ListProducts :many
+SELECT p.* FROM products p
+LEFT JOIN users u ON u.id = p.user_id
+WHERE (sqlc.narg('category_ids')::uuid[] IS NULL OR EXISTS (
+    SELECT 1 FROM products_category pc
+    WHERE pc.product_id = p.id AND pc.category_id = ANY(sqlc.narg('category_ids')::uuid[])
+  ))
+  AND (sqlc.narg('min_price')::bigint IS NULL OR p.price >= sqlc.narg('min_price'))
+  AND (sqlc.narg('max_price')::bigint IS NULL OR p.price <= sqlc.narg('max_price'))
+  AND (sqlc.narg('condition')::text IS NULL OR p.condition = sqlc.narg('condition'))
+  AND (sqlc.narg('state')::text IS NULL OR p.state = sqlc.narg('state'))
+  AND (sqlc.narg('negotiable')::text IS NULL OR p.negotiable = sqlc.narg('negotiable'))
+  AND (sqlc.narg('region')::text IS NULL OR u.region = sqlc.narg('region'))
+  AND (sqlc.narg('city')::text IS NULL OR u.city = sqlc.narg('city'))
+  AND (sqlc.narg('seller_id')::uuid IS NULL OR p.user_id = sqlc.narg('seller_id'))
+  AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (p.created_at, p.id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid))
+ORDER BY p.created_at DESC, p.id DESC
+LIMIT sqlc.arg('page_limit');
 
 -- name: SearchProducts :many
-SELECT * FROM products p
-WHERE p.name ILIKE '%' || $1 || '%'
-   OR p.description ILIKE '%' || $1 || '%';
+SELECT sqlc.embed(p),
+    ts_rank(products_search_vector(p.name, p.description), query)::real AS rank,
+    ts_headline('spanish_unaccent', p.name, query, 'StartSel=<mark>, StopSel=</mark>, HighlightAll=true')::text AS name_highlight,
+    ts_headline('spanish_unaccent', coalesce(p.description, ''), query, 'StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MaxWords=20, MinWords=8')::text AS description_highlight
+FROM products p
+CROSS JOIN to_tsquery('spanish_unaccent', sqlc.arg('query')) query
+LEFT JOIN users u ON u.id = p.user_id
+WHERE products_search_vector(p.name, p.description) @@ query
+  AND (sqlc.narg('category_ids')::uuid[] IS NULL OR EXISTS (
+    SELECT 1 FROM products_category pc
+    WHERE pc.product_id = p.id AND pc.category_id = ANY(sqlc.narg('category_ids')::uuid[])
+  ))
+  AND (sqlc.narg('min_price')::bigint IS NULL OR p.price >= sqlc.narg('min_price'))
+  AND (sqlc.narg('max_price')::bigint IS NULL OR p.price <= sqlc.narg('max_price'))
+  AND (sqlc.narg('condition')::text IS NULL OR p.condition = sqlc.narg('condition'))
+  AND (sqlc.narg('state')::text IS NULL OR p.state = sqlc.narg('state'))
+  AND (sqlc.narg('negotiable')::text IS NULL OR p.negotiable = sqlc.narg('negotiable'))
+  AND (sqlc.narg('region')::text IS NULL OR u.region = sqlc.narg('region'))
+  AND (sqlc.narg('city')::text IS NULL OR u.city = sqlc.narg('city'))
+ORDER BY rank DESC, p.created_at DESC, p.id DESC
+LIMIT sqlc.arg('page_limit') OFFSET sqlc.arg('page_offset');
 
--- name: GetProductsImages :many
-SELECT * FROM product_images; 
+-- name: SuggestProducts :many
+SELECT p.id, p.name,
+    ts_headline('spanish_unaccent', p.name, query, 'StartSel=<mark>, StopSel=</mark>, HighlightAll=true')::text AS name_highlight
+FROM products p
+CROSS JOIN to_tsquery('spanish_unaccent', sqlc.arg('query')) query
+WHERE products_search_vector(p.name, p.description) @@ query
+  AND p.state = 'available'
+ORDER BY ts_rank(products_search_vector(p.name, p.description), query) DESC, p.created_at DESC
+LIMIT sqlc.arg('page_limit');
 
--- name: GetProductsCategories :many
+-- name: GetProductsImagesByProductIds :many
+SELECT * FROM product_images
+WHERE product_id = ANY(sqlc.arg('product_ids')::uuid[])
+ORDER BY is_cover DESC, position ASC, created_at ASC;
+
+-- name: GetProductsCategoriesByProductIds :many
 SELECT c.id, pc.product_id, c.name FROM products_category pc
-JOIN categories c ON pc.category_id = c.id; 
+JOIN categories c ON pc.category_id = c.id
+WHERE pc.product_id = ANY(sqlc.arg('product_ids')::uuid[]);
 
 -- name: GetProductById :one
 SELECT * FROM products WHERE id = $1;
@@ -22,7 +70,13 @@ JOIN categories c ON pc.category_id = c.id
 WHERE product_id = $1;
 
 -- name: GetProductImagesById :many
-SELECT * FROM product_images WHERE product_id = $1;
+SELECT * FROM product_images WHERE product_id = $1
+ORDER BY is_cover DESC, position ASC, created_at ASC;
+
+-- name: LockProductImages :many
+SELECT * FROM product_images WHERE product_id = $1
+ORDER BY position ASC, created_at ASC
+FOR UPDATE;
 
 -- name: CreateProduct :one
 INSERT INTO products
@@ -46,8 +100,72 @@ RETURNING *;
 -- name: GetProductsByUserId :many
 SELECT * FROM products WHERE user_id = $1 ORDER BY created_at DESC;
 
+-- name: CountUserProductsByState :many
+SELECT state, COUNT(*) AS product_count FROM products
+WHERE user_id = $1
+GROUP BY state
+ORDER BY state;
+
 -- name: CreateProductImage :one
-INSERT INTO product_images (product_id, image_url) VALUES ($1, $2) RETURNING *;
+INSERT INTO product_images (product_id, image_url, position, is_cover) VALUES ($1, $2, $3, $4) RETURNING *;
+
+-- name: DeleteProductImage :one
+DELETE FROM product_images WHERE id = $1 AND product_id = $2
+RETURNING *;
+
+-- name: ClearProductImageCover :exec
+UPDATE product_images SET is_cover = FALSE WHERE product_id = $1 AND is_cover;
+
+-- name: ReorderProductImages :execrows
+UPDATE product_images pi
+SET position = o.position - 1, is_cover = (pi.id = sqlc.arg('cover_id')::uuid)
+FROM unnest(sqlc.arg('image_ids')::uuid[]) WITH ORDINALITY AS o(id, position)
+WHERE pi.id = o.id AND pi.product_id = sqlc.arg('product_id');
+
+-- name: CompactProductImagePositions :exec
+UPDATE product_images pi
+SET position = o.position
+FROM (
+    SELECT id, ROW_NUMBER() OVER (ORDER BY position, created_at) - 1 AS position
+    FROM product_images
+    WHERE product_id = $1
+) o
+WHERE pi.id = o.id AND pi.position <> o.position;
+
+-- name: EnsureProductImageCover :exec
+UPDATE product_images SET is_cover = TRUE
+WHERE id = (
+    SELECT id FROM product_images
+    WHERE product_id = $1
+    ORDER BY position ASC, created_at ASC
+    LIMIT 1
+)
+AND NOT EXISTS (SELECT 1 FROM product_images WHERE product_id = $1 AND is_cover);
 
 -- name: CreateProductCategory :one
 INSERT INTO products_category (product_id, category_id) VALUES ($1, $2) RETURNING *;
+
+-- name: UpdateProductState :one
+UPDATE products
+SET state = sqlc.arg('to_state'), updated_at = NOW()
+WHERE id = sqlc.arg('id') AND state = sqlc.arg('from_state')
+RETURNING *;
+
+-- name: CreateProductStateHistory :exec
+INSERT INTO product_state_history (product_id, from_state, to_state, changed_by)
+VALUES ($1, $2, $3, $4);
+
+-- name: ExpireStaleProducts :execrows
+WITH expired AS (
+    UPDATE products
+    SET state = 'expired', updated_at = NOW()
+    WHERE state = 'available' AND updated_at < sqlc.arg('updated_before')
+    RETURNING id
+)
+INSERT INTO product_state_history (product_id, from_state, to_state)
+SELECT id, 'available', 'expired' FROM expired;
+
+-- name: GetProductStateHistory :many
+SELECT * FROM product_state_history
+WHERE product_id = $1
+ORDER BY created_at DESC;
@@ -0,0 +1,42 @@
+-- name: UpsertReview :one
+INSERT INTO reviews (product_id, reviewer_id, seller_id, rating, content)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (product_id, reviewer_id)
+DO UPDATE SET rating = EXCLUDED.rating, content = EXCLUDED.content, updated_at = NOW()
+RETURNING *;
+
+-- name: GetSellerReviews :many
+SELECT
+    r.id,
+    r.product_id,
+    r.reviewer_id,
+    r.rating,
+    r.content,
+    r.created_at,
+    r.updated_at,
+    u.name AS reviewer_name,
+    u.image AS reviewer_image,
+    p.name AS product_name
+FROM reviews r
+JOIN users u ON u.id = r.reviewer_id
+LEFT JOIN products p ON p.id = r.product_id
+WHERE r.seller_id = sqlc.arg('seller_id')
+  AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (r.created_at, r.id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid))
+ORDER BY r.created_at DESC, r.id DESC
+LIMIT sqlc.arg('page_limit');
+
+-- name: GetSellerRatingSummary :one
+SELECT
+    COALESCE(ROUND(AVG(rating), 1), 0)::float8 AS average_rating,
+    COUNT(*) AS review_count
+FROM reviews
+WHERE seller_id = $1;
+
+-- name: HasTwoWayConversation :one
+SELECT EXISTS (
+    SELECT 1 FROM conversations c
+    WHERE c.product_id = $1 AND c.buyer_id = $2
+      AND EXISTS (SELECT 1 FROM messages m WHERE m.conversation_id = c.id AND m.sender_id = c.buyer_id)
+      AND EXISTS (SELECT 1 FROM messages m WHERE m.conversation_id = c.id AND m.sender_id = c.seller_id)
+)::boolean AS replied;
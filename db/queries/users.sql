@@ -7,7 +7,7 @@ RETURNING *;
 UPDATE users SET image = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2;
 
 -- name: GetUserByEmail :one
-SELECT * FROM users WHERE email = $1 LIMIT 1;
+SELECT * FROM users WHERE LOWER(email) = LOWER($1) LIMIT 1;
 
 -- name: GetUserById :one
 SELECT * FROM users WHERE id = $1 LIMIT 1;
@@ -19,12 +19,70 @@ UPDATE users SET email_verified = TRUE, updated_at = CURRENT_TIMESTAMP WHERE id
 UPDATE users SET password_hash = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2;
 
 -- name: CreateRefreshToken :one
-INSERT INTO refresh_tokens (user_id, token_hash, expires_at)
+INSERT INTO refresh_tokens (user_id, token_hash, expires_at, family_id)
+VALUES ($1, $2, $3, $4)
+RETURNING *;
+
+-- name: GetRotatedRefreshToken :one
+SELECT * FROM refresh_tokens rt
+WHERE rt.token_hash = $1
+  AND rt.revoked = TRUE
+  AND EXISTS (
+    SELECT 1 FROM refresh_tokens newer
+    WHERE newer.family_id = rt.family_id AND newer.id <> rt.id AND newer.created_at >= rt.created_at
+  )
+LIMIT 1;
+
+-- name: ConsumeRefreshToken :one
+UPDATE refresh_tokens rt SET revoked = TRUE
+FROM refresh_token_families f
+WHERE rt.token_hash = $1
+  AND rt.revoked = FALSE
+  AND rt.expires_at > CURRENT_TIMESTAMP
+  AND f.id = rt.family_id
+  AND f.revoked_at IS NULL
+RETURNING rt.*;
+
+-- name: CreateRefreshTokenFamily :one
+INSERT INTO refresh_token_families (user_id, user_agent, ip_address)
 VALUES ($1, $2, $3)
 RETURNING *;
 
--- name: GetRefreshToken :one
-SELECT * FROM refresh_tokens WHERE token_hash = $1 AND revoked = FALSE AND expires_at > CURRENT_TIMESTAMP LIMIT 1;
+-- name: GetRefreshTokenFamily :one
+SELECT * FROM refresh_token_families
+WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+LIMIT 1;
+
+-- name: TouchRefreshTokenFamily :exec
+UPDATE refresh_token_families
+SET last_used_at = CURRENT_TIMESTAMP, user_agent = $2, ip_address = $3
+WHERE id = $1;
+
+-- name: ListActiveRefreshTokenFamilies :many
+SELECT * FROM refresh_token_families f
+WHERE f.user_id = $1
+  AND f.revoked_at IS NULL
+  AND EXISTS (
+    SELECT 1 FROM refresh_tokens rt
+    WHERE rt.family_id = f.id AND rt.revoked = FALSE AND rt.expires_at > CURRENT_TIMESTAMP
+  )
+ORDER BY f.last_used_at DESC;
+
+-- name: RevokeRefreshTokenFamily :exec
+UPDATE refresh_token_families SET revoked_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND revoked_at IS NULL;
+
+-- name: RevokeUserRefreshTokenFamily :execrows
+UPDATE refresh_token_families SET revoked_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL;
+
+-- name: RevokeOtherUserRefreshTokenFamilies :exec
+UPDATE refresh_token_families SET revoked_at = CURRENT_TIMESTAMP
+WHERE user_id = $1 AND id <> $2 AND revoked_at IS NULL;
+
+-- name: RevokeAllUserRefreshTokenFamilies :exec
+UPDATE refresh_token_families SET revoked_at = CURRENT_TIMESTAMP
+WHERE user_id = $1 AND revoked_at IS NULL;
 
 -- name: RevokeRefreshToken :exec
 UPDATE refresh_tokens SET revoked = TRUE WHERE token_hash = $1;
@@ -60,11 +118,66 @@ SELECT * FROM oauth_accounts WHERE provider = $1 AND provider_user_id = $2 LIMIT
 -- name: GetOAuthAccountByUserId :one
 SELECT * FROM oauth_accounts WHERE user_id = $1 AND provider = $2 LIMIT 1;
 
+-- name: ListOAuthAccountsByUserId :many
+SELECT * FROM oauth_accounts WHERE user_id = $1 ORDER BY created_at ASC;
+
+-- name: ListStaleOAuthAccounts :many
+SELECT * FROM oauth_accounts
+WHERE refresh_token IS NOT NULL AND expires_at < $1
+ORDER BY expires_at DESC
+LIMIT $2;
+
+-- name: DeleteOAuthAccountKeepingSignIn :execrows
+-- Only deletes when the user can still sign in with a password or another provider
+DELETE FROM oauth_accounts oa
+WHERE oa.user_id = $1
+  AND oa.provider = $2
+  AND (
+    EXISTS (SELECT 1 FROM users u WHERE u.id = oa.user_id AND u.password_hash IS NOT NULL)
+    OR EXISTS (SELECT 1 FROM oauth_accounts o WHERE o.user_id = oa.user_id AND o.id <> oa.id)
+  );
+
+-- name: ListOAuthAccountsAfter :many
+SELECT * FROM oauth_accounts WHERE id > $1 ORDER BY id LIMIT $2;
+
 -- name: UpdateOAuthTokens :exec
 UPDATE oauth_accounts SET access_token = $1, refresh_token = $2, expires_at = $3 WHERE id = $4;
 
+-- name: UpdateOAuthTokensIfUnchanged :execrows
+UPDATE oauth_accounts
+SET access_token = sqlc.arg('access_token'), refresh_token = sqlc.arg('refresh_token')
+WHERE id = sqlc.arg('id')
+  AND access_token IS NOT DISTINCT FROM sqlc.arg('old_access_token')
+  AND refresh_token IS NOT DISTINCT FROM sqlc.arg('old_refresh_token');
+
 -- name: UpdateUserName :exec
 UPDATE users SET name = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2;
 
 -- name: UpdateUserLocation :exec
 UPDATE users SET region = $1, city = $2, updated_at = CURRENT_TIMESTAMP WHERE id = $3;
+
+-- name: UpdateUserRole :one
+UPDATE users SET role = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2
+RETURNING *;
+
+-- name: GetUserVerificationTokensByType :many
+SELECT * FROM verification_tokens
+WHERE user_id = $1 AND type = $2
+ORDER BY created_at DESC
+LIMIT 5;
+
+-- name: ConsumeVerificationToken :one
+DELETE FROM verification_tokens
+WHERE token = $1 AND type = $2 AND expires_at > CURRENT_TIMESTAMP
+RETURNING *;
+
+-- name: DeleteUserVerificationTokensByType :exec
+DELETE FROM verification_tokens WHERE user_id = $1 AND type = $2;
+
+-- name: CreateEmailChangeToken :exec
+INSERT INTO verification_tokens (user_id, token, type, expires_at, new_email)
+VALUES ($1, $2, $3, $4, $5);
+
+-- name: UpdateUserEmail :one
+UPDATE users SET email = $1, email_verified = TRUE, updated_at = CURRENT_TIMESTAMP WHERE id = $2
+RETURNING *;
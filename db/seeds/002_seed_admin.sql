@@ -0,0 +1,9 @@
+-- +goose Up
+-- Initial admin. It has no password: sign in with Google using this email,
+-- which links the OAuth account to this user.
+INSERT INTO "public"."users" ("email", "name", "email_verified", "role") VALUES
+('admin@trompeventas.cl', 'Administrador', TRUE, 'admin')
+ON CONFLICT ("email") DO UPDATE SET "role" = 'admin';
+
+-- +goose Down
+DELETE FROM users WHERE email = 'admin@trompeventas.cl';
@@ -1,15 +1,22 @@
 package main
 
 import (
+	"os"
+	"strings"
+
 	"restorapp/db"
 	"restorapp/modules/auth"
 	"restorapp/modules/categories"
 	"restorapp/modules/email"
 	"restorapp/modules/locations"
 	"restorapp/modules/comments"
+	"restorapp/modules/messages"
 	"restorapp/modules/products"
+	"restorapp/modules/realtime"
+	"restorapp/modules/reviews"
 	"restorapp/modules/storage"
 
+	"github.com/charmbracelet/log"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
@@ -20,21 +27,42 @@ func main() {
 	router.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"http://localhost:5173", "http://localhost:3000"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Cookie", "Authorization"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Cookie", "Authorization", "X-CSRF-Token"},
 		ExposeHeaders:    []string{"Content-Length"},
 		AllowCredentials: true,
 	}))
 
 	conn := db.InitDBClient()
 	defer conn.Close()
+
+	// X-Forwarded-For is only believed from the proxies in TRUSTED_PROXIES,
+	// otherwise the client IP is the connection address. Sign-in throttling
+	// and session records depend on it
+	var trustedProxies []string
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			trustedProxies = append(trustedProxies, proxy)
+		}
+	}
+	if err := router.SetTrustedProxies(trustedProxies); err != nil {
+		log.Fatal("Invalid TRUSTED_PROXIES", "error", err)
+	}
 	email.InitResendClient()
 	storage.InitStorage()
+	realtime.InitRealtime(conn)
 
 	auth.InitAuth(router)
+	products.StartFavoritesNotifier()
+	products.StartProductImportWorker()
+	products.StartProductExpiryWorker()
+	auth.StartAccountDeletionWorker()
+	auth.StartOAuthTokenRefresher()
 
 	products.ProductsController(router)
 	categories.CategoriesController(router)
 	comments.CommentsController(router)
+	messages.MessagesController(router)
+	reviews.ReviewsController(router)
 	locations.LocationsController(router)
 	storage.StorageController(router)
 
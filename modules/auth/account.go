@@ -0,0 +1,279 @@
+package auth
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+
+	"restorapp/db"
+	"restorapp/db/client"
+	"restorapp/modules/email"
+
+	"github.com/charmbracelet/log"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	accountDeletionGracePeriod = 14 * 24 * time.Hour
+	// Accounts without a password confirm sensitive changes by having signed in this recently
+	reauthWindow = 10 * time.Minute
+
+	accountDeletionWorkerInterval = time.Hour
+	// Accounts deleted per tick, the rest wait for the next tick
+	accountDeletionBatchSize = 50
+)
+
+var ErrReauthRequired = errors.New("recent sign in required")
+
+// RequestAccountDeletion schedules the account for deletion after the grace
+// period, signs the user out everywhere and revokes their API keys. Signing in
+// again before then cancels the deletion, the keys stay revoked
+func (s *AuthService) RequestAccountDeletion(ctx context.Context, userID uuid.UUID, sessionID string, session SessionInfo, password string) (time.Time, error) {
+	user, err := s.queries.GetUserById(ctx, userID)
+	if err != nil {
+		return time.Time{}, ErrUserNotFound
+	}
+
+	if user.PasswordHash.Valid {
+		if err := s.checkAccountPassword(ctx, user, password, session); err != nil {
+			return time.Time{}, err
+		}
+	} else if !s.isRecentSession(ctx, userID, sessionID) {
+		return time.Time{}, ErrReauthRequired
+	}
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer tx.Rollback(ctx)
+	qtx := s.queries.WithTx(tx)
+
+	deletion, err := qtx.ScheduleAccountDeletion(ctx, client.ScheduleAccountDeletionParams{
+		UserID:       userID,
+		ScheduledFor: pgtype.Timestamp{Time: time.Now().Add(accountDeletionGracePeriod), Valid: true},
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if err := qtx.RevokeAllUserRefreshTokens(ctx, userID); err != nil {
+		return time.Time{}, err
+	}
+	if err := qtx.RevokeAllUserRefreshTokenFamilies(ctx, userID); err != nil {
+		return time.Time{}, err
+	}
+	if err := qtx.RevokeAllUserAPIKeys(ctx, userID); err != nil {
+		return time.Time{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return time.Time{}, err
+	}
+
+	signInURL := AppConfig.FrontendURL + "/sign-in"
+	deletionDate := deletion.ScheduledFor.Time.Format("02/01/2006")
+	if err := email.SendAccountDeletionEmail(user.Email, user.Name, deletionDate, signInURL); err != nil {
+		log.Error("Failed to send account deletion email", "error", err)
+	}
+
+	return deletion.ScheduledFor.Time, nil
+}
+
+// isRecentSession reports whether the session the request was made with was
+// opened by a sign in within the re-authentication window
+func (s *AuthService) isRecentSession(ctx context.Context, userID uuid.UUID, sessionID string) bool {
+	familyID, err := uuid.Parse(sessionID)
+	if err != nil {
+		return false
+	}
+
+	family, err := s.queries.GetRefreshTokenFamily(ctx, client.GetRefreshTokenFamilyParams{
+		ID:     familyID,
+		UserID: userID,
+	})
+	if err != nil {
+		return false
+	}
+
+	return time.Since(family.CreatedAt.Time) <= reauthWindow
+}
+
+// StartAccountDeletionWorker deletes accounts whose grace period is over in
+// the background. Products, images, tokens and OAuth links go with the user
+// through the foreign key cascades, comments are kept without an author
+func StartAccountDeletionWorker() {
+	go func() {
+		ticker := time.NewTicker(accountDeletionWorkerInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			deleteDueAccounts(context.Background())
+		}
+	}()
+	log.Info("Account deletion worker started")
+}
+
+func deleteDueAccounts(ctx context.Context) {
+	deletions, err := db.Queries.ListDueAccountDeletions(ctx, accountDeletionBatchSize)
+	if err != nil {
+		log.Error("Failed to list due account deletions", "error", err)
+		return
+	}
+
+	for _, deletion := range deletions {
+		// Only deletes if the user did not sign in since the deletion was listed
+		rows, err := db.Queries.DeleteDueAccount(ctx, deletion.UserID)
+		if err != nil {
+			log.Error("Failed to delete account", "user", deletion.UserID, "error", err)
+			continue
+		}
+		if rows > 0 {
+			log.Info("Account deleted", "user", deletion.UserID)
+		}
+	}
+}
+
+// ExportAccount gathers the profile, listings, comments and votes of a user
+func (s *AuthService) ExportAccount(ctx context.Context, userID uuid.UUID) (*AccountExport, error) {
+	user, err := s.queries.GetUserById(ctx, userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	products, err := s.exportProducts(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	comments, err := s.queries.GetCommentsByUserId(ctx, pgtype.UUID{Bytes: userID, Valid: true})
+	if err != nil {
+		return nil, err
+	}
+
+	exportedComments := make([]ExportedComment, 0, len(comments))
+	for _, comment := range comments {
+		var parentID *uuid.UUID
+		if comment.ParentID.Valid {
+			id := uuid.UUID(comment.ParentID.Bytes)
+			parentID = &id
+		}
+
+		exportedComments = append(exportedComments, ExportedComment{
+			ID:        comment.ID,
+			ProductID: comment.ProductID,
+			ParentID:  parentID,
+			Content:   comment.Content,
+			CreatedAt: comment.CreatedAt.Time,
+			UpdatedAt: comment.UpdatedAt.Time,
+		})
+	}
+
+	votes, err := s.queries.GetCommentVotesByUserId(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	exportedVotes := make([]ExportedVote, 0, len(votes))
+	for _, vote := range votes {
+		exportedVotes = append(exportedVotes, ExportedVote{
+			CommentID: vote.CommentID,
+			VoteType:  vote.VoteType,
+			CreatedAt: vote.CreatedAt.Time,
+		})
+	}
+
+	return &AccountExport{
+		ExportedAt: time.Now(),
+		Profile:    userToResponse(user),
+		Products:   products,
+		Comments:   exportedComments,
+		Votes:      exportedVotes,
+	}, nil
+}
+
+func (s *AuthService) exportProducts(ctx context.Context, userID uuid.UUID) ([]ExportedProduct, error) {
+	products, err := s.queries.GetProductsByUserId(ctx, pgtype.UUID{Bytes: userID, Valid: true})
+	if err != nil {
+		return nil, err
+	}
+
+	exported := make([]ExportedProduct, 0, len(products))
+	if len(products) == 0 {
+		return exported, nil
+	}
+
+	productIDs := make([]uuid.UUID, 0, len(products))
+	for _, product := range products {
+		productIDs = append(productIDs, product.ID)
+	}
+
+	images, err := s.queries.GetProductsImagesByProductIds(ctx, productIDs)
+	if err != nil {
+		return nil, err
+	}
+	imagesByProduct := make(map[uuid.UUID][]string)
+	for _, image := range images {
+		imagesByProduct[image.ProductID] = append(imagesByProduct[image.ProductID], image.ImageUrl)
+	}
+
+	categories, err := s.queries.GetProductsCategoriesByProductIds(ctx, productIDs)
+	if err != nil {
+		return nil, err
+	}
+	categoriesByProduct := make(map[uuid.UUID][]string)
+	for _, category := range categories {
+		categoriesByProduct[category.ProductID] = append(categoriesByProduct[category.ProductID], category.Name)
+	}
+
+	for _, product := range products {
+		exported = append(exported, ExportedProduct{
+			ID:          product.ID,
+			Name:        product.Name,
+			Description: product.Description.String,
+			Price:       product.Price,
+			Condition:   product.Condition,
+			State:       product.State,
+			Negotiable:  product.Negotiable,
+			Categories:  categoriesByProduct[product.ID],
+			Images:      imagesByProduct[product.ID],
+			CreatedAt:   product.CreatedAt.Time,
+			UpdatedAt:   product.UpdatedAt.Time,
+		})
+	}
+
+	return exported, nil
+}
+
+// writeAccountExportZip writes one JSON file per section of the export
+func writeAccountExportZip(w io.Writer, export *AccountExport) error {
+	archive := zip.NewWriter(w)
+
+	files := []struct {
+		name string
+		data any
+	}{
+		{"profile.json", export.Profile},
+		{"products.json", export.Products},
+		{"comments.json", export.Comments},
+		{"votes.json", export.Votes},
+	}
+	for _, file := range files {
+		f, err := archive.Create(file.name)
+		if err != nil {
+			return err
+		}
+
+		encoder := json.NewEncoder(f)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(file.data); err != nil {
+			return err
+		}
+	}
+
+	return archive.Close()
+}
@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"restorapp/db/client"
+
+	"github.com/charmbracelet/log"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// API key scopes, each route accepting API keys names the ones it needs
+const (
+	ScopeProductsRead  = "products:read"
+	ScopeProductsWrite = "products:write"
+	ScopeCommentsWrite = "comments:write"
+)
+
+const (
+	apiKeyAuthScheme = "ApiKey "
+	apiKeyPrefix     = "tv_"
+	// Kept in the clear so users can tell their keys apart
+	apiKeyVisibleLength = len(apiKeyPrefix) + 8
+	maxAPIKeysPerUser   = 20
+)
+
+var (
+	ErrInvalidAPIKey  = errors.New("invalid api key")
+	ErrAPIKeyExpired  = errors.New("api key expired")
+	ErrAPIKeyNotFound = errors.New("api key not found")
+	ErrTooManyAPIKeys = errors.New("too many api keys")
+)
+
+// CreateAPIKey returns the new key in full. Only its hash is stored, so this
+// is the only time it can be shown
+func (s *AuthService) CreateAPIKey(ctx context.Context, userID uuid.UUID, req CreateAPIKeyRequest) (*CreatedAPIKeyResponse, error) {
+	count, err := s.queries.CountActiveAPIKeysByUserId(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if count >= maxAPIKeysPerUser {
+		return nil, ErrTooManyAPIKeys
+	}
+
+	scopes := slices.Clone(req.Scopes)
+	slices.Sort(scopes)
+	scopes = slices.Compact(scopes)
+
+	expiresAt := pgtype.Timestamp{}
+	if req.ExpiresInDays != nil {
+		expiresAt = pgtype.Timestamp{Time: time.Now().AddDate(0, 0, *req.ExpiresInDays), Valid: true}
+	}
+
+	key := apiKeyPrefix + GenerateSecureToken()
+	apiKey, err := s.queries.CreateAPIKey(ctx, client.CreateAPIKeyParams{
+		UserID:    userID,
+		Name:      req.Name,
+		Prefix:    key[:apiKeyVisibleLength],
+		KeyHash:   HashToken(key),
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &CreatedAPIKeyResponse{
+		APIKeyResponse: apiKeyToResponse(apiKey),
+		Key:            key,
+	}, nil
+}
+
+func (s *AuthService) ListAPIKeys(ctx context.Context, userID uuid.UUID) ([]APIKeyResponse, error) {
+	apiKeys, err := s.queries.ListAPIKeysByUserId(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]APIKeyResponse, 0, len(apiKeys))
+	for _, apiKey := range apiKeys {
+		responses = append(responses, apiKeyToResponse(apiKey))
+	}
+	return responses, nil
+}
+
+func (s *AuthService) RevokeAPIKey(ctx context.Context, userID uuid.UUID, keyID uuid.UUID) error {
+	rows, err := s.queries.RevokeAPIKey(ctx, client.RevokeAPIKeyParams{
+		ID:     keyID,
+		UserID: userID,
+	})
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// AuthenticateAPIKey looks up the owner of a key and records its use
+func (s *AuthService) AuthenticateAPIKey(ctx context.Context, key string) (*client.GetAPIKeyForAuthRow, error) {
+	apiKey, err := s.queries.GetAPIKeyForAuth(ctx, HashToken(key))
+	if err != nil {
+		return nil, ErrInvalidAPIKey
+	}
+
+	if apiKey.ExpiresAt.Valid && time.Now().After(apiKey.ExpiresAt.Time) {
+		return nil, ErrAPIKeyExpired
+	}
+
+	// Only written once a minute, busy scripts would otherwise update the row on every request
+	if err := s.queries.TouchAPIKey(ctx, apiKey.ID); err != nil {
+		log.Error("Failed to record api key use", "key", apiKey.ID, "error", err)
+	}
+
+	return &apiKey, nil
+}
+
+func apiKeyToResponse(apiKey client.ApiKey) APIKeyResponse {
+	response := APIKeyResponse{
+		ID:        apiKey.ID,
+		Name:      apiKey.Name,
+		Prefix:    apiKey.Prefix,
+		Scopes:    apiKey.Scopes,
+		CreatedAt: apiKey.CreatedAt.Time,
+	}
+	if apiKey.ExpiresAt.Valid {
+		response.ExpiresAt = &apiKey.ExpiresAt.Time
+	}
+	if apiKey.LastUsedAt.Valid {
+		response.LastUsedAt = &apiKey.LastUsedAt.Time
+	}
+	return response
+}
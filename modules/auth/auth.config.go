@@ -2,20 +2,33 @@ package auth
 
 import (
 	"log"
+	"net/http"
 	"os"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	JWTSecret            string
-	FrontendURL          string
-	BackendURL           string
-	GoogleClientID       string
-	GoogleClientSecret   string
-	GoogleRedirectURL    string
-	AccessTokenDuration  int // minutes
-	RefreshTokenDuration int // days
+	JWTKeysDir            string
+	JWTActiveKeyID        string
+	JWTEphemeralKey       bool
+	JWTIssuer             string
+	JWTAudience           string
+	FrontendURL           string
+	BackendURL            string
+	GoogleClientID        string
+	GoogleClientSecret    string
+	GitHubClientID        string
+	GitHubClientSecret    string
+	OAuthTokenKeys        string
+	OAuthTokenActiveKeyID string
+	CookieMode            bool
+	CookieDomain          string
+	CookieSecure          bool
+	CookieSameSite        http.SameSite
+	AccessTokenDuration   int // minutes
+	RefreshTokenDuration  int // days
 }
 
 var AppConfig *Config
@@ -24,24 +37,54 @@ func LoadConfig() {
 	godotenv.Load()
 
 	AppConfig = &Config{
-		JWTSecret:            os.Getenv("JWT_SECRET"),
-		FrontendURL:          getEnvOrDefault("FRONTEND_URL", "http://localhost:5173"),
-		BackendURL:           getEnvOrDefault("BACKEND_URL", "http://localhost:8080"),
-		GoogleClientID:       os.Getenv("GOOGLE_CLIENT_ID"),
-		GoogleClientSecret:   os.Getenv("GOOGLE_CLIENT_SECRET"),
-		AccessTokenDuration:  15,
-		RefreshTokenDuration: 7,
+		JWTKeysDir:            os.Getenv("JWT_KEYS_DIR"),
+		JWTActiveKeyID:        os.Getenv("JWT_ACTIVE_KID"),
+		JWTEphemeralKey:       os.Getenv("JWT_EPHEMERAL_KEY") == "true",
+		JWTAudience:           getEnvOrDefault("JWT_AUDIENCE", "trompeventas-api"),
+		FrontendURL:           getEnvOrDefault("FRONTEND_URL", "http://localhost:5173"),
+		BackendURL:            getEnvOrDefault("BACKEND_URL", "http://localhost:8080"),
+		GoogleClientID:        os.Getenv("GOOGLE_CLIENT_ID"),
+		GoogleClientSecret:    os.Getenv("GOOGLE_CLIENT_SECRET"),
+		GitHubClientID:        os.Getenv("GITHUB_CLIENT_ID"),
+		GitHubClientSecret:    os.Getenv("GITHUB_CLIENT_SECRET"),
+		OAuthTokenKeys:        os.Getenv("OAUTH_TOKEN_KEYS"),
+		OAuthTokenActiveKeyID: os.Getenv("OAUTH_TOKEN_ACTIVE_KID"),
+		CookieMode:            os.Getenv("AUTH_COOKIE_MODE") == "true",
+		CookieDomain:          os.Getenv("COOKIE_DOMAIN"),
+		CookieSecure:          os.Getenv("COOKIE_SECURE") != "false",
+		CookieSameSite:        parseSameSite(os.Getenv("COOKIE_SAMESITE")),
+		AccessTokenDuration:   15,
+		RefreshTokenDuration:  7,
 	}
 
-	AppConfig.GoogleRedirectURL = AppConfig.BackendURL + "/auth/oauth/google/callback"
+	AppConfig.JWTIssuer = getEnvOrDefault("JWT_ISSUER", AppConfig.BackendURL)
 
-	if AppConfig.JWTSecret == "" {
-		log.Fatal("JWT_SECRET environment variable is required")
+	if AppConfig.JWTKeysDir != "" && AppConfig.JWTActiveKeyID == "" {
+		log.Fatal("JWT_ACTIVE_KID environment variable is required when JWT_KEYS_DIR is set")
+	}
+
+	if AppConfig.CookieMode && AppConfig.CookieSameSite == http.SameSiteNoneMode && !AppConfig.CookieSecure {
+		log.Fatal("COOKIE_SAMESITE=none requires secure cookies, unset COOKIE_SECURE=false")
 	}
 
 	if AppConfig.GoogleClientID == "" {
 		log.Println("WARNING: GOOGLE_CLIENT_ID not set, Google OAuth will not work")
 	}
+
+	if AppConfig.GitHubClientID == "" {
+		log.Println("WARNING: GITHUB_CLIENT_ID not set, GitHub OAuth will not work")
+	}
+}
+
+func parseSameSite(value string) http.SameSite {
+	switch strings.ToLower(value) {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteLaxMode
+	}
 }
 
 func getEnvOrDefault(key, defaultValue string) string {
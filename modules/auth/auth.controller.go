@@ -1,43 +1,84 @@
 package auth
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/base64"
+	"io"
+	"math"
 	"net/http"
-	"sync"
+	"strconv"
 	"time"
 
+	"restorapp/db"
+
+	"github.com/charmbracelet/log"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"golang.org/x/oauth2"
 )
 
 var authService *AuthService
 
-// In-memory stores for OAuth state and one-time exchange codes
-var (
-	oauthExchangeCodes = make(map[string]*oauthExchangeEntry)
-	oauthStates        = make(map[string]time.Time) // state → expiry
-	oauthMu            sync.Mutex
-)
-
 func InitAuth(router *gin.Engine) {
 	LoadConfig()
-	InitGoogleOAuth()
+	initTokenKeys()
+	initOAuthTokenCipher()
+	InitOAuthProviders()
 	authService = NewAuthService()
+	oauthStore = NewPostgresOAuthStore(db.Queries)
+	startOAuthStoreSweeper(context.Background(), oauthStore, oauthSweepInterval)
+
+	// Only enforced on writes authenticated by cookie
+	router.Use(CSRFMiddleware())
 
 	auth := router.Group("/auth")
 	{
+		auth.GET("/csrf", handleGetCSRFToken)
 		auth.POST("/sign-up", handleSignUp)
 		auth.POST("/sign-in", handleSignIn)
 		auth.POST("/sign-out", handleSignOut)
 		auth.POST("/refresh", handleRefresh)
+		auth.POST("/magic-link", handleRequestMagicLink)
+		auth.POST("/magic-link/verify", handleVerifyMagicLink)
+		auth.POST("/mfa/verify", handleVerifyMFA)
+		auth.GET("/mfa", AuthMiddleware(), handleGetMFAStatus)
+		auth.POST("/mfa/setup", AuthMiddleware(), handleSetupMFA)
+		auth.POST("/mfa/enable", AuthMiddleware(), handleEnableMFA)
+		auth.POST("/mfa/disable", AuthMiddleware(), handleDisableMFA)
+		auth.POST("/mfa/recovery-codes", AuthMiddleware(), handleRegenerateRecoveryCodes)
+		auth.GET("/sessions", AuthMiddleware(), handleListSessions)
+		auth.DELETE("/sessions", AuthMiddleware(), handleRevokeAllSessions)
+		auth.DELETE("/sessions/:id", AuthMiddleware(), handleRevokeSession)
 		auth.GET("/me", AuthMiddleware(), handleGetCurrentUser)
 		auth.PUT("/me", AuthMiddleware(), handleUpdateProfile)
+		auth.DELETE("/me", AuthMiddleware(), handleDeleteAccount)
+		auth.GET("/me/export", AuthMiddleware(), handleExportAccount)
+		auth.POST("/me/email", AuthMiddleware(), handleRequestEmailChange)
+		auth.POST("/me/password", AuthMiddleware(), handleSetPassword)
+		auth.GET("/me/identities", AuthMiddleware(), handleListIdentities)
+		auth.POST("/me/identities/:provider", AuthMiddleware(), handleLinkIdentity)
+		auth.DELETE("/me/identities/:provider", AuthMiddleware(), handleUnlinkIdentity)
+		auth.GET("/me/api-keys", AuthMiddleware(), handleListAPIKeys)
+		auth.POST("/me/api-keys", AuthMiddleware(), handleCreateAPIKey)
+		auth.DELETE("/me/api-keys/:id", AuthMiddleware(), handleRevokeAPIKey)
+		auth.POST("/confirm-email-change", handleConfirmEmailChange)
 		auth.POST("/send-verification", AuthMiddleware(), handleSendVerification)
 		auth.GET("/verify-email", handleVerifyEmail)
-		auth.GET("/oauth/google", handleGoogleOAuthURL)
-		auth.GET("/oauth/google/callback", handleGoogleOAuthCallback)
-		auth.POST("/oauth/google/exchange", handleOAuthExchange)
+		auth.POST("/forgot-password", handleForgotPassword)
+		auth.POST("/reset-password", handleResetPassword)
+		auth.GET("/oauth/:provider", handleOAuthURL)
+		auth.GET("/oauth/:provider/callback", handleOAuthCallback)
+		auth.POST("/oauth/:provider/exchange", handleOAuthExchange)
+	}
+
+	// Public keys for services that verify our access tokens
+	router.GET("/.well-known/jwks.json", handleJWKS)
+
+	admin := router.Group("/admin")
+	admin.Use(AuthMiddleware(), RequireRole(RoleAdmin))
+	{
+		admin.PUT("/users/:id/role", handleUpdateUserRole)
 	}
 }
 
@@ -79,8 +120,12 @@ func handleSignIn(c *gin.Context) {
 		return
 	}
 
-	user, accessToken, refreshToken, err := authService.SignIn(c.Request.Context(), req)
+	result, err := authService.SignIn(c.Request.Context(), req, sessionInfoFromRequest(c))
 	if err != nil {
+		if err == ErrTooManyAttempts {
+			respondTooManyAttempts(c, result.RetryAfter)
+			return
+		}
 		if err == ErrInvalidCredentials {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 			return
@@ -89,46 +134,148 @@ func handleSignIn(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"user":         user,
-		"accessToken":  accessToken,
-		"refreshToken": refreshToken,
-	})
+	respondWithSignIn(c, result)
+}
+
+func respondTooManyAttempts(c *gin.Context, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many sign in attempts, try again later", "retryAfter": seconds})
+}
+
+// respondWithSignIn sends the tokens, or the MFA challenge when a second step
+// is needed. That step happens on /auth/mfa/verify
+func respondWithSignIn(c *gin.Context, result *SignInResult) {
+	if result.MFAToken != "" {
+		c.JSON(http.StatusOK, gin.H{
+			"mfaRequired": true,
+			"mfaToken":    result.MFAToken,
+		})
+		return
+	}
+
+	respondWithTokens(c, http.StatusOK, gin.H{"user": result.User}, result.AccessToken, result.RefreshToken)
+}
+
+func handleRequestMagicLink(c *gin.Context) {
+	var req MagicLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Same as forgot password: the response never depends on the account
+	go func(emailAddress string) {
+		if err := authService.RequestMagicLink(context.Background(), emailAddress); err != nil {
+			log.Error("Failed to send magic link email", "error", err)
+		}
+	}(req.Email)
+
+	c.JSON(http.StatusOK, gin.H{"message": "If an account exists for that email, a sign in link has been sent"})
+}
+
+// handleVerifyMagicLink is a POST so opening the emailed link does nothing on
+// its own. Requiring a JSON body keeps other sites from posting a token of
+// their own without passing CORS, so only the frontend page signs in
+func handleVerifyMagicLink(c *gin.Context) {
+	if c.ContentType() != "application/json" {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Content-Type must be application/json"})
+		return
+	}
+
+	var req VerifyMagicLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Token required"})
+		return
+	}
+
+	result, err := authService.SignInWithMagicLink(c.Request.Context(), req.Token, sessionInfoFromRequest(c))
+	if err != nil {
+		if err == ErrInvalidMagicLink || err == ErrUserNotFound {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired link"})
+			return
+		}
+		log.Error("Magic link sign in failed", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign in"})
+		return
+	}
+
+	respondWithSignIn(c, result)
+}
+
+func handleVerifyMFA(c *gin.Context) {
+	var req VerifyMFARequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := authService.VerifyMFAChallenge(c.Request.Context(), req.MFAToken, req.Code, sessionInfoFromRequest(c))
+	if err != nil {
+		if err == ErrTooManyAttempts {
+			respondTooManyAttempts(c, result.RetryAfter)
+			return
+		}
+		if err == ErrInvalidMFAChallenge || err == ErrMFANotEnabled {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired sign in, please sign in again"})
+			return
+		}
+		if err == ErrInvalidMFACode {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid code, please sign in again"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign in"})
+		return
+	}
+
+	respondWithSignIn(c, result)
 }
 
 func handleSignOut(c *gin.Context) {
 	var req struct {
 		RefreshToken string `json:"refreshToken"`
 	}
-	if err := c.ShouldBindJSON(&req); err == nil && req.RefreshToken != "" {
-		authService.SignOut(c.Request.Context(), req.RefreshToken)
+	c.ShouldBindJSON(&req)
+
+	if refreshToken := refreshTokenFromRequest(c, req.RefreshToken); refreshToken != "" {
+		authService.SignOut(c.Request.Context(), refreshToken)
 	}
 
+	clearAuthCookies(c)
 	c.JSON(http.StatusOK, gin.H{"message": "Signed out successfully"})
 }
 
 func handleRefresh(c *gin.Context) {
+	// The body is optional in cookie mode
 	var req struct {
-		RefreshToken string `json:"refreshToken" binding:"required"`
+		RefreshToken string `json:"refreshToken"`
 	}
-	if err := c.ShouldBindJSON(&req); err != nil {
+	c.ShouldBindJSON(&req)
+
+	refreshToken := refreshTokenFromRequest(c, req.RefreshToken)
+	if refreshToken == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Refresh token is required"})
 		return
 	}
 
-	accessToken, newRefreshToken, err := authService.RefreshAccessToken(c.Request.Context(), req.RefreshToken)
+	accessToken, newRefreshToken, err := authService.RefreshAccessToken(c.Request.Context(), refreshToken, sessionInfoFromRequest(c))
 	if err != nil {
+		clearAuthCookies(c)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"accessToken":  accessToken,
-		"refreshToken": newRefreshToken,
-	})
+	respondWithTokens(c, http.StatusOK, gin.H{}, accessToken, newRefreshToken)
 }
 
-func handleGetCurrentUser(c *gin.Context) {
+func sessionInfoFromRequest(c *gin.Context) SessionInfo {
+	return SessionInfo{
+		UserAgent: c.Request.UserAgent(),
+		IPAddress: c.ClientIP(),
+	}
+}
+
+func handleListSessions(c *gin.Context) {
 	userID := c.GetString("userId")
 	if userID == "" {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
@@ -141,20 +288,49 @@ func handleGetCurrentUser(c *gin.Context) {
 		return
 	}
 
-	user, err := authService.GetUserByID(c.Request.Context(), uid)
+	sessions, err := authService.ListSessions(c.Request.Context(), uid, c.GetString("sessionId"))
 	if err != nil {
-		if err == ErrUserNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sessions": sessions})
+}
+
+func handleRevokeSession(c *gin.Context) {
+	userID := c.GetString("userId")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	sessionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return
+	}
+
+	err = authService.RevokeSession(c.Request.Context(), uid, sessionID)
+	if err != nil {
+		if err == ErrSessionNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"user": user})
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked"})
 }
 
-func handleUpdateProfile(c *gin.Context) {
+// handleRevokeAllSessions signs the user out on every device, including this one
+func handleRevokeAllSessions(c *gin.Context) {
 	userID := c.GetString("userId")
 	if userID == "" {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
@@ -167,22 +343,38 @@ func handleUpdateProfile(c *gin.Context) {
 		return
 	}
 
-	var req UpdateProfileRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := authService.RevokeAllSessions(c.Request.Context(), uid); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign out"})
 		return
 	}
 
-	user, err := authService.UpdateProfile(c.Request.Context(), uid, req.Name, req.Image, req.Region, req.City)
+	clearAuthCookies(c)
+	c.JSON(http.StatusOK, gin.H{"message": "Signed out of all sessions"})
+}
+
+func handleGetMFAStatus(c *gin.Context) {
+	userID := c.GetString("userId")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	uid, err := uuid.Parse(userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"user": user})
+	status, err := authService.GetMFAStatus(c.Request.Context(), uid)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get two-factor status"})
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
 }
 
-func handleSendVerification(c *gin.Context) {
+func handleSetupMFA(c *gin.Context) {
 	userID := c.GetString("userId")
 	if userID == "" {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
@@ -195,113 +387,867 @@ func handleSendVerification(c *gin.Context) {
 		return
 	}
 
-	err = authService.SendVerificationEmail(c.Request.Context(), uid)
+	setup, err := authService.SetupMFA(c.Request.Context(), uid)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if err == ErrUserNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		if err == ErrMFARequiresPassword {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Set a password before enabling two-factor authentication"})
+			return
+		}
+		if err == ErrMFAAlreadyEnabled {
+			c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set up two-factor authentication"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Verification email sent"})
+	c.JSON(http.StatusOK, setup)
 }
 
-func handleVerifyEmail(c *gin.Context) {
-	token := c.Query("token")
-	if token == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Token required"})
+func handleEnableMFA(c *gin.Context) {
+	userID := c.GetString("userId")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
 
-	_, err := authService.VerifyEmail(c.Request.Context(), token)
+	uid, err := uuid.Parse(userID)
 	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Redirect to frontend email verified success page
-	// The frontend will fetch updated user data and update the session
-	c.Redirect(http.StatusFound, AppConfig.FrontendURL+"/email-verified")
+	codes, err := authService.EnableMFA(c.Request.Context(), uid, req.Code)
+	if err != nil {
+		if err == ErrMFANotEnabled {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Start two-factor setup first"})
+			return
+		}
+		if err == ErrMFAAlreadyEnabled {
+			c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+			return
+		}
+		if err == ErrInvalidMFACode {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid code"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable two-factor authentication"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recoveryCodes": codes})
 }
 
-func handleGoogleOAuthURL(c *gin.Context) {
-	// Generate random state
-	b := make([]byte, 16)
-	rand.Read(b)
-	state := base64.URLEncoding.EncodeToString(b)
+func handleDisableMFA(c *gin.Context) {
+	userID := c.GetString("userId")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
 
-	// Store state in memory with 10-minute expiry
-	oauthMu.Lock()
-	oauthStates[state] = time.Now().Add(10 * time.Minute)
-	oauthMu.Unlock()
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
 
-	authURL := GetGoogleAuthURL(state)
-	c.JSON(http.StatusOK, OAuthURLResponse{AuthURL: authURL})
+	var req DisableMFARequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = authService.DisableMFA(c.Request.Context(), uid, sessionInfoFromRequest(c), req.Password, req.Code)
+	if err != nil {
+		if err == ErrTooManyAttempts {
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many attempts, try again later"})
+			return
+		}
+		if err == ErrUserNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		if err == ErrInvalidCredentials {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid password"})
+			return
+		}
+		if err == ErrMFANotEnabled {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+			return
+		}
+		if err == ErrInvalidMFACode {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid code"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable two-factor authentication"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
 }
 
-func handleGoogleOAuthCallback(c *gin.Context) {
-	code := c.Query("code")
-	state := c.Query("state")
+func handleRegenerateRecoveryCodes(c *gin.Context) {
+	userID := c.GetString("userId")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
 
-	// Verify state from memory
-	oauthMu.Lock()
-	expiry, exists := oauthStates[state]
-	if exists {
-		delete(oauthStates, state) // one-time use
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
 	}
-	oauthMu.Unlock()
 
-	if !exists || time.Now().After(expiry) {
-		c.Redirect(http.StatusFound, AppConfig.FrontendURL+"/sign-in?error=oauth_failed")
+	var req MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	if code == "" {
-		c.Redirect(http.StatusFound, AppConfig.FrontendURL+"/sign-in?error=oauth_failed")
+	codes, err := authService.RegenerateRecoveryCodes(c.Request.Context(), uid, sessionInfoFromRequest(c), req.Code)
+	if err != nil {
+		if err == ErrTooManyAttempts {
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many attempts, try again later"})
+			return
+		}
+		if err == ErrMFANotEnabled {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+			return
+		}
+		if err == ErrInvalidMFACode {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid code"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to regenerate recovery codes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recoveryCodes": codes})
+}
+
+func handleGetCurrentUser(c *gin.Context) {
+	userID := c.GetString("userId")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
 
-	user, accessToken, refreshToken, err := authService.HandleGoogleOAuth(c.Request.Context(), code)
+	uid, err := uuid.Parse(userID)
 	if err != nil {
-		c.Redirect(http.StatusFound, AppConfig.FrontendURL+"/sign-in?error=oauth_failed")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
 		return
 	}
 
-	// Generate a one-time exchange code so the frontend can retrieve user data + tokens
-	exchangeCode := uuid.New().String()
-	oauthMu.Lock()
-	oauthExchangeCodes[exchangeCode] = &oauthExchangeEntry{
-		User:         user,
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		ExpiresAt:    time.Now().Add(2 * time.Minute),
+	user, err := authService.GetUserByID(c.Request.Context(), uid)
+	if err != nil {
+		if err == ErrUserNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user"})
+		return
 	}
-	oauthMu.Unlock()
 
-	// Redirect to frontend callback with the exchange code
-	c.Redirect(http.StatusFound, AppConfig.FrontendURL+"/auth/google/callback?auth_code="+exchangeCode)
+	c.JSON(http.StatusOK, gin.H{"user": user})
 }
 
-func handleOAuthExchange(c *gin.Context) {
-	var req OAuthExchangeRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Code is required"})
+func handleUpdateProfile(c *gin.Context) {
+	userID := c.GetString("userId")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
 
-	oauthMu.Lock()
-	entry, exists := oauthExchangeCodes[req.Code]
-	if exists {
-		delete(oauthExchangeCodes, req.Code) // one-time use
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
 	}
-	oauthMu.Unlock()
 
-	if !exists || time.Now().After(entry.ExpiresAt) {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired code"})
+	var req UpdateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"user":         entry.User,
-		"accessToken":  entry.AccessToken,
-		"refreshToken": entry.RefreshToken,
+	user, err := authService.UpdateProfile(c.Request.Context(), uid, req.Name, req.Image, req.Region, req.City)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user": user})
+}
+
+func handleUpdateUserRole(c *gin.Context) {
+	uid, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req UpdateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := authService.UpdateRole(c.Request.Context(), uid, req.Role)
+	if err != nil {
+		if err == ErrUserNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		log.Error("Failed to update role", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user": user})
+}
+
+func handleSendVerification(c *gin.Context) {
+	userID := c.GetString("userId")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	err = authService.SendVerificationEmail(c.Request.Context(), uid)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Verification email sent"})
+}
+
+func handleRequestEmailChange(c *gin.Context) {
+	userID := c.GetString("userId")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req ChangeEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = authService.RequestEmailChange(c.Request.Context(), uid, c.GetString("sessionId"), sessionInfoFromRequest(c), req.NewEmail, req.Password)
+	if err != nil {
+		if err == ErrTooManyAttempts {
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many attempts, try again later"})
+			return
+		}
+		if err == ErrUserNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		if err == ErrInvalidCredentials {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid password"})
+			return
+		}
+		if err == ErrReauthRequired {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Please sign in again to change your email"})
+			return
+		}
+		if err == ErrEmailUnchanged {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "The new email is your current email"})
+			return
+		}
+		if err == ErrUserExists {
+			c.JSON(http.StatusConflict, gin.H{"error": "Email already in use"})
+			return
+		}
+		if err == ErrEmailChangeTooSoon {
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Please wait a few minutes before requesting another change"})
+			return
+		}
+		log.Error("Failed to request email change", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to request email change"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Confirmation link sent to the new email"})
+}
+
+// handleConfirmEmailChange takes the token as JSON for the same reason as
+// handleVerifyMagicLink: only the frontend page can confirm the change
+func handleConfirmEmailChange(c *gin.Context) {
+	if c.ContentType() != "application/json" {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Content-Type must be application/json"})
+		return
+	}
+
+	var req ConfirmEmailChangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Token required"})
+		return
+	}
+
+	_, err := authService.ConfirmEmailChange(c.Request.Context(), req.Token)
+	if err != nil {
+		if err == ErrUserExists {
+			c.JSON(http.StatusConflict, gin.H{"error": "Email already in use"})
+			return
+		}
+		if err == ErrInvalidEmailChange {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired link"})
+			return
+		}
+		log.Error("Email change failed", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change email"})
+		return
+	}
+
+	// Every session was revoked, the user signs in again with the new email
+	clearAuthCookies(c)
+	c.JSON(http.StatusOK, gin.H{"message": "Email changed"})
+}
+
+func handleListIdentities(c *gin.Context) {
+	userID := c.GetString("userId")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	identities, err := authService.ListIdentities(c.Request.Context(), uid)
+	if err != nil {
+		if err == ErrUserNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get sign in methods"})
+		return
+	}
+
+	c.JSON(http.StatusOK, identities)
+}
+
+// handleLinkIdentity answers with the provider URL, like /auth/oauth/:provider.
+// The callback then links the provider instead of signing in
+func handleLinkIdentity(c *gin.Context) {
+	userID := c.GetString("userId")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	provider, err := oauthProviders.Get(c.Param("provider"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown OAuth provider"})
+		return
+	}
+
+	startOAuthFlow(c, provider, uid)
+}
+
+func handleUnlinkIdentity(c *gin.Context) {
+	userID := c.GetString("userId")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	err = authService.UnlinkOAuthAccount(c.Request.Context(), uid, c.Param("provider"))
+	if err != nil {
+		if err == ErrIdentityNotLinked {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Provider not linked"})
+			return
+		}
+		if err == ErrLastSignInMethod {
+			c.JSON(http.StatusConflict, gin.H{"error": "Set a password or link another provider before unlinking this one"})
+			return
+		}
+		log.Error("Failed to unlink identity", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlink provider"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Provider unlinked"})
+}
+
+func handleListAPIKeys(c *gin.Context) {
+	userID := c.GetString("userId")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	apiKeys, err := authService.ListAPIKeys(c.Request.Context(), uid)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get API keys"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"apiKeys": apiKeys})
+}
+
+func handleCreateAPIKey(c *gin.Context) {
+	userID := c.GetString("userId")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	apiKey, err := authService.CreateAPIKey(c.Request.Context(), uid, req)
+	if err != nil {
+		if err == ErrTooManyAPIKeys {
+			c.JSON(http.StatusConflict, gin.H{"error": "API key limit reached, revoke a key first"})
+			return
+		}
+		log.Error("Failed to create API key", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create API key"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, apiKey)
+}
+
+func handleRevokeAPIKey(c *gin.Context) {
+	userID := c.GetString("userId")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	keyID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid API key ID"})
+		return
+	}
+
+	err = authService.RevokeAPIKey(c.Request.Context(), uid, keyID)
+	if err != nil {
+		if err == ErrAPIKeyNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke API key"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked"})
+}
+
+func handleSetPassword(c *gin.Context) {
+	userID := c.GetString("userId")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req SetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = authService.SetPassword(c.Request.Context(), uid, c.GetString("sessionId"), sessionInfoFromRequest(c), req.CurrentPassword, req.NewPassword)
+	if err != nil {
+		if err == ErrTooManyAttempts {
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many attempts, try again later"})
+			return
+		}
+		if err == ErrUserNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		if err == ErrCurrentPasswordInvalid {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid current password"})
+			return
+		}
+		if err == ErrReauthRequired {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Please sign in again to set a password"})
+			return
+		}
+		log.Error("Failed to set password", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set password"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password updated, other sessions were signed out"})
+}
+
+func handleDeleteAccount(c *gin.Context) {
+	userID := c.GetString("userId")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	// The body is optional for accounts without a password
+	var req DeleteAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil && err != io.EOF {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	scheduledFor, err := authService.RequestAccountDeletion(c.Request.Context(), uid, c.GetString("sessionId"), sessionInfoFromRequest(c), req.Password)
+	if err != nil {
+		if err == ErrTooManyAttempts {
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many attempts, try again later"})
+			return
+		}
+		if err == ErrUserNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		if err == ErrInvalidCredentials {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid password"})
+			return
+		}
+		if err == ErrReauthRequired {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Please sign in again to delete your account"})
+			return
+		}
+		log.Error("Failed to request account deletion", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete account"})
+		return
+	}
+
+	clearAuthCookies(c)
+	c.JSON(http.StatusAccepted, AccountDeletionResponse{
+		Message:      "Account scheduled for deletion, sign in before then to cancel",
+		ScheduledFor: scheduledFor,
 	})
 }
+
+func handleExportAccount(c *gin.Context) {
+	userID := c.GetString("userId")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "zip" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Format must be json or zip"})
+		return
+	}
+
+	export, err := authService.ExportAccount(c.Request.Context(), uid)
+	if err != nil {
+		if err == ErrUserNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		log.Error("Failed to export account", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export account"})
+		return
+	}
+
+	filename := "trompeventas-export-" + export.ExportedAt.Format("2006-01-02") + "." + format
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+
+	if format == "zip" {
+		c.Header("Content-Type", "application/zip")
+		c.Status(http.StatusOK)
+		if err := writeAccountExportZip(c.Writer, export); err != nil {
+			log.Error("Failed to write account export", "error", err)
+		}
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, export)
+}
+
+func handleVerifyEmail(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Token required"})
+		return
+	}
+
+	_, err := authService.VerifyEmail(c.Request.Context(), token)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Redirect to frontend email verified success page
+	// The frontend will fetch updated user data and update the session
+	c.Redirect(http.StatusFound, AppConfig.FrontendURL+"/email-verified")
+}
+
+func handleForgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Run in the background so the response time doesn't reveal whether the
+	// account exists
+	go func(emailAddress string) {
+		if err := authService.RequestPasswordReset(context.Background(), emailAddress); err != nil {
+			log.Error("Failed to send password reset email", "error", err)
+		}
+	}(req.Email)
+
+	c.JSON(http.StatusOK, gin.H{"message": "If an account exists for that email, a reset link has been sent"})
+}
+
+func handleResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := authService.ResetPassword(c.Request.Context(), req.Token, req.Password)
+	if err != nil {
+		if err == ErrInvalidResetToken {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired token"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset successfully"})
+}
+
+// oauthStateKey binds a state to the provider that issued it, so a state
+// started with one provider can not be completed on another callback
+func oauthStateKey(provider string, state string) string {
+	return provider + ":" + state
+}
+
+func handleOAuthURL(c *gin.Context) {
+	provider, err := oauthProviders.Get(c.Param("provider"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown OAuth provider"})
+		return
+	}
+
+	startOAuthFlow(c, provider, uuid.Nil)
+}
+
+// startOAuthFlow saves a new state and answers with the provider URL. A
+// linkUserID makes the callback link the provider to that user
+func startOAuthFlow(c *gin.Context, provider OAuthProvider, linkUserID uuid.UUID) {
+	// Generate random state
+	b := make([]byte, 16)
+	rand.Read(b)
+	state := base64.URLEncoding.EncodeToString(b)
+
+	// PKCE verifier, only its challenge goes to the provider
+	codeVerifier := oauth2.GenerateVerifier()
+
+	entry := &oauthStateEntry{CodeVerifier: codeVerifier, LinkUserID: linkUserID}
+	if err := oauthStore.SaveState(c.Request.Context(), oauthStateKey(provider.Name(), state), entry, oauthStateTTL); err != nil {
+		log.Error("Failed to save OAuth state", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start sign in"})
+		return
+	}
+
+	authURL := provider.AuthCodeURL(state, codeVerifier)
+	c.JSON(http.StatusOK, OAuthURLResponse{AuthURL: authURL})
+}
+
+func handleOAuthCallback(c *gin.Context) {
+	provider, err := oauthProviders.Get(c.Param("provider"))
+	if err != nil {
+		c.Redirect(http.StatusFound, AppConfig.FrontendURL+"/sign-in?error=oauth_failed")
+		return
+	}
+
+	code := c.Query("code")
+	state := c.Query("state")
+
+	// Verify state, one-time use
+	entry, err := oauthStore.ConsumeState(c.Request.Context(), oauthStateKey(provider.Name(), state))
+	if err != nil {
+		c.Redirect(http.StatusFound, AppConfig.FrontendURL+"/sign-in?error=oauth_failed")
+		return
+	}
+
+	if entry.LinkUserID != uuid.Nil {
+		completeOAuthLink(c, provider, entry, code)
+		return
+	}
+
+	if code == "" {
+		c.Redirect(http.StatusFound, AppConfig.FrontendURL+"/sign-in?error=oauth_failed")
+		return
+	}
+
+	user, err := authService.HandleOAuth(c.Request.Context(), provider, code, entry.CodeVerifier)
+	if err == ErrOAuthAccountExists {
+		c.Redirect(http.StatusFound, AppConfig.FrontendURL+"/sign-in?error=account_exists")
+		return
+	}
+	if err == ErrOAuthEmailNotVerified {
+		c.Redirect(http.StatusFound, AppConfig.FrontendURL+"/sign-in?error=oauth_email_not_verified")
+		return
+	}
+	if err != nil {
+		log.Error("OAuth sign in failed", "provider", provider.Name(), "error", err)
+		c.Redirect(http.StatusFound, AppConfig.FrontendURL+"/sign-in?error=oauth_failed")
+		return
+	}
+
+	// The frontend trades this one-time code for the session, tokens never
+	// travel in the redirect
+	exchangeCode := GenerateSecureToken()
+	err = oauthStore.SaveExchangeCode(c.Request.Context(), exchangeCode, &oauthExchangeEntry{UserID: user.ID}, oauthExchangeCodeTTL)
+	if err != nil {
+		log.Error("Failed to save OAuth exchange code", "error", err)
+		c.Redirect(http.StatusFound, AppConfig.FrontendURL+"/sign-in?error=oauth_failed")
+		return
+	}
+
+	// Redirect to frontend callback with the exchange code
+	c.Redirect(http.StatusFound, AppConfig.FrontendURL+"/auth/"+provider.Name()+"/callback?auth_code="+exchangeCode)
+}
+
+// completeOAuthLink finishes a flow started from /auth/me/identities and
+// sends the user back to their profile
+func completeOAuthLink(c *gin.Context, provider OAuthProvider, entry *oauthStateEntry, code string) {
+	profileURL := AppConfig.FrontendURL + "/profile"
+	if code == "" {
+		c.Redirect(http.StatusFound, profileURL+"?error=oauth_failed")
+		return
+	}
+
+	err := authService.LinkOAuthAccount(c.Request.Context(), entry.LinkUserID, provider, code, entry.CodeVerifier)
+	if err == ErrIdentityInUse {
+		c.Redirect(http.StatusFound, profileURL+"?error=identity_in_use")
+		return
+	}
+	if err == ErrProviderAlreadyLinked {
+		c.Redirect(http.StatusFound, profileURL+"?error=provider_already_linked")
+		return
+	}
+	if err != nil {
+		log.Error("OAuth link failed", "provider", provider.Name(), "error", err)
+		c.Redirect(http.StatusFound, profileURL+"?error=oauth_failed")
+		return
+	}
+
+	c.Redirect(http.StatusFound, profileURL+"?linked="+provider.Name())
+}
+
+func handleOAuthExchange(c *gin.Context) {
+	var req OAuthExchangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Code is required"})
+		return
+	}
+
+	entry, err := oauthStore.ConsumeExchangeCode(c.Request.Context(), req.Code)
+	if err == ErrOAuthEntryNotFound {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired code"})
+		return
+	}
+	if err != nil {
+		log.Error("Failed to read OAuth exchange code", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to complete sign in"})
+		return
+	}
+
+	result, err := authService.SignInWithOAuth(c.Request.Context(), entry.UserID, sessionInfoFromRequest(c))
+	if err == ErrUserNotFound {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired code"})
+		return
+	}
+	if err != nil {
+		log.Error("Failed to complete OAuth sign in", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to complete sign in"})
+		return
+	}
+
+	respondWithSignIn(c, result)
+}
+
+func handleJWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, tokenKeys.JWKS())
+}
@@ -2,6 +2,7 @@ package auth
 
 import (
 	"net/http"
+	"slices"
 	"strings"
 
 	"restorapp/db"
@@ -12,20 +13,23 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func AuthMiddleware() gin.HandlerFunc {
+// AuthMiddleware accepts access tokens, and API keys on routes that list the
+// scopes they need. API keys are refused on routes that list none
+func AuthMiddleware(scopes ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Get access token from Authorization header
+		if strings.HasPrefix(c.GetHeader("Authorization"), apiKeyAuthScheme) {
+			authenticateAPIKey(c, scopes)
+			return
+		}
 
-		authHeader := c.GetHeader("Authorization")
-		log.Info(authHeader)
-		if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
+		// Get access token from Authorization header or cookie
+		accessToken := accessTokenFromRequest(c)
+		if accessToken == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 			c.Abort()
 			return
 		}
 
-		accessToken := strings.TrimPrefix(authHeader, "Bearer ")
-
 		// Validate token
 		claims, err := ValidateAccessToken(accessToken)
 		if err != nil {
@@ -44,11 +48,78 @@ func AuthMiddleware() gin.HandlerFunc {
 		// Store user ID in context
 		c.Set("userId", claims.UserID.String())
 		c.Set("userEmail", claims.Email)
+		c.Set("userRole", roleOrDefault(claims.Role))
+		c.Set("sessionId", claims.SessionID)
+
+		c.Next()
+	}
+}
+
+func authenticateAPIKey(c *gin.Context, scopes []string) {
+	key := strings.TrimPrefix(c.GetHeader("Authorization"), apiKeyAuthScheme)
+
+	apiKey, err := authService.AuthenticateAPIKey(c.Request.Context(), key)
+	if err != nil {
+		if err == ErrAPIKeyExpired {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "API key expired"})
+		} else {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key"})
+		}
+		c.Abort()
+		return
+	}
+
+	if len(scopes) == 0 {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API keys can not be used here"})
+		c.Abort()
+		return
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(apiKey.Scopes, scope) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "API key is missing the " + scope + " scope"})
+			c.Abort()
+			return
+		}
+	}
+
+	// Same context values as an access token, without a session
+	c.Set("userId", apiKey.UserID.String())
+	c.Set("userEmail", apiKey.Email)
+	c.Set("userRole", roleOrDefault(apiKey.Role))
+	c.Set("apiKeyId", apiKey.ID.String())
+
+	c.Next()
+}
+
+// RequireRole must run after AuthMiddleware. It only lets through users whose
+// role is one of the given roles
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := c.GetString("userRole")
+		if role == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			c.Abort()
+			return
+		}
+
+		if !slices.Contains(roles, role) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+			c.Abort()
+			return
+		}
 
 		c.Next()
 	}
 }
 
+// Tokens issued before roles existed carry no role claim
+func roleOrDefault(role string) string {
+	if role == "" {
+		return RoleUser
+	}
+	return role
+}
+
 func EmailVerifiedMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userIdStr, exists := c.Get("userId")
@@ -84,15 +155,13 @@ func EmailVerifiedMiddleware() gin.HandlerFunc {
 
 func OptionalAuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Get access token from Authorization header
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
+		// Get access token from Authorization header or cookie
+		accessToken := accessTokenFromRequest(c)
+		if accessToken == "" {
 			c.Next()
 			return
 		}
 
-		accessToken := strings.TrimPrefix(authHeader, "Bearer ")
-
 		// Validate token
 		claims, err := ValidateAccessToken(accessToken)
 		if err != nil {
@@ -103,6 +172,8 @@ func OptionalAuthMiddleware() gin.HandlerFunc {
 		// Store user ID in context
 		c.Set("userId", claims.UserID.String())
 		c.Set("userEmail", claims.Email)
+		c.Set("userRole", roleOrDefault(claims.Role))
+		c.Set("sessionId", claims.SessionID)
 
 		c.Next()
 	}
@@ -6,6 +6,13 @@ import (
 	"github.com/google/uuid"
 )
 
+// User roles, from least to most privileged
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
 // Request DTOs
 type SignUpRequest struct {
 	Email    string `json:"email" binding:"required,email"`
@@ -18,6 +25,41 @@ type SignInRequest struct {
 	Password string `json:"password" binding:"required"`
 }
 
+type MFACodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type DisableMFARequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+type VerifyMFARequest struct {
+	MFAToken string `json:"mfaToken" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type MagicLinkRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type VerifyMagicLinkRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type ConfirmEmailChangeRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=8"`
+}
+
 type SendVerificationRequest struct {
 	Email string `json:"email" binding:"required,email"`
 }
@@ -31,9 +73,31 @@ type UserResponse struct {
 	Image         string    `json:"image"`
 	Region        string    `json:"region"`
 	City          string    `json:"city"`
+	Role          string    `json:"role"`
 	CreatedAt     time.Time `json:"createdAt"`
 }
 
+// SignInResult carries either the tokens or, when a second factor is
+// required, only the MFA challenge token. RetryAfter is only set with
+// ErrTooManyAttempts
+type SignInResult struct {
+	User         *UserResponse
+	AccessToken  string
+	RefreshToken string
+	MFAToken     string
+	RetryAfter   time.Duration
+}
+
+type MFASetupResponse struct {
+	Secret     string `json:"secret"`
+	OtpauthURL string `json:"otpauthUrl"`
+}
+
+type MFAStatusResponse struct {
+	Enabled                bool  `json:"enabled"`
+	RecoveryCodesRemaining int64 `json:"recoveryCodesRemaining"`
+}
+
 type AuthResponse struct {
 	User  UserResponse `json:"user"`
 	Token string       `json:"token,omitempty"` // Only used in non-cookie scenarios
@@ -49,10 +113,15 @@ type CSRFTokenResponse struct {
 
 // JWT Claims
 type JWTClaims struct {
-	UserID uuid.UUID `json:"userId"`
-	Email  string    `json:"email"`
-	Exp    int64     `json:"exp"`
-	Iat    int64     `json:"iat"`
+	UserID    uuid.UUID `json:"userId"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	SessionID string    `json:"sid"`
+	Issuer    string    `json:"iss"`
+	Audience  string    `json:"aud"`
+	Exp       int64     `json:"exp"`
+	Nbf       int64     `json:"nbf"`
+	Iat       int64     `json:"iat"`
 }
 
 type UpdateProfileRequest struct {
@@ -62,15 +131,133 @@ type UpdateProfileRequest struct {
 	City   *string `json:"city"`
 }
 
+type ChangeEmailRequest struct {
+	NewEmail string `json:"newEmail" binding:"required,email"`
+	Password string `json:"password"`
+}
+
+type UpdateRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=user moderator admin"`
+}
+
+// SessionInfo describes the client a session was started or last used from
+type SessionInfo struct {
+	UserAgent string
+	IPAddress string
+}
+
+type SessionResponse struct {
+	ID         uuid.UUID `json:"id"`
+	UserAgent  string    `json:"userAgent"`
+	IPAddress  string    `json:"ipAddress"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastUsedAt time.Time `json:"lastUsedAt"`
+	Current    bool      `json:"current"`
+}
+
+// SetPasswordRequest sets or changes the password. CurrentPassword is only
+// checked when the account already has one
+type SetPasswordRequest struct {
+	CurrentPassword string `json:"currentPassword"`
+	NewPassword     string `json:"newPassword" binding:"required,min=8"`
+}
+
+type IdentityResponse struct {
+	Provider string    `json:"provider"`
+	LinkedAt time.Time `json:"linkedAt"`
+}
+
+type IdentitiesResponse struct {
+	HasPassword bool               `json:"hasPassword"`
+	Identities  []IdentityResponse `json:"identities"`
+}
+
+type CreateAPIKeyRequest struct {
+	Name          string   `json:"name" binding:"required,max=100"`
+	Scopes        []string `json:"scopes" binding:"required,min=1,dive,oneof=products:read products:write comments:write"`
+	ExpiresInDays *int     `json:"expiresInDays" binding:"omitempty,min=1,max=365"`
+}
+
+type APIKeyResponse struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expiresAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+// CreatedAPIKeyResponse is the only response that carries the key itself
+type CreatedAPIKeyResponse struct {
+	APIKeyResponse
+	Key string `json:"key"`
+}
+
+// DeleteAccountRequest confirms a deletion. Accounts without a password
+// confirm by having signed in recently instead
+type DeleteAccountRequest struct {
+	Password string `json:"password"`
+}
+
+type AccountDeletionResponse struct {
+	Message      string    `json:"message"`
+	ScheduledFor time.Time `json:"scheduledFor"`
+}
+
+// AccountExport is everything stored about a user. The ZIP format holds
+// one JSON file per field
+type AccountExport struct {
+	ExportedAt time.Time         `json:"exportedAt"`
+	Profile    *UserResponse     `json:"profile"`
+	Products   []ExportedProduct `json:"products"`
+	Comments   []ExportedComment `json:"comments"`
+	Votes      []ExportedVote    `json:"votes"`
+}
+
+type ExportedProduct struct {
+	ID          uuid.UUID `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Price       int64     `json:"price"`
+	Condition   string    `json:"condition"`
+	State       string    `json:"state"`
+	Negotiable  string    `json:"negotiable"`
+	Categories  []string  `json:"categories"`
+	Images      []string  `json:"images"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+type ExportedComment struct {
+	ID        uuid.UUID  `json:"id"`
+	ProductID uuid.UUID  `json:"productId"`
+	ParentID  *uuid.UUID `json:"parentId"`
+	Content   string     `json:"content"`
+	CreatedAt time.Time  `json:"createdAt"`
+	UpdatedAt time.Time  `json:"updatedAt"`
+}
+
+type ExportedVote struct {
+	CommentID uuid.UUID `json:"commentId"`
+	VoteType  string    `json:"voteType"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
 // OAuth exchange code request
 type OAuthExchangeRequest struct {
 	Code string `json:"code" binding:"required"`
 }
 
-// Temporary OAuth exchange code entry
+// oauthStateEntry is what a state stands for until the callback. LinkUserID
+// is set when a signed-in user links the provider instead of signing in
+type oauthStateEntry struct {
+	CodeVerifier string
+	LinkUserID   uuid.UUID
+}
+
+// oauthExchangeEntry is the user an exchange code signs in. The session is
+// only started when the code is exchanged
 type oauthExchangeEntry struct {
-	User         *UserResponse
-	AccessToken  string
-	RefreshToken string
-	ExpiresAt    time.Time
+	UserID uuid.UUID
 }
@@ -7,9 +7,13 @@ import (
 	"restorapp/db"
 	"restorapp/db/client"
 	"restorapp/modules/email"
+	"strings"
 	"time"
 
+	"github.com/charmbracelet/log"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
@@ -17,6 +21,35 @@ var (
 	ErrUserExists         = errors.New("user already exists")
 	ErrInvalidCredentials = errors.New("invalid credentials")
 	ErrUserNotFound       = errors.New("user not found")
+	ErrInvalidResetToken  = errors.New("invalid or expired reset token")
+	ErrRefreshTokenReused = errors.New("refresh token reused")
+	ErrSessionNotFound    = errors.New("session not found")
+	ErrEmailUnchanged     = errors.New("new email is the current email")
+	ErrEmailChangeTooSoon = errors.New("email change requested recently")
+	ErrInvalidEmailChange = errors.New("invalid or expired email change token")
+	ErrInvalidMagicLink   = errors.New("invalid or expired magic link")
+
+	ErrOAuthEmailRequired    = errors.New("oauth provider did not return an email")
+	ErrOAuthEmailNotVerified = errors.New("oauth email is not verified")
+	ErrOAuthAccountExists    = errors.New("an account with this email already exists")
+)
+
+// verification_tokens.type values
+const (
+	tokenTypeEmailVerification = "email_verification"
+	tokenTypePasswordReset     = "password_reset"
+	tokenTypeMFAChallenge      = "mfa_challenge"
+	tokenTypeEmailChange       = "email_change"
+	tokenTypeMagicLink         = "magic_link"
+)
+
+const (
+	passwordResetTokenDuration = 1 * time.Hour
+	passwordResetCooldown      = 5 * time.Minute
+	emailChangeTokenDuration   = 24 * time.Hour
+	emailChangeCooldown        = 5 * time.Minute
+	magicLinkTokenDuration     = 15 * time.Minute
+	magicLinkCooldown          = 5 * time.Minute
 )
 
 type AuthService struct {
@@ -29,6 +62,12 @@ func NewAuthService() *AuthService {
 	}
 }
 
+// normalizeEmail is applied to every email before it is stored or looked up,
+// emails are stored in lower case
+func normalizeEmail(emailAddress string) string {
+	return strings.ToLower(strings.TrimSpace(emailAddress))
+}
+
 func userToResponse(user client.User) *UserResponse {
 	return &UserResponse{
 		ID:            user.ID,
@@ -38,11 +77,14 @@ func userToResponse(user client.User) *UserResponse {
 		Image:         user.Image.String,
 		Region:        user.Region.String,
 		City:          user.City.String,
+		Role:          user.Role,
 		CreatedAt:     user.CreatedAt.Time,
 	}
 }
 
 func (s *AuthService) SignUp(ctx context.Context, req SignUpRequest) (*UserResponse, error) {
+	req.Email = normalizeEmail(req.Email)
+
 	// Check if user exists
 	existingUser, err := s.queries.GetUserByEmail(ctx, req.Email)
 	if err == nil && existingUser.Email != "" {
@@ -71,76 +113,189 @@ func (s *AuthService) SignUp(ctx context.Context, req SignUpRequest) (*UserRespo
 	return userToResponse(user), nil
 }
 
-func (s *AuthService) SignIn(ctx context.Context, req SignInRequest) (*UserResponse, string, string, error) {
+// SignIn checks the password. Users with two-factor authentication get an
+// MFA challenge token instead of tokens, to be completed with VerifyMFAChallenge.
+// Throttled attempts return ErrTooManyAttempts with RetryAfter set
+func (s *AuthService) SignIn(ctx context.Context, req SignInRequest, session SessionInfo) (*SignInResult, error) {
+	emailAddress := normalizeEmail(req.Email)
+
+	attempt, err := s.startLoginAttempt(ctx, emailAddress, session, pgtype.UUID{})
+	if err != nil {
+		return nil, err
+	}
+	if attempt.RetryAfter > 0 {
+		return &SignInResult{RetryAfter: attempt.RetryAfter}, ErrTooManyAttempts
+	}
+
 	// Get user
-	user, err := s.queries.GetUserByEmail(ctx, req.Email)
+	user, err := s.queries.GetUserByEmail(ctx, emailAddress)
 	if err != nil {
-		return nil, "", "", ErrInvalidCredentials
+		// The attempt is already counted as failed
+		CheckPassword(req.Password, dummyPasswordHash())
+		return nil, ErrInvalidCredentials
 	}
 
 	// Check password
-	if !user.PasswordHash.Valid || !CheckPassword(req.Password, user.PasswordHash.String) {
-		return nil, "", "", ErrInvalidCredentials
+	passwordHash := dummyPasswordHash()
+	if user.PasswordHash.Valid {
+		passwordHash = user.PasswordHash.String
+	}
+	if !CheckPassword(req.Password, passwordHash) || !user.PasswordHash.Valid {
+		s.failLoginAttempt(ctx, attempt, user)
+		return nil, ErrInvalidCredentials
+	}
+
+	result, err := s.completeSignIn(ctx, user, session)
+	if err != nil {
+		s.discardLoginAttempt(ctx, attempt)
+		return nil, err
+	}
+
+	// With two-factor authentication the attempt is recorded once the code
+	// is checked, so a known password does not reset the failure count
+	if result.MFAToken != "" {
+		s.discardLoginAttempt(ctx, attempt)
+	} else {
+		s.finishLoginAttempt(ctx, attempt, pgtype.UUID{Bytes: user.ID, Valid: true}, true)
+	}
+
+	return result, nil
+}
+
+// completeSignIn runs once the first factor is checked: it returns an MFA
+// challenge for users with two-factor authentication, tokens otherwise
+func (s *AuthService) completeSignIn(ctx context.Context, user client.User, session SessionInfo) (*SignInResult, error) {
+	_, err := getEnabledMFA(ctx, s.queries, user.ID)
+	if err == nil {
+		mfaToken, err := s.createMFAChallenge(ctx, user.ID)
+		if err != nil {
+			return nil, err
+		}
+		return &SignInResult{MFAToken: mfaToken}, nil
+	}
+	if err != ErrMFANotEnabled {
+		return nil, err
+	}
+
+	accessToken, refreshToken, err := s.startSession(ctx, user, session)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SignInResult{
+		User:         userToResponse(user),
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+// startSession opens a new token family for a sign in and issues its first tokens
+func (s *AuthService) startSession(ctx context.Context, user client.User, session SessionInfo) (string, string, error) {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	defer tx.Rollback(ctx)
+	qtx := s.queries.WithTx(tx)
+
+	// Signing in during the grace period keeps the account
+	cancelled, err := qtx.CancelAccountDeletion(ctx, user.ID)
+	if err != nil {
+		return "", "", err
+	}
+	if cancelled > 0 {
+		log.Info("Account deletion cancelled by sign in", "user", user.ID)
+	}
+
+	family, err := qtx.CreateRefreshTokenFamily(ctx, client.CreateRefreshTokenFamilyParams{
+		UserID:    user.ID,
+		UserAgent: pgtype.Text{String: session.UserAgent, Valid: session.UserAgent != ""},
+		IpAddress: pgtype.Text{String: session.IPAddress, Valid: session.IPAddress != ""},
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	refreshToken, err := issueRefreshToken(ctx, qtx, user.ID, family.ID)
+	if err != nil {
+		return "", "", err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return "", "", err
 	}
 
-	// Generate tokens
-	accessToken, err := GenerateAccessToken(user.ID, user.Email)
+	accessToken, err := GenerateAccessToken(user.ID, user.Email, user.Role, family.ID)
 	if err != nil {
-		return nil, "", "", err
+		return "", "", err
 	}
 
+	return accessToken, refreshToken, nil
+}
+
+func issueRefreshToken(ctx context.Context, queries *client.Queries, userID uuid.UUID, familyID uuid.UUID) (string, error) {
 	refreshToken := GenerateRefreshToken()
-	tokenHash := HashToken(refreshToken)
 	expiresAt := time.Now().Add(time.Duration(AppConfig.RefreshTokenDuration) * 24 * time.Hour)
 
-	_, err = s.queries.CreateRefreshToken(ctx, client.CreateRefreshTokenParams{
-		UserID:    user.ID,
-		TokenHash: tokenHash,
+	_, err := queries.CreateRefreshToken(ctx, client.CreateRefreshTokenParams{
+		UserID:    userID,
+		TokenHash: HashToken(refreshToken),
 		ExpiresAt: pgtype.Timestamp{Time: expiresAt, Valid: true},
+		FamilyID:  familyID,
 	})
 	if err != nil {
-		return nil, "", "", err
+		return "", err
 	}
 
-	return userToResponse(user), accessToken, refreshToken, nil
+	return refreshToken, nil
 }
 
-func (s *AuthService) RefreshAccessToken(ctx context.Context, refreshToken string) (string, string, error) {
+// RefreshAccessToken rotates a refresh token within its family. A token that
+// was already rotated coming back means it was copied, so the whole family is
+// revoked and neither copy can be used again
+func (s *AuthService) RefreshAccessToken(ctx context.Context, refreshToken string, session SessionInfo) (string, string, error) {
 	tokenHash := HashToken(refreshToken)
 
-	// Get refresh token from DB
-	token, err := s.queries.GetRefreshToken(ctx, tokenHash)
+	tx, err := db.Pool.Begin(ctx)
 	if err != nil {
-		return "", "", ErrInvalidToken
+		return "", "", err
 	}
+	defer tx.Rollback(ctx)
+	qtx := s.queries.WithTx(tx)
 
-	// Get user
-	user, err := s.queries.GetUserById(ctx, token.UserID)
+	token, err := qtx.ConsumeRefreshToken(ctx, tokenHash)
+	if err == pgx.ErrNoRows {
+		tx.Rollback(ctx)
+		return "", "", s.detectRefreshTokenReuse(ctx, tokenHash)
+	}
 	if err != nil {
 		return "", "", err
 	}
 
-	// Generate new access token
-	accessToken, err := GenerateAccessToken(user.ID, user.Email)
+	user, err := qtx.GetUserById(ctx, token.UserID)
 	if err != nil {
 		return "", "", err
 	}
 
-	// Rotate refresh token
-	err = s.queries.RevokeRefreshToken(ctx, tokenHash)
+	err = qtx.TouchRefreshTokenFamily(ctx, client.TouchRefreshTokenFamilyParams{
+		ID:        token.FamilyID,
+		UserAgent: pgtype.Text{String: session.UserAgent, Valid: session.UserAgent != ""},
+		IpAddress: pgtype.Text{String: session.IPAddress, Valid: session.IPAddress != ""},
+	})
 	if err != nil {
 		return "", "", err
 	}
 
-	newRefreshToken := GenerateRefreshToken()
-	newTokenHash := HashToken(newRefreshToken)
-	expiresAt := time.Now().Add(time.Duration(AppConfig.RefreshTokenDuration) * 24 * time.Hour)
+	newRefreshToken, err := issueRefreshToken(ctx, qtx, user.ID, token.FamilyID)
+	if err != nil {
+		return "", "", err
+	}
 
-	_, err = s.queries.CreateRefreshToken(ctx, client.CreateRefreshTokenParams{
-		UserID:    user.ID,
-		TokenHash: newTokenHash,
-		ExpiresAt: pgtype.Timestamp{Time: expiresAt, Valid: true},
-	})
+	if err := tx.Commit(ctx); err != nil {
+		return "", "", err
+	}
+
+	accessToken, err := GenerateAccessToken(user.ID, user.Email, user.Role, token.FamilyID)
 	if err != nil {
 		return "", "", err
 	}
@@ -148,9 +303,85 @@ func (s *AuthService) RefreshAccessToken(ctx context.Context, refreshToken strin
 	return accessToken, newRefreshToken, nil
 }
 
+func (s *AuthService) detectRefreshTokenReuse(ctx context.Context, tokenHash string) error {
+	token, err := s.queries.GetRotatedRefreshToken(ctx, tokenHash)
+	if err != nil {
+		return ErrInvalidToken
+	}
+
+	log.Warn("Rotated refresh token reused, revoking session", "userId", token.UserID, "sessionId", token.FamilyID)
+	if err := s.queries.RevokeRefreshTokenFamily(ctx, token.FamilyID); err != nil {
+		return err
+	}
+
+	return ErrRefreshTokenReused
+}
+
+// SignOut ends the session the refresh token belongs to
 func (s *AuthService) SignOut(ctx context.Context, refreshToken string) error {
-	tokenHash := HashToken(refreshToken)
-	return s.queries.RevokeRefreshToken(ctx, tokenHash)
+	token, err := s.queries.ConsumeRefreshToken(ctx, HashToken(refreshToken))
+	if err == pgx.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return s.queries.RevokeRefreshTokenFamily(ctx, token.FamilyID)
+}
+
+func (s *AuthService) ListSessions(ctx context.Context, userID uuid.UUID, currentSessionID string) ([]SessionResponse, error) {
+	families, err := s.queries.ListActiveRefreshTokenFamilies(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]SessionResponse, 0, len(families))
+	for _, family := range families {
+		sessions = append(sessions, SessionResponse{
+			ID:         family.ID,
+			UserAgent:  family.UserAgent.String,
+			IPAddress:  family.IpAddress.String,
+			CreatedAt:  family.CreatedAt.Time,
+			LastUsedAt: family.LastUsedAt.Time,
+			Current:    family.ID.String() == currentSessionID,
+		})
+	}
+
+	return sessions, nil
+}
+
+func (s *AuthService) RevokeSession(ctx context.Context, userID uuid.UUID, sessionID uuid.UUID) error {
+	rows, err := s.queries.RevokeUserRefreshTokenFamily(ctx, client.RevokeUserRefreshTokenFamilyParams{
+		ID:     sessionID,
+		UserID: userID,
+	})
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// RevokeAllSessions signs the user out everywhere
+func (s *AuthService) RevokeAllSessions(ctx context.Context, userID uuid.UUID) error {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	qtx := s.queries.WithTx(tx)
+
+	if err := qtx.RevokeAllUserRefreshTokens(ctx, userID); err != nil {
+		return err
+	}
+	if err := qtx.RevokeAllUserRefreshTokenFamilies(ctx, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
 }
 
 func (s *AuthService) GetUserByID(ctx context.Context, userID uuid.UUID) (*UserResponse, error) {
@@ -162,93 +393,96 @@ func (s *AuthService) GetUserByID(ctx context.Context, userID uuid.UUID) (*UserR
 	return userToResponse(user), nil
 }
 
-func (s *AuthService) HandleGoogleOAuth(ctx context.Context, code string) (*UserResponse, string, string, error) {
-	// Get user info from Google
-	googleUser, oauthToken, err := GetGoogleUserInfo(ctx, code)
+// HandleOAuth resolves a provider authorization code to a user, creating the
+// account on first sign in when the provider verified the email. An email
+// that already has an account returns ErrOAuthAccountExists. The sign in is completed by SignInWithOAuth
+func (s *AuthService) HandleOAuth(ctx context.Context, provider OAuthProvider, code string, codeVerifier string) (*UserResponse, error) {
+	oauthToken, err := provider.Exchange(ctx, code, codeVerifier)
 	if err != nil {
-		return nil, "", "", err
+		return nil, err
+	}
+
+	oauthUser, err := provider.UserInfo(ctx, oauthToken)
+	if err != nil {
+		return nil, err
+	}
+
+	oauthUser.Email = normalizeEmail(oauthUser.Email)
+	if oauthUser.Email == "" {
+		return nil, ErrOAuthEmailRequired
 	}
 
 	// Check if OAuth account exists
 	oauthAccount, err := s.queries.GetOAuthAccount(ctx, client.GetOAuthAccountParams{
-		Provider:       "google",
-		ProviderUserID: googleUser.ID,
+		Provider:       provider.Name(),
+		ProviderUserID: oauthUser.ProviderUserID,
 	})
 
 	var user client.User
 	if err != nil {
-		// Check if user with email exists
-		existingUser, err := s.queries.GetUserByEmail(ctx, googleUser.Email)
-		if err == nil {
-			// User exists, link OAuth account
-			user = existingUser
-			// Update image if not set
-			if !user.Image.Valid && googleUser.Picture != "" {
-				s.queries.UpdateUserImage(ctx, client.UpdateUserImageParams{
-					Image: pgtype.Text{String: googleUser.Picture, Valid: true},
-					ID:    user.ID,
-				})
-				user.Image = pgtype.Text{String: googleUser.Picture, Valid: true}
-			}
-		} else {
-			// Create new user
-			user, err = s.queries.CreateUser(ctx, client.CreateUserParams{
-				Email:         googleUser.Email,
-				Name:          googleUser.Name,
-				EmailVerified: pgtype.Bool{Bool: googleUser.VerifiedEmail, Valid: true},
-				PasswordHash:  pgtype.Text{Valid: false}, // No password for OAuth users
-				Image:         pgtype.Text{String: googleUser.Picture, Valid: googleUser.Picture != ""},
-			})
-			if err != nil {
-				return nil, "", "", err
-			}
+		// Accounts are never linked by email, the owner signs in and links
+		// the provider from their profile
+		if _, err := s.queries.GetUserByEmail(ctx, oauthUser.Email); err == nil {
+			return nil, ErrOAuthAccountExists
 		}
 
-		// Create OAuth account
-		expiresAt := pgtype.Timestamp{}
-		if oauthToken.Expiry.IsZero() == false {
-			expiresAt = pgtype.Timestamp{Time: oauthToken.Expiry, Valid: true}
+		// Create new user
+		params, err := newOAuthUserParams(oauthUser)
+		if err != nil {
+			return nil, err
+		}
+		user, err = s.queries.CreateUser(ctx, params)
+		if err != nil {
+			return nil, err
 		}
 
-		_, err = s.queries.CreateOAuthAccount(ctx, client.CreateOAuthAccountParams{
-			UserID:         user.ID,
-			Provider:       "google",
-			ProviderUserID: googleUser.ID,
-			AccessToken:    pgtype.Text{String: oauthToken.AccessToken, Valid: true},
-			RefreshToken:   pgtype.Text{String: oauthToken.RefreshToken, Valid: true},
-			ExpiresAt:      expiresAt,
-		})
+		// Create OAuth account
+		err = s.createOAuthAccount(ctx, user.ID, provider.Name(), oauthUser.ProviderUserID, oauthToken)
 		if err != nil {
-			return nil, "", "", err
+			return nil, err
 		}
 	} else {
 		// OAuth account exists, get user
 		user, err = s.queries.GetUserById(ctx, oauthAccount.UserID)
 		if err != nil {
-			return nil, "", "", err
+			return nil, err
+		}
+
+		// Keep the stored tokens as fresh as the sign in
+		if err := s.storeOAuthTokens(ctx, oauthAccount, oauthToken); err != nil {
+			return nil, err
 		}
 	}
 
-	// Generate tokens
-	accessToken, err := GenerateAccessToken(user.ID, user.Email)
-	if err != nil {
-		return nil, "", "", err
+	return userToResponse(user), nil
+}
+
+// newOAuthUserParams builds the account for a first provider sign in. Only
+// emails the provider verified can hold an account, otherwise anyone could
+// claim an address before its owner signs up
+func newOAuthUserParams(oauthUser *OAuthUserInfo) (client.CreateUserParams, error) {
+	if !oauthUser.EmailVerified {
+		return client.CreateUserParams{}, ErrOAuthEmailNotVerified
 	}
 
-	refreshToken := GenerateRefreshToken()
-	tokenHash := HashToken(refreshToken)
-	expiresAt := time.Now().Add(time.Duration(AppConfig.RefreshTokenDuration) * 24 * time.Hour)
+	return client.CreateUserParams{
+		Email:         oauthUser.Email,
+		Name:          oauthUser.Name,
+		EmailVerified: pgtype.Bool{Bool: true, Valid: true},
+		PasswordHash:  pgtype.Text{Valid: false}, // No password for OAuth users
+		Image:         pgtype.Text{String: oauthUser.Picture, Valid: oauthUser.Picture != ""},
+	}, nil
+}
 
-	_, err = s.queries.CreateRefreshToken(ctx, client.CreateRefreshTokenParams{
-		UserID:    user.ID,
-		TokenHash: tokenHash,
-		ExpiresAt: pgtype.Timestamp{Time: expiresAt, Valid: true},
-	})
+// SignInWithOAuth signs in the user an OAuth exchange code was issued for.
+// Users with two-factor authentication get an MFA challenge, like SignIn
+func (s *AuthService) SignInWithOAuth(ctx context.Context, userID uuid.UUID, session SessionInfo) (*SignInResult, error) {
+	user, err := s.queries.GetUserById(ctx, userID)
 	if err != nil {
-		return nil, "", "", err
+		return nil, ErrUserNotFound
 	}
 
-	return userToResponse(user), accessToken, refreshToken, nil
+	return s.completeSignIn(ctx, user, session)
 }
 
 func (s *AuthService) UpdateProfile(ctx context.Context, userID uuid.UUID, name string, image *string, region *string, city *string) (*UserResponse, error) {
@@ -289,6 +523,18 @@ func (s *AuthService) UpdateProfile(ctx context.Context, userID uuid.UUID, name
 	return s.GetUserByID(ctx, userID)
 }
 
+func (s *AuthService) UpdateRole(ctx context.Context, userID uuid.UUID, role string) (*UserResponse, error) {
+	user, err := s.queries.UpdateUserRole(ctx, client.UpdateUserRoleParams{Role: role, ID: userID})
+	if err == pgx.ErrNoRows {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return userToResponse(user), nil
+}
+
 func (s *AuthService) CreateVerificationToken(ctx context.Context, userID uuid.UUID) (string, error) {
 	token := uuid.New().String()
 	expiresAt := time.Now().Add(24 * time.Hour)
@@ -296,7 +542,7 @@ func (s *AuthService) CreateVerificationToken(ctx context.Context, userID uuid.U
 	_, err := s.queries.CreateVerificationToken(ctx, client.CreateVerificationTokenParams{
 		UserID:    userID,
 		Token:     token,
-		Type:      "email_verification",
+		Type:      tokenTypeEmailVerification,
 		ExpiresAt: pgtype.Timestamp{Time: expiresAt, Valid: true},
 	})
 	if err != nil {
@@ -377,3 +623,287 @@ func (s *AuthService) SendVerificationEmail(ctx context.Context, userID uuid.UUI
 
 	return nil
 }
+
+// RequestPasswordReset emails a single-use reset link. It returns nil when the
+// email is unknown or a link was sent recently, so callers cannot tell
+// whether an account exists
+func (s *AuthService) RequestPasswordReset(ctx context.Context, emailAddress string) error {
+	user, err := s.queries.GetUserByEmail(ctx, normalizeEmail(emailAddress))
+	if err != nil {
+		return nil
+	}
+
+	// Same cooldown as verification emails, silently skipped
+	tokens, err := s.queries.GetUserVerificationTokensByType(ctx, client.GetUserVerificationTokensByTypeParams{
+		UserID: user.ID,
+		Type:   tokenTypePasswordReset,
+	})
+	if err == nil && len(tokens) > 0 && time.Since(tokens[0].CreatedAt.Time) < passwordResetCooldown {
+		return nil
+	}
+
+	// Only the hash is stored, the plain token only travels in the email
+	token := GenerateSecureToken()
+	_, err = s.queries.CreateVerificationToken(ctx, client.CreateVerificationTokenParams{
+		UserID:    user.ID,
+		Token:     HashToken(token),
+		Type:      tokenTypePasswordReset,
+		ExpiresAt: pgtype.Timestamp{Time: time.Now().Add(passwordResetTokenDuration), Valid: true},
+	})
+	if err != nil {
+		return err
+	}
+
+	resetURL := fmt.Sprintf("%s/reset-password?token=%s", AppConfig.FrontendURL, token)
+
+	return email.SendPasswordResetEmail(user.Email, user.Name, resetURL, "1 hora")
+}
+
+func (s *AuthService) ResetPassword(ctx context.Context, token string, newPassword string) error {
+	// Deleting the token is what makes it single-use
+	resetToken, err := s.queries.ConsumeVerificationToken(ctx, client.ConsumeVerificationTokenParams{
+		Token: HashToken(token),
+		Type:  tokenTypePasswordReset,
+	})
+	if err != nil {
+		return ErrInvalidResetToken
+	}
+
+	passwordHash, err := HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+
+	err = s.queries.UpdateUserPassword(ctx, client.UpdateUserPasswordParams{
+		PasswordHash: pgtype.Text{String: passwordHash, Valid: true},
+		ID:           resetToken.UserID,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Invalidate any other outstanding reset links
+	err = s.queries.DeleteUserVerificationTokensByType(ctx, client.DeleteUserVerificationTokensByTypeParams{
+		UserID: resetToken.UserID,
+		Type:   tokenTypePasswordReset,
+	})
+	if err != nil {
+		return err
+	}
+
+	// A reset usually means the account was at risk, keys made by whoever had
+	// the old password go too
+	if err := s.queries.RevokeAllUserAPIKeys(ctx, resetToken.UserID); err != nil {
+		return err
+	}
+
+	// Sign out every session that may have been opened with the old password
+	return s.RevokeAllSessions(ctx, resetToken.UserID)
+}
+
+// RequestMagicLink emails a single-use sign-in link. Like
+// RequestPasswordReset it returns nil when the email is unknown or a link was
+// sent recently, so callers cannot tell whether an account exists
+func (s *AuthService) RequestMagicLink(ctx context.Context, emailAddress string) error {
+	user, err := s.queries.GetUserByEmail(ctx, normalizeEmail(emailAddress))
+	if err != nil {
+		return nil
+	}
+
+	tokens, err := s.queries.GetUserVerificationTokensByType(ctx, client.GetUserVerificationTokensByTypeParams{
+		UserID: user.ID,
+		Type:   tokenTypeMagicLink,
+	})
+	if err == nil && len(tokens) > 0 && time.Since(tokens[0].CreatedAt.Time) < magicLinkCooldown {
+		return nil
+	}
+
+	token := GenerateSecureToken()
+	_, err = s.queries.CreateVerificationToken(ctx, client.CreateVerificationTokenParams{
+		UserID:    user.ID,
+		Token:     HashToken(token),
+		Type:      tokenTypeMagicLink,
+		ExpiresAt: pgtype.Timestamp{Time: time.Now().Add(magicLinkTokenDuration), Valid: true},
+	})
+	if err != nil {
+		return err
+	}
+
+	// The frontend page POSTs the token to /auth/magic-link/verify, so mail
+	// scanners that open links do not use it up
+	signInURL := fmt.Sprintf("%s/magic-link?token=%s", AppConfig.FrontendURL, token)
+
+	return email.SendMagicLinkEmail(user.Email, user.Name, signInURL, "15 minutos")
+}
+
+// SignInWithMagicLink consumes the link and signs the user in. Opening it
+// proves the user owns the address, so the email is marked as verified.
+// Users with two-factor authentication still get an MFA challenge
+func (s *AuthService) SignInWithMagicLink(ctx context.Context, token string, session SessionInfo) (*SignInResult, error) {
+	magicLink, err := s.queries.ConsumeVerificationToken(ctx, client.ConsumeVerificationTokenParams{
+		Token: HashToken(token),
+		Type:  tokenTypeMagicLink,
+	})
+	if err != nil {
+		return nil, ErrInvalidMagicLink
+	}
+
+	user, err := s.queries.GetUserById(ctx, magicLink.UserID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	if !user.EmailVerified.Bool {
+		if err := s.queries.UpdateUserEmailVerified(ctx, user.ID); err != nil {
+			return nil, err
+		}
+		user.EmailVerified = pgtype.Bool{Bool: true, Valid: true}
+	}
+
+	// Any other link that was sent is no longer needed
+	err = s.queries.DeleteUserVerificationTokensByType(ctx, client.DeleteUserVerificationTokensByTypeParams{
+		UserID: user.ID,
+		Type:   tokenTypeMagicLink,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.completeSignIn(ctx, user, session)
+}
+
+// RequestEmailChange emails a confirmation link to the new address and a
+// notice to the current one. Password accounts must confirm their password,
+// accounts without one must have signed in recently. The users row only
+// changes once the link is opened
+func (s *AuthService) RequestEmailChange(ctx context.Context, userID uuid.UUID, sessionID string, session SessionInfo, newEmail string, password string) error {
+	user, err := s.queries.GetUserById(ctx, userID)
+	if err != nil {
+		return ErrUserNotFound
+	}
+
+	if user.PasswordHash.Valid {
+		if err := s.checkAccountPassword(ctx, user, password, session); err != nil {
+			return err
+		}
+	} else if !s.isRecentSession(ctx, userID, sessionID) {
+		return ErrReauthRequired
+	}
+
+	newEmail = normalizeEmail(newEmail)
+	if newEmail == normalizeEmail(user.Email) {
+		return ErrEmailUnchanged
+	}
+
+	if _, err := s.queries.GetUserByEmail(ctx, newEmail); err == nil {
+		return ErrUserExists
+	}
+
+	tokens, err := s.queries.GetUserVerificationTokensByType(ctx, client.GetUserVerificationTokensByTypeParams{
+		UserID: userID,
+		Type:   tokenTypeEmailChange,
+	})
+	if err == nil && len(tokens) > 0 && time.Since(tokens[0].CreatedAt.Time) < emailChangeCooldown {
+		return ErrEmailChangeTooSoon
+	}
+
+	// Only the latest requested address can be confirmed
+	err = s.queries.DeleteUserVerificationTokensByType(ctx, client.DeleteUserVerificationTokensByTypeParams{
+		UserID: userID,
+		Type:   tokenTypeEmailChange,
+	})
+	if err != nil {
+		return err
+	}
+
+	token := GenerateSecureToken()
+	err = s.queries.CreateEmailChangeToken(ctx, client.CreateEmailChangeTokenParams{
+		UserID:    userID,
+		Token:     HashToken(token),
+		Type:      tokenTypeEmailChange,
+		ExpiresAt: pgtype.Timestamp{Time: time.Now().Add(emailChangeTokenDuration), Valid: true},
+		NewEmail:  pgtype.Text{String: newEmail, Valid: true},
+	})
+	if err != nil {
+		return err
+	}
+
+	// The frontend page POSTs the token to /auth/confirm-email-change, so mail
+	// scanners opening the link do not confirm the change
+	confirmURL := fmt.Sprintf("%s/confirm-email-change?token=%s", AppConfig.FrontendURL, token)
+	if err := email.SendEmailChangeEmail(newEmail, user.Name, confirmURL, "24 horas"); err != nil {
+		return err
+	}
+
+	resetURL := AppConfig.FrontendURL + "/forgot-password"
+	if err := email.SendEmailChangeNoticeEmail(user.Email, user.Name, newEmail, resetURL); err != nil {
+		log.Error("Failed to send email change notice", "error", err)
+	}
+
+	return nil
+}
+
+// ConfirmEmailChange swaps the email, signs the user out everywhere and
+// revokes their API keys.
+// Linked OAuth accounts stay linked: they are matched by provider user ID,
+// not by email, so Google or GitHub sign-in keeps working after the change
+func (s *AuthService) ConfirmEmailChange(ctx context.Context, token string) (uuid.UUID, error) {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	defer tx.Rollback(ctx)
+	qtx := s.queries.WithTx(tx)
+
+	changeToken, err := qtx.ConsumeVerificationToken(ctx, client.ConsumeVerificationTokenParams{
+		Token: HashToken(token),
+		Type:  tokenTypeEmailChange,
+	})
+	if err != nil || !changeToken.NewEmail.Valid {
+		return uuid.Nil, ErrInvalidEmailChange
+	}
+
+	// The address may have been taken since the change was requested
+	if _, err := qtx.GetUserByEmail(ctx, changeToken.NewEmail.String); err == nil {
+		return uuid.Nil, ErrUserExists
+	}
+
+	_, err = qtx.UpdateUserEmail(ctx, client.UpdateUserEmailParams{
+		Email: changeToken.NewEmail.String,
+		ID:    changeToken.UserID,
+	})
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" { // unique_violation
+			return uuid.Nil, ErrUserExists
+		}
+		return uuid.Nil, err
+	}
+
+	// Links sent to the old address are no longer valid
+	for _, tokenType := range []string{tokenTypeEmailVerification, tokenTypePasswordReset, tokenTypeMagicLink} {
+		err = qtx.DeleteUserVerificationTokensByType(ctx, client.DeleteUserVerificationTokensByTypeParams{
+			UserID: changeToken.UserID,
+			Type:   tokenType,
+		})
+		if err != nil {
+			return uuid.Nil, err
+		}
+	}
+
+	if err := qtx.RevokeAllUserRefreshTokens(ctx, changeToken.UserID); err != nil {
+		return uuid.Nil, err
+	}
+	if err := qtx.RevokeAllUserRefreshTokenFamilies(ctx, changeToken.UserID); err != nil {
+		return uuid.Nil, err
+	}
+	if err := qtx.RevokeAllUserAPIKeys(ctx, changeToken.UserID); err != nil {
+		return uuid.Nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return uuid.Nil, err
+	}
+
+	return changeToken.UserID, nil
+}
@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Cookie mode keeps the tokens out of reach of JavaScript. The refresh
+// token cookie is scoped to /auth, the only place it is read
+const (
+	accessTokenCookie  = "access_token"
+	refreshTokenCookie = "refresh_token"
+	csrfTokenCookie    = "csrf_token"
+	csrfHeader         = "X-CSRF-Token"
+	refreshCookiePath  = "/auth"
+)
+
+func setCookie(c *gin.Context, name string, value string, maxAge int, path string) {
+	c.SetSameSite(AppConfig.CookieSameSite)
+	c.SetCookie(name, value, maxAge, path, AppConfig.CookieDomain, AppConfig.CookieSecure, true)
+}
+
+func setAuthCookies(c *gin.Context, accessToken string, refreshToken string) {
+	setCookie(c, accessTokenCookie, accessToken, AppConfig.AccessTokenDuration*60, "/")
+	setCookie(c, refreshTokenCookie, refreshToken, AppConfig.RefreshTokenDuration*24*60*60, refreshCookiePath)
+}
+
+func clearAuthCookies(c *gin.Context) {
+	setCookie(c, accessTokenCookie, "", -1, "/")
+	setCookie(c, refreshTokenCookie, "", -1, refreshCookiePath)
+}
+
+// ensureCSRFCookie returns the current CSRF token, issuing one if the client
+// has none yet
+func ensureCSRFCookie(c *gin.Context) string {
+	if token, err := c.Cookie(csrfTokenCookie); err == nil && token != "" {
+		return token
+	}
+
+	token := GenerateSecureToken()
+	setCookie(c, csrfTokenCookie, token, AppConfig.RefreshTokenDuration*24*60*60, "/")
+	return token
+}
+
+// respondWithTokens sends the tokens in the body, or as cookies in cookie
+// mode, where the body carries the CSRF token to send back on writes instead
+func respondWithTokens(c *gin.Context, status int, body gin.H, accessToken string, refreshToken string) {
+	if AppConfig.CookieMode {
+		setAuthCookies(c, accessToken, refreshToken)
+		body["csrfToken"] = ensureCSRFCookie(c)
+	} else {
+		body["accessToken"] = accessToken
+		body["refreshToken"] = refreshToken
+	}
+
+	c.JSON(status, body)
+}
+
+// accessTokenFromRequest prefers the Authorization header over the cookie
+func accessTokenFromRequest(c *gin.Context) string {
+	authHeader := c.GetHeader("Authorization")
+	if strings.HasPrefix(authHeader, "Bearer ") {
+		return strings.TrimPrefix(authHeader, "Bearer ")
+	}
+
+	token, err := c.Cookie(accessTokenCookie)
+	if err != nil {
+		return ""
+	}
+	return token
+}
+
+// refreshTokenFromRequest prefers the body over the cookie
+func refreshTokenFromRequest(c *gin.Context, bodyToken string) string {
+	if bodyToken != "" {
+		return bodyToken
+	}
+
+	token, err := c.Cookie(refreshTokenCookie)
+	if err != nil {
+		return ""
+	}
+	return token
+}
+
+func usesAuthCookies(c *gin.Context) bool {
+	if strings.HasPrefix(c.GetHeader("Authorization"), "Bearer ") {
+		return false
+	}
+
+	for _, name := range []string{accessTokenCookie, refreshTokenCookie} {
+		if _, err := c.Cookie(name); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func isSafeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+func handleGetCSRFToken(c *gin.Context) {
+	c.JSON(http.StatusOK, CSRFTokenResponse{CSRFToken: ensureCSRFCookie(c)})
+}
+
+// CSRFMiddleware checks the double-submit token on state-changing requests
+// authenticated by cookie. Bearer requests can not be forged cross-site and
+// pass through
+func CSRFMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if isSafeMethod(c.Request.Method) || !usesAuthCookies(c) {
+			c.Next()
+			return
+		}
+
+		cookieToken, err := c.Cookie(csrfTokenCookie)
+		headerToken := c.GetHeader(csrfHeader)
+		if err != nil || cookieToken == "" || subtle.ConstantTimeCompare([]byte(cookieToken), []byte(headerToken)) != 1 {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Invalid CSRF token"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
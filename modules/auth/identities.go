@@ -0,0 +1,181 @@
+package auth
+
+import (
+	"context"
+	"errors"
+
+	"restorapp/db"
+	"restorapp/db/client"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+var (
+	ErrIdentityInUse          = errors.New("identity is linked to another account")
+	ErrProviderAlreadyLinked  = errors.New("provider already linked")
+	ErrIdentityNotLinked      = errors.New("provider not linked")
+	ErrLastSignInMethod       = errors.New("last sign in method")
+	ErrCurrentPasswordInvalid = errors.New("current password is invalid")
+)
+
+// ListIdentities returns the linked providers and whether a password is set
+func (s *AuthService) ListIdentities(ctx context.Context, userID uuid.UUID) (*IdentitiesResponse, error) {
+	user, err := s.queries.GetUserById(ctx, userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	accounts, err := s.queries.ListOAuthAccountsByUserId(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	identities := make([]IdentityResponse, 0, len(accounts))
+	for _, account := range accounts {
+		identities = append(identities, IdentityResponse{
+			Provider: account.Provider,
+			LinkedAt: account.CreatedAt.Time,
+		})
+	}
+
+	return &IdentitiesResponse{
+		HasPassword: user.PasswordHash.Valid,
+		Identities:  identities,
+	}, nil
+}
+
+// LinkOAuthAccount completes a link started by a signed-in user. Unlike
+// HandleOAuth the provider email does not have to match the account email,
+// the user proved both sides by being signed in to each
+func (s *AuthService) LinkOAuthAccount(ctx context.Context, userID uuid.UUID, provider OAuthProvider, code string, codeVerifier string) error {
+	oauthToken, err := provider.Exchange(ctx, code, codeVerifier)
+	if err != nil {
+		return err
+	}
+
+	oauthUser, err := provider.UserInfo(ctx, oauthToken)
+	if err != nil {
+		return err
+	}
+
+	existing, err := s.queries.GetOAuthAccount(ctx, client.GetOAuthAccountParams{
+		Provider:       provider.Name(),
+		ProviderUserID: oauthUser.ProviderUserID,
+	})
+	if err == nil {
+		if existing.UserID != userID {
+			return ErrIdentityInUse
+		}
+		return s.storeOAuthTokens(ctx, existing, oauthToken)
+	}
+	if err != pgx.ErrNoRows {
+		return err
+	}
+
+	// One identity per provider, a different one has to be unlinked first
+	if _, err := s.queries.GetOAuthAccountByUserId(ctx, client.GetOAuthAccountByUserIdParams{
+		UserID:   userID,
+		Provider: provider.Name(),
+	}); err == nil {
+		return ErrProviderAlreadyLinked
+	}
+
+	return s.createOAuthAccount(ctx, userID, provider.Name(), oauthUser.ProviderUserID, oauthToken)
+}
+
+// UnlinkOAuthAccount removes a provider as long as the user keeps a password
+// or another provider to sign in with
+func (s *AuthService) UnlinkOAuthAccount(ctx context.Context, userID uuid.UUID, provider string) error {
+	rows, err := s.queries.DeleteOAuthAccountKeepingSignIn(ctx, client.DeleteOAuthAccountKeepingSignInParams{
+		UserID:   userID,
+		Provider: provider,
+	})
+	if err != nil {
+		return err
+	}
+	if rows > 0 {
+		return nil
+	}
+
+	// Nothing deleted: either not linked or the only way left to sign in
+	_, err = s.queries.GetOAuthAccountByUserId(ctx, client.GetOAuthAccountByUserIdParams{
+		UserID:   userID,
+		Provider: provider,
+	})
+	if err == pgx.ErrNoRows {
+		return ErrIdentityNotLinked
+	}
+	if err != nil {
+		return err
+	}
+	return ErrLastSignInMethod
+}
+
+// SetPassword changes the password after confirming the current one. OAuth-only
+// accounts setting their first password need a recent sign in instead. Every
+// other session is signed out and API keys are revoked, as on a reset. The
+// session making the change stays signed in
+func (s *AuthService) SetPassword(ctx context.Context, userID uuid.UUID, sessionID string, session SessionInfo, currentPassword string, newPassword string) error {
+	user, err := s.queries.GetUserById(ctx, userID)
+	if err != nil {
+		return ErrUserNotFound
+	}
+
+	if user.PasswordHash.Valid {
+		err := s.checkAccountPassword(ctx, user, currentPassword, session)
+		if err == ErrInvalidCredentials {
+			return ErrCurrentPasswordInvalid
+		}
+		if err != nil {
+			return err
+		}
+	} else if !s.isRecentSession(ctx, userID, sessionID) {
+		return ErrReauthRequired
+	}
+
+	passwordHash, err := HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	qtx := s.queries.WithTx(tx)
+
+	err = qtx.UpdateUserPassword(ctx, client.UpdateUserPasswordParams{
+		PasswordHash: pgtype.Text{String: passwordHash, Valid: true},
+		ID:           userID,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Reset links were sent for the old password
+	err = qtx.DeleteUserVerificationTokensByType(ctx, client.DeleteUserVerificationTokensByTypeParams{
+		UserID: userID,
+		Type:   tokenTypePasswordReset,
+	})
+	if err != nil {
+		return err
+	}
+
+	currentSession, _ := uuid.Parse(sessionID)
+	err = qtx.RevokeOtherUserRefreshTokenFamilies(ctx, client.RevokeOtherUserRefreshTokenFamiliesParams{
+		UserID: userID,
+		ID:     currentSession,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := qtx.RevokeAllUserAPIKeys(ctx, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
@@ -1,7 +1,8 @@
 package auth
 
 import (
-	"crypto/hmac"
+	"crypto/ed25519"
+	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
@@ -18,28 +19,43 @@ var (
 	ErrExpiredToken = errors.New("token expired")
 )
 
-func GenerateAccessToken(userID uuid.UUID, email string) (string, error) {
+// Allowed clock difference between us and whoever checks nbf/exp
+const tokenClockSkew = 30 * time.Second
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+	Kid string `json:"kid"`
+}
+
+func GenerateAccessToken(userID uuid.UUID, email string, role string, sessionID uuid.UUID) (string, error) {
 	now := time.Now()
 	exp := now.Add(time.Duration(AppConfig.AccessTokenDuration) * time.Minute)
 
 	claims := JWTClaims{
-		UserID: userID,
-		Email:  email,
-		Iat:    now.Unix(),
-		Exp:    exp.Unix(),
+		UserID:    userID,
+		Email:     email,
+		Role:      role,
+		SessionID: sessionID.String(),
+		Issuer:    AppConfig.JWTIssuer,
+		Audience:  AppConfig.JWTAudience,
+		Iat:       now.Unix(),
+		Nbf:       now.Unix(),
+		Exp:       exp.Unix(),
 	}
 
 	return generateToken(claims)
 }
 
 func generateToken(claims JWTClaims) (string, error) {
-	// Create header
-	header := map[string]string{
-		"alg": "HS256",
-		"typ": "JWT",
-	}
+	key := tokenKeys.signing
 
-	headerJSON, err := json.Marshal(header)
+	// Create header
+	headerJSON, err := json.Marshal(jwtHeader{
+		Alg: jwtAlgorithm,
+		Typ: "JWT",
+		Kid: key.ID,
+	})
 	if err != nil {
 		return "", err
 	}
@@ -54,10 +70,10 @@ func generateToken(claims JWTClaims) (string, error) {
 
 	// Create signature
 	message := headerEncoded + "." + payloadEncoded
-	signature := createSignature(message, AppConfig.JWTSecret)
+	signature := ed25519.Sign(key.PrivateKey, []byte(message))
 
 	// Combine all parts
-	token := message + "." + signature
+	token := message + "." + base64.RawURLEncoding.EncodeToString(signature)
 
 	return token, nil
 }
@@ -70,13 +86,34 @@ func ValidateAccessToken(tokenString string) (*JWTClaims, error) {
 
 	headerEncoded := parts[0]
 	payloadEncoded := parts[1]
-	signature := parts[2]
+
+	// Decode header, the algorithm is fixed and never taken from the token
+	headerJSON, err := base64.RawURLEncoding.DecodeString(headerEncoded)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	var header jwtHeader
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return nil, ErrInvalidToken
+	}
+	if header.Alg != jwtAlgorithm {
+		return nil, ErrInvalidToken
+	}
+
+	publicKey, found := tokenKeys.verification[header.Kid]
+	if !found {
+		return nil, ErrInvalidToken
+	}
 
 	// Verify signature
-	message := headerEncoded + "." + payloadEncoded
-	expectedSignature := createSignature(message, AppConfig.JWTSecret)
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
 
-	if signature != expectedSignature {
+	message := headerEncoded + "." + payloadEncoded
+	if !ed25519.Verify(publicKey, []byte(message), signature) {
 		return nil, ErrInvalidToken
 	}
 
@@ -91,24 +128,34 @@ func ValidateAccessToken(tokenString string) (*JWTClaims, error) {
 		return nil, ErrInvalidToken
 	}
 
-	// Check expiration
-	if time.Now().Unix() > claims.Exp {
+	if claims.Issuer != AppConfig.JWTIssuer || claims.Audience != AppConfig.JWTAudience {
+		return nil, ErrInvalidToken
+	}
+
+	// Check validity window
+	now := time.Now()
+	if now.Add(tokenClockSkew).Unix() < claims.Nbf {
+		return nil, ErrInvalidToken
+	}
+	if now.Add(-tokenClockSkew).Unix() > claims.Exp {
 		return nil, ErrExpiredToken
 	}
 
 	return &claims, nil
 }
 
-func createSignature(message, secret string) string {
-	h := hmac.New(sha256.New, []byte(secret))
-	h.Write([]byte(message))
-	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
-}
-
 func GenerateRefreshToken() string {
 	return uuid.New().String()
 }
 
+// GenerateSecureToken returns 32 random bytes, URL-safe encoded, for links
+// sent by email
+func GenerateSecureToken() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
 func HashToken(token string) string {
 	h := sha256.New()
 	h.Write([]byte(token))
@@ -0,0 +1,309 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	testJWTIssuer   = "https://api.example.com"
+	testJWTAudience = "trompeventas-api"
+)
+
+func setTestJWTConfig(t *testing.T) {
+	t.Helper()
+	previous := AppConfig
+	AppConfig = &Config{
+		JWTIssuer:           testJWTIssuer,
+		JWTAudience:         testJWTAudience,
+		AccessTokenDuration: 15,
+	}
+	t.Cleanup(func() { AppConfig = previous })
+}
+
+func useTokenKeys(t *testing.T, keySet *KeySet) {
+	t.Helper()
+	previous := tokenKeys
+	tokenKeys = keySet
+	t.Cleanup(func() { tokenKeys = previous })
+}
+
+// writeTestKey saves a new Ed25519 key in dir as <kid>.pem, only its public
+// half when publicOnly is set
+func writeTestKey(t *testing.T, dir string, keyID string, publicOnly bool) ed25519.PrivateKey {
+	t.Helper()
+
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	block := &pem.Block{Type: "PRIVATE KEY"}
+	if publicOnly {
+		block.Type = "PUBLIC KEY"
+		block.Bytes, err = x509.MarshalPKIXPublicKey(publicKey)
+	} else {
+		block.Bytes, err = x509.MarshalPKCS8PrivateKey(privateKey)
+	}
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, keyID+".pem"), pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+	return privateKey
+}
+
+func mustLoadKeySet(t *testing.T, dir string, activeKeyID string) *KeySet {
+	t.Helper()
+	keySet, err := LoadKeySet(dir, activeKeyID)
+	if err != nil {
+		t.Fatalf("load key set: %v", err)
+	}
+	return keySet
+}
+
+func testClaims() JWTClaims {
+	now := time.Now()
+	return JWTClaims{
+		UserID:    uuid.New(),
+		Email:     "ana@example.com",
+		Role:      RoleUser,
+		SessionID: uuid.NewString(),
+		Issuer:    testJWTIssuer,
+		Audience:  testJWTAudience,
+		Iat:       now.Unix(),
+		Nbf:       now.Unix(),
+		Exp:       now.Add(15 * time.Minute).Unix(),
+	}
+}
+
+// signTestToken builds a token with any header, to forge what
+// generateToken never produces
+func signTestToken(t *testing.T, header jwtHeader, claims JWTClaims, privateKey ed25519.PrivateKey) string {
+	t.Helper()
+
+	headerJSON, _ := json.Marshal(header)
+	payloadJSON, _ := json.Marshal(claims)
+	message := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(payloadJSON)
+	return message + "." + base64.RawURLEncoding.EncodeToString(ed25519.Sign(privateKey, []byte(message)))
+}
+
+func TestAccessTokenRoundTrip(t *testing.T) {
+	setTestJWTConfig(t)
+	dir := t.TempDir()
+	writeTestKey(t, dir, "2026-01", false)
+	useTokenKeys(t, mustLoadKeySet(t, dir, "2026-01"))
+
+	userID, sessionID := uuid.New(), uuid.New()
+	token, err := GenerateAccessToken(userID, "ana@example.com", RoleAdmin, sessionID)
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+
+	claims, err := ValidateAccessToken(token)
+	if err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	if claims.UserID != userID || claims.Role != RoleAdmin || claims.SessionID != sessionID.String() {
+		t.Errorf("unexpected claims %+v", claims)
+	}
+	if claims.Issuer != testJWTIssuer || claims.Audience != testJWTAudience {
+		t.Errorf("iss/aud = %q/%q", claims.Issuer, claims.Audience)
+	}
+}
+
+func TestValidateAccessTokenRejectsForgedHeaders(t *testing.T) {
+	setTestJWTConfig(t)
+	dir := t.TempDir()
+	privateKey := writeTestKey(t, dir, "2026-01", false)
+	useTokenKeys(t, mustLoadKeySet(t, dir, "2026-01"))
+
+	tests := []struct {
+		name   string
+		header jwtHeader
+	}{
+		{"alg none", jwtHeader{Alg: "none", Typ: "JWT", Kid: "2026-01"}},
+		{"alg HS256", jwtHeader{Alg: "HS256", Typ: "JWT", Kid: "2026-01"}},
+		{"unknown kid", jwtHeader{Alg: jwtAlgorithm, Typ: "JWT", Kid: "2025-12"}},
+		{"missing kid", jwtHeader{Alg: jwtAlgorithm, Typ: "JWT"}},
+	}
+
+	for _, tt := range tests {
+		token := signTestToken(t, tt.header, testClaims(), privateKey)
+		if _, err := ValidateAccessToken(token); err != ErrInvalidToken {
+			t.Errorf("%s: got %v, want ErrInvalidToken", tt.name, err)
+		}
+	}
+}
+
+func TestValidateAccessTokenRejectsOtherKeys(t *testing.T) {
+	setTestJWTConfig(t)
+	dir := t.TempDir()
+	writeTestKey(t, dir, "2026-01", false)
+	useTokenKeys(t, mustLoadKeySet(t, dir, "2026-01"))
+
+	// Right kid, signed by a key that is not ours
+	_, otherKey, _ := ed25519.GenerateKey(rand.Reader)
+	token := signTestToken(t, jwtHeader{Alg: jwtAlgorithm, Typ: "JWT", Kid: "2026-01"}, testClaims(), otherKey)
+	if _, err := ValidateAccessToken(token); err != ErrInvalidToken {
+		t.Errorf("foreign signature: got %v, want ErrInvalidToken", err)
+	}
+
+	// Changing the payload breaks the signature
+	valid, _ := generateToken(testClaims())
+	parts := strings.Split(valid, ".")
+	claims := testClaims()
+	claims.Role = RoleAdmin
+	payloadJSON, _ := json.Marshal(claims)
+	parts[1] = base64.RawURLEncoding.EncodeToString(payloadJSON)
+	if _, err := ValidateAccessToken(strings.Join(parts, ".")); err != ErrInvalidToken {
+		t.Errorf("modified payload: got %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestValidateAccessTokenChecksClaims(t *testing.T) {
+	setTestJWTConfig(t)
+	dir := t.TempDir()
+	writeTestKey(t, dir, "2026-01", false)
+	useTokenKeys(t, mustLoadKeySet(t, dir, "2026-01"))
+
+	now := time.Now()
+	tests := []struct {
+		name   string
+		modify func(*JWTClaims)
+		want   error
+	}{
+		{"wrong issuer", func(c *JWTClaims) { c.Issuer = "https://evil.example.com" }, ErrInvalidToken},
+		{"wrong audience", func(c *JWTClaims) { c.Audience = "another-api" }, ErrInvalidToken},
+		{"not yet valid", func(c *JWTClaims) { c.Nbf = now.Add(5 * time.Minute).Unix() }, ErrInvalidToken},
+		{"expired", func(c *JWTClaims) { c.Exp = now.Add(-5 * time.Minute).Unix() }, ErrExpiredToken},
+		{"nbf within skew", func(c *JWTClaims) { c.Nbf = now.Add(tokenClockSkew / 2).Unix() }, nil},
+		{"exp within skew", func(c *JWTClaims) { c.Exp = now.Add(-tokenClockSkew / 2).Unix() }, nil},
+	}
+
+	for _, tt := range tests {
+		claims := testClaims()
+		tt.modify(&claims)
+		token, err := generateToken(claims)
+		if err != nil {
+			t.Fatalf("%s: generate: %v", tt.name, err)
+		}
+		if _, err := ValidateAccessToken(token); err != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestValidateAccessTokenAfterRotation(t *testing.T) {
+	setTestJWTConfig(t)
+	dir := t.TempDir()
+	oldKey := writeTestKey(t, dir, "2026-01", false)
+	writeTestKey(t, dir, "2026-02", false)
+
+	useTokenKeys(t, mustLoadKeySet(t, dir, "2026-01"))
+	oldToken, err := generateToken(testClaims())
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+
+	// The new key signs, the old one still verifies
+	tokenKeys = mustLoadKeySet(t, dir, "2026-02")
+	if _, err := ValidateAccessToken(oldToken); err != nil {
+		t.Errorf("token signed by the previous key: %v", err)
+	}
+	newToken, _ := generateToken(testClaims())
+	if !strings.Contains(decodeTestHeader(t, newToken), `"kid":"2026-02"`) {
+		t.Errorf("new tokens are not signed with the active key")
+	}
+
+	// Keeping only the public half of the old key is enough to verify
+	publicKey := oldKey.Public().(ed25519.PublicKey)
+	publicDER, _ := x509.MarshalPKIXPublicKey(publicKey)
+	os.WriteFile(filepath.Join(dir, "2026-01.pem"), pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}), 0o600)
+	tokenKeys = mustLoadKeySet(t, dir, "2026-02")
+	if _, err := ValidateAccessToken(oldToken); err != nil {
+		t.Errorf("token signed by a public-only key: %v", err)
+	}
+
+	// Once the old key is gone its tokens stop validating
+	os.Remove(filepath.Join(dir, "2026-01.pem"))
+	tokenKeys = mustLoadKeySet(t, dir, "2026-02")
+	if _, err := ValidateAccessToken(oldToken); err != ErrInvalidToken {
+		t.Errorf("token signed by a removed key: got %v, want ErrInvalidToken", err)
+	}
+}
+
+func decodeTestHeader(t *testing.T, token string) string {
+	t.Helper()
+	header, err := base64.RawURLEncoding.DecodeString(strings.Split(token, ".")[0])
+	if err != nil {
+		t.Fatalf("decode header: %v", err)
+	}
+	return string(header)
+}
+
+func TestLoadKeySetRequiresActivePrivateKey(t *testing.T) {
+	dir := t.TempDir()
+	writeTestKey(t, dir, "2026-01", true)
+
+	if _, err := LoadKeySet(dir, "2026-01"); err == nil {
+		t.Error("expected a public-only active key to be refused")
+	}
+	if _, err := LoadKeySet(dir, "2026-02"); err == nil {
+		t.Error("expected a missing active key to be refused")
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	dir := t.TempDir()
+	newKey := writeTestKey(t, dir, "2026-02", false)
+	oldKey := writeTestKey(t, dir, "2026-01", true)
+	keySet := mustLoadKeySet(t, dir, "2026-02")
+
+	body, err := json.Marshal(keySet.JWKS())
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+
+	var jwks struct {
+		Keys []map[string]string `json:"keys"`
+	}
+	if err := json.Unmarshal(body, &jwks); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if len(jwks.Keys) != 2 {
+		t.Fatalf("got %d keys, want 2", len(jwks.Keys))
+	}
+
+	// Sorted by kid, public halves only
+	expected := []struct {
+		kid string
+		key ed25519.PrivateKey
+	}{{"2026-01", oldKey}, {"2026-02", newKey}}
+	for i, want := range expected {
+		jwk := jwks.Keys[i]
+		if jwk["kid"] != want.kid || jwk["kty"] != "OKP" || jwk["crv"] != "Ed25519" || jwk["alg"] != "EdDSA" || jwk["use"] != "sig" {
+			t.Errorf("key %d: unexpected %v", i, jwk)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(jwk["x"])
+		if err != nil || !ed25519.PublicKey(x).Equal(want.key.Public()) {
+			t.Errorf("key %d: x does not match the public key", i)
+		}
+		if _, found := jwk["d"]; found {
+			t.Errorf("key %d: private key published", i)
+		}
+	}
+}
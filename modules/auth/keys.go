@@ -0,0 +1,179 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/log"
+)
+
+// Access tokens are signed with Ed25519 (JWS alg "EdDSA"). Keys live in
+// JWT_KEYS_DIR as PEM files named <kid>.pem:
+//
+//   - a PKCS#8 private key can sign and verify
+//   - a PKIX public key only verifies
+//
+// JWT_ACTIVE_KID picks the signing key. To rotate without downtime, ship the
+// new key to every instance first, then switch JWT_ACTIVE_KID, and delete the
+// old file once the tokens it signed have expired.
+const jwtAlgorithm = "EdDSA"
+
+type signingKey struct {
+	ID         string
+	PrivateKey ed25519.PrivateKey
+}
+
+type KeySet struct {
+	signing      signingKey
+	verification map[string]ed25519.PublicKey
+}
+
+var tokenKeys *KeySet
+
+func LoadKeySet(dir string, activeKeyID string) (*KeySet, error) {
+	keySet := &KeySet{verification: make(map[string]ed25519.PublicKey)}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		keyID := strings.TrimSuffix(filepath.Base(file), ".pem")
+		privateKey, publicKey, err := readPEMKey(file)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+
+		keySet.verification[keyID] = publicKey
+		if keyID == activeKeyID {
+			if privateKey == nil {
+				return nil, fmt.Errorf("active key %s has no private key", keyID)
+			}
+			keySet.signing = signingKey{ID: keyID, PrivateKey: privateKey}
+		}
+	}
+
+	if keySet.signing.PrivateKey == nil {
+		return nil, fmt.Errorf("active key %q not found in %s", activeKeyID, dir)
+	}
+
+	return keySet, nil
+}
+
+// newEphemeralKeySet is used with JWT_EPHEMERAL_KEY. Tokens stop
+// validating on restart and across instances, so it is only fit for development
+func newEphemeralKeySet() (*KeySet, error) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(publicKey)
+	keyID := "dev-" + base64.RawURLEncoding.EncodeToString(sum[:6])
+
+	return &KeySet{
+		signing:      signingKey{ID: keyID, PrivateKey: privateKey},
+		verification: map[string]ed25519.PublicKey{keyID: publicKey},
+	}, nil
+}
+
+func readPEMKey(file string) (ed25519.PrivateKey, ed25519.PublicKey, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, nil, errors.New("no PEM block found")
+	}
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, nil, err
+		}
+		privateKey, ok := key.(ed25519.PrivateKey)
+		if !ok {
+			return nil, nil, errors.New("not an Ed25519 private key")
+		}
+		return privateKey, privateKey.Public().(ed25519.PublicKey), nil
+	case "PUBLIC KEY":
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, nil, err
+		}
+		publicKey, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return nil, nil, errors.New("not an Ed25519 public key")
+		}
+		return nil, publicKey, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+}
+
+// initTokenKeys refuses to start without keys, a temporary key has to be
+// asked for with JWT_EPHEMERAL_KEY=true
+func initTokenKeys() {
+	var err error
+	switch {
+	case AppConfig.JWTKeysDir != "":
+		tokenKeys, err = LoadKeySet(AppConfig.JWTKeysDir, AppConfig.JWTActiveKeyID)
+	case AppConfig.JWTEphemeralKey:
+		log.Warn("JWT_EPHEMERAL_KEY set, signing access tokens with a temporary key")
+		tokenKeys, err = newEphemeralKeySet()
+	default:
+		log.Fatal("JWT_KEYS_DIR environment variable is required, set JWT_EPHEMERAL_KEY=true to use a temporary key in development")
+	}
+	if err != nil {
+		log.Fatal("Failed to load JWT keys", "error", err)
+	}
+}
+
+// JWK is an OKP public key as published in the JWKS document (RFC 8037)
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+func (k *KeySet) JWKS() JWKS {
+	keyIDs := make([]string, 0, len(k.verification))
+	for keyID := range k.verification {
+		keyIDs = append(keyIDs, keyID)
+	}
+	sort.Strings(keyIDs)
+
+	jwks := JWKS{Keys: []JWK{}}
+	for _, keyID := range keyIDs {
+		jwks.Keys = append(jwks.Keys, JWK{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(k.verification[keyID]),
+			Kid: keyID,
+			Use: "sig",
+			Alg: jwtAlgorithm,
+		})
+	}
+	return jwks
+}
@@ -0,0 +1,222 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"restorapp/db"
+	"restorapp/db/client"
+	"restorapp/modules/email"
+
+	"github.com/charmbracelet/log"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Password sign-in throttling. Failures are counted per email (reset by a
+// successful sign-in) and per IP, both over a sliding window
+const (
+	loginAttemptWindow = 15 * time.Minute
+	// From this many failures on, every attempt waits twice as long as the previous one
+	loginDelayAfterFailures = 3
+	loginMaxDelay           = 30 * time.Second
+	loginLockoutFailures    = 10
+	loginLockoutDuration    = 15 * time.Minute
+	loginIPMaxFailures      = 50
+)
+
+var ErrTooManyAttempts = errors.New("too many sign in attempts")
+
+// dummyPasswordHash is compared against when there is no password to check,
+// so unknown emails cost the same bcrypt work as a wrong password
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, _ := HashPassword(GenerateSecureToken())
+	return hash
+})
+
+// loginDelay is how long after the last failure the next attempt is allowed
+func loginDelay(failures int64) time.Duration {
+	if failures >= loginLockoutFailures {
+		return loginLockoutDuration
+	}
+	if failures < loginDelayAfterFailures {
+		return 0
+	}
+	return min(time.Second<<(failures-loginDelayAfterFailures), loginMaxDelay)
+}
+
+// checkLoginThrottle returns how long the client has to wait before trying
+// again, zero when the attempt may go ahead, and the current failure count
+// for the email. It runs before any bcrypt work
+func checkLoginThrottle(ctx context.Context, queries *client.Queries, emailAddress string, ipAddress string) (time.Duration, int64, error) {
+	since := pgtype.Timestamp{Time: time.Now().Add(-loginAttemptWindow), Valid: true}
+
+	ipFailures, err := queries.GetIPLoginFailures(ctx, client.GetIPLoginFailuresParams{
+		IpAddress: ipAddress,
+		CreatedAt: since,
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	if ipFailures.Failures >= loginIPMaxFailures {
+		return time.Until(ipFailures.LastFailureAt.Time.Add(loginAttemptWindow)), ipFailures.Failures, nil
+	}
+
+	emailFailures, err := queries.GetEmailLoginFailures(ctx, client.GetEmailLoginFailuresParams{
+		Email:     emailAddress,
+		CreatedAt: since,
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	retryAfter := time.Until(emailFailures.LastFailureAt.Time.Add(loginDelay(emailFailures.Failures)))
+	if emailFailures.Failures == 0 || retryAfter < 0 {
+		retryAfter = 0
+	}
+
+	return retryAfter, emailFailures.Failures, nil
+}
+
+// loginAttempt is an attempt counted as failed until finishLoginAttempt
+// records how it went
+type loginAttempt struct {
+	ID         uuid.UUID
+	Session    SessionInfo
+	Failures   int64
+	RetryAfter time.Duration
+}
+
+// startLoginAttempt checks the throttle and, when the attempt may go ahead,
+// records it as failed. Both run in one short transaction holding a lock on
+// the email, so concurrent attempts can not all pass the check before any of
+// them is counted. The password or code is checked after it returns, outside
+// the transaction. RetryAfter is set when the attempt has to wait
+func (s *AuthService) startLoginAttempt(ctx context.Context, emailAddress string, session SessionInfo, userID pgtype.UUID) (*loginAttempt, error) {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+	qtx := s.queries.WithTx(tx)
+
+	if err := qtx.LockLoginEmail(ctx, emailAddress); err != nil {
+		return nil, err
+	}
+
+	retryAfter, failures, err := checkLoginThrottle(ctx, qtx, emailAddress, session.IPAddress)
+	if err != nil {
+		return nil, err
+	}
+	attempt := &loginAttempt{Session: session, Failures: failures, RetryAfter: retryAfter}
+	if retryAfter > 0 {
+		return attempt, nil
+	}
+
+	attempt.ID, err = qtx.CreateLoginAttempt(ctx, client.CreateLoginAttemptParams{
+		Email:     emailAddress,
+		IpAddress: session.IPAddress,
+		UserID:    userID,
+		UserAgent: pgtype.Text{String: session.UserAgent, Valid: session.UserAgent != ""},
+		Success:   false,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return attempt, tx.Commit(ctx)
+}
+
+// finishLoginAttempt records the outcome of a started attempt. A failed update
+// is only logged, the attempt then stays counted as failed
+func (s *AuthService) finishLoginAttempt(ctx context.Context, attempt *loginAttempt, userID pgtype.UUID, success bool) {
+	err := s.queries.FinishLoginAttempt(ctx, client.FinishLoginAttemptParams{
+		ID:      attempt.ID,
+		UserID:  userID,
+		Success: success,
+	})
+	if err != nil {
+		log.Error("Failed to record login attempt", "error", err)
+	}
+}
+
+// failLoginAttempt records a wrong password or code and tells the owner when
+// it locks the account
+func (s *AuthService) failLoginAttempt(ctx context.Context, attempt *loginAttempt, user client.User) {
+	s.finishLoginAttempt(ctx, attempt, pgtype.UUID{Bytes: user.ID, Valid: true}, false)
+	if attempt.Failures+1 == loginLockoutFailures {
+		notifyAccountLocked(user, attempt.Session.IPAddress)
+	}
+}
+
+// discardLoginAttempt forgets an attempt whose outcome is decided by a later
+// step, like a password that still needs the second factor
+func (s *AuthService) discardLoginAttempt(ctx context.Context, attempt *loginAttempt) {
+	if err := s.queries.DeleteLoginAttempt(ctx, attempt.ID); err != nil {
+		log.Error("Failed to discard login attempt", "error", err)
+	}
+}
+
+// checkAccountPassword re-checks the password of a signed-in user before a
+// sensitive change. Wrong passwords count towards the sign-in throttle of the
+// account so a stolen session can not keep guessing, a right one does not
+// reset it
+func (s *AuthService) checkAccountPassword(ctx context.Context, user client.User, password string, session SessionInfo) error {
+	attempt, err := s.startLoginAttempt(ctx, normalizeEmail(user.Email), session, pgtype.UUID{Bytes: user.ID, Valid: true})
+	if err != nil {
+		return err
+	}
+	if attempt.RetryAfter > 0 {
+		return ErrTooManyAttempts
+	}
+
+	if !user.PasswordHash.Valid || !CheckPassword(password, user.PasswordHash.String) {
+		s.failLoginAttempt(ctx, attempt, user)
+		return ErrInvalidCredentials
+	}
+
+	s.discardLoginAttempt(ctx, attempt)
+	return nil
+}
+
+// checkAccountMFACode is checkAccountPassword for the second factor
+func (s *AuthService) checkAccountMFACode(ctx context.Context, user client.User, code string, session SessionInfo) error {
+	mfa, err := getEnabledMFA(ctx, s.queries, user.ID)
+	if err != nil {
+		return err
+	}
+
+	attempt, err := s.startLoginAttempt(ctx, normalizeEmail(user.Email), session, pgtype.UUID{Bytes: user.ID, Valid: true})
+	if err != nil {
+		return err
+	}
+	if attempt.RetryAfter > 0 {
+		return ErrTooManyAttempts
+	}
+
+	if err := verifyMFACode(ctx, s.queries, mfa, code); err != nil {
+		if err == ErrInvalidMFACode {
+			s.failLoginAttempt(ctx, attempt, user)
+		} else {
+			s.discardLoginAttempt(ctx, attempt)
+		}
+		return err
+	}
+
+	s.discardLoginAttempt(ctx, attempt)
+	return nil
+}
+
+// notifyAccountLocked emails the owner in the background, so the response
+// time is the same whether or not the account exists
+func notifyAccountLocked(user client.User, ipAddress string) {
+	go func() {
+		resetURL := AppConfig.FrontendURL + "/forgot-password"
+		err := email.SendAccountLockedEmail(user.Email, user.Name, ipAddress, "15 minutos", resetURL)
+		if err != nil {
+			log.Error("Failed to send account locked email", "error", err)
+		}
+	}()
+}
@@ -0,0 +1,341 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"strings"
+	"time"
+
+	"restorapp/db"
+	"restorapp/db/client"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	mfaChallengeDuration = 5 * time.Minute
+	mfaRecoveryCodeCount = 10
+)
+
+var (
+	ErrMFAAlreadyEnabled   = errors.New("two-factor authentication already enabled")
+	ErrMFANotEnabled       = errors.New("two-factor authentication not enabled")
+	ErrMFARequiresPassword = errors.New("two-factor authentication requires a password account")
+	ErrInvalidMFACode      = errors.New("invalid two-factor code")
+	ErrInvalidMFAChallenge = errors.New("invalid or expired mfa challenge")
+)
+
+// generateRecoveryCodes returns codes formatted as xxxxx-xxxxx
+func generateRecoveryCodes() []string {
+	codes := make([]string, mfaRecoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 7)
+		rand.Read(b)
+		code := strings.ToLower(totpEncoding.EncodeToString(b))[:10]
+		codes[i] = code[:5] + "-" + code[5:]
+	}
+	return codes
+}
+
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	code = strings.ReplaceAll(code, "-", "")
+	return HashToken(code)
+}
+
+// replaceRecoveryCodes invalidates the previous codes and stores new ones
+func replaceRecoveryCodes(ctx context.Context, queries *client.Queries, userID uuid.UUID) ([]string, error) {
+	if err := queries.DeleteUserMFARecoveryCodes(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	codes := generateRecoveryCodes()
+	for _, code := range codes {
+		err := queries.CreateMFARecoveryCode(ctx, client.CreateMFARecoveryCodeParams{
+			UserID:   userID,
+			CodeHash: hashRecoveryCode(code),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return codes, nil
+}
+
+func getEnabledMFA(ctx context.Context, queries *client.Queries, userID uuid.UUID) (client.UserMfa, error) {
+	mfa, err := queries.GetUserMFA(ctx, userID)
+	if err == pgx.ErrNoRows || (err == nil && !mfa.EnabledAt.Valid) {
+		return mfa, ErrMFANotEnabled
+	}
+	return mfa, err
+}
+
+// verifyMFACode accepts either a TOTP code or an unused recovery code. Both
+// are single-use: a TOTP time step and a recovery code can not be used twice
+func verifyMFACode(ctx context.Context, queries *client.Queries, mfa client.UserMfa, code string) error {
+	code = strings.TrimSpace(code)
+
+	if len(code) == totpDigits {
+		step, ok := validateTOTP(mfa.TotpSecret, code, time.Now())
+		if !ok {
+			return ErrInvalidMFACode
+		}
+
+		rows, err := queries.UseMFAStep(ctx, client.UseMFAStepParams{
+			UserID:       mfa.UserID,
+			LastUsedStep: step,
+		})
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return ErrInvalidMFACode
+		}
+		return nil
+	}
+
+	rows, err := queries.UseMFARecoveryCode(ctx, client.UseMFARecoveryCodeParams{
+		UserID:   mfa.UserID,
+		CodeHash: hashRecoveryCode(code),
+	})
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrInvalidMFACode
+	}
+	return nil
+}
+
+func (s *AuthService) GetMFAStatus(ctx context.Context, userID uuid.UUID) (*MFAStatusResponse, error) {
+	_, err := getEnabledMFA(ctx, s.queries, userID)
+	if err == ErrMFANotEnabled {
+		return &MFAStatusResponse{Enabled: false}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	remaining, err := s.queries.CountUnusedMFARecoveryCodes(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MFAStatusResponse{Enabled: true, RecoveryCodesRemaining: remaining}, nil
+}
+
+// SetupMFA starts enrollment with a new secret. It does nothing to sign-in
+// until EnableMFA confirms a code generated from that secret
+func (s *AuthService) SetupMFA(ctx context.Context, userID uuid.UUID) (*MFASetupResponse, error) {
+	user, err := s.queries.GetUserById(ctx, userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	if !user.PasswordHash.Valid {
+		return nil, ErrMFARequiresPassword
+	}
+
+	secret := generateTOTPSecret()
+	_, err = s.queries.UpsertPendingUserMFA(ctx, client.UpsertPendingUserMFAParams{
+		UserID:     userID,
+		TotpSecret: secret,
+	})
+	if err == pgx.ErrNoRows {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &MFASetupResponse{
+		Secret:     secret,
+		OtpauthURL: totpURI(secret, user.Email),
+	}, nil
+}
+
+// EnableMFA confirms enrollment and returns the recovery codes. They are
+// only shown this once
+func (s *AuthService) EnableMFA(ctx context.Context, userID uuid.UUID, code string) ([]string, error) {
+	mfa, err := s.queries.GetUserMFA(ctx, userID)
+	if err == pgx.ErrNoRows {
+		return nil, ErrMFANotEnabled
+	}
+	if err != nil {
+		return nil, err
+	}
+	if mfa.EnabledAt.Valid {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	step, ok := validateTOTP(mfa.TotpSecret, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+	qtx := s.queries.WithTx(tx)
+
+	rows, err := qtx.EnableUserMFA(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if rows == 0 {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	_, err = qtx.UseMFAStep(ctx, client.UseMFAStepParams{UserID: userID, LastUsedStep: step})
+	if err != nil {
+		return nil, err
+	}
+
+	codes, err := replaceRecoveryCodes(ctx, qtx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+func (s *AuthService) DisableMFA(ctx context.Context, userID uuid.UUID, session SessionInfo, password string, code string) error {
+	user, err := s.queries.GetUserById(ctx, userID)
+	if err != nil {
+		return ErrUserNotFound
+	}
+
+	if err := s.checkAccountPassword(ctx, user, password, session); err != nil {
+		return err
+	}
+
+	if err := s.checkAccountMFACode(ctx, user, code, session); err != nil {
+		return err
+	}
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	qtx := s.queries.WithTx(tx)
+
+	if err := qtx.DeleteUserMFARecoveryCodes(ctx, userID); err != nil {
+		return err
+	}
+	if err := qtx.DeleteUserMFA(ctx, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (s *AuthService) RegenerateRecoveryCodes(ctx context.Context, userID uuid.UUID, session SessionInfo, code string) ([]string, error) {
+	user, err := s.queries.GetUserById(ctx, userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	if err := s.checkAccountMFACode(ctx, user, code, session); err != nil {
+		return nil, err
+	}
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	codes, err := replaceRecoveryCodes(ctx, s.queries.WithTx(tx), userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// createMFAChallenge stands in for the tokens after a correct password. Only
+// its hash is stored
+func (s *AuthService) createMFAChallenge(ctx context.Context, userID uuid.UUID) (string, error) {
+	token := GenerateSecureToken()
+	_, err := s.queries.CreateVerificationToken(ctx, client.CreateVerificationTokenParams{
+		UserID:    userID,
+		Token:     HashToken(token),
+		Type:      tokenTypeMFAChallenge,
+		ExpiresAt: pgtype.Timestamp{Time: time.Now().Add(mfaChallengeDuration), Valid: true},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// VerifyMFAChallenge completes a sign-in started with a password, a magic
+// link or a provider. Wrong codes count towards the sign-in throttle. The
+// challenge is consumed on every attempt, a wrong code means signing in again
+func (s *AuthService) VerifyMFAChallenge(ctx context.Context, mfaToken string, code string, session SessionInfo) (*SignInResult, error) {
+	challenge, err := s.queries.ConsumeVerificationToken(ctx, client.ConsumeVerificationTokenParams{
+		Token: HashToken(mfaToken),
+		Type:  tokenTypeMFAChallenge,
+	})
+	if err != nil {
+		return nil, ErrInvalidMFAChallenge
+	}
+
+	user, err := s.queries.GetUserById(ctx, challenge.UserID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	// Second factor guesses share the password throttle
+	attempt, err := s.startLoginAttempt(ctx, normalizeEmail(user.Email), session, pgtype.UUID{Bytes: user.ID, Valid: true})
+	if err != nil {
+		return nil, err
+	}
+	if attempt.RetryAfter > 0 {
+		return &SignInResult{RetryAfter: attempt.RetryAfter}, ErrTooManyAttempts
+	}
+
+	mfa, err := getEnabledMFA(ctx, s.queries, user.ID)
+	if err != nil {
+		s.discardLoginAttempt(ctx, attempt)
+		return nil, err
+	}
+
+	if err := verifyMFACode(ctx, s.queries, mfa, code); err != nil {
+		if err == ErrInvalidMFACode {
+			s.failLoginAttempt(ctx, attempt, user)
+		} else {
+			s.discardLoginAttempt(ctx, attempt)
+		}
+		return nil, err
+	}
+
+	s.finishLoginAttempt(ctx, attempt, pgtype.UUID{Bytes: user.ID, Valid: true}, true)
+
+	accessToken, refreshToken, err := s.startSession(ctx, user, session)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SignInResult{
+		User:         userToResponse(user),
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+	}, nil
+}
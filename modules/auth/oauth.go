@@ -4,29 +4,160 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"net/http"
+	"strconv"
+	"sync"
 
 	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/endpoints"
 	"golang.org/x/oauth2/google"
 )
 
-var googleOAuthConfig *oauth2.Config
-
-func InitGoogleOAuth() {
-	googleOAuthConfig = &oauth2.Config{
-		ClientID:     AppConfig.GoogleClientID,
-		ClientSecret: AppConfig.GoogleClientSecret,
-		RedirectURL:  AppConfig.GoogleRedirectURL,
-		Scopes: []string{
-			"https://www.googleapis.com/auth/userinfo.email",
-			"https://www.googleapis.com/auth/userinfo.profile",
-		},
-		Endpoint: google.Endpoint,
+const (
+	googleUserInfoURL = "https://www.googleapis.com/oauth2/v2/userinfo"
+	githubAPIURL      = "https://api.github.com"
+)
+
+var ErrUnknownOAuthProvider = errors.New("unknown oauth provider")
+
+// OAuthUserInfo is the identity every provider is normalized to
+type OAuthUserInfo struct {
+	ProviderUserID string
+	Email          string
+	EmailVerified  bool
+	Name           string
+	Picture        string
+}
+
+// OAuthProvider is one identity provider for the authorization code flow.
+// Every provider gets the same PKCE verifier treatment, providers that do not
+// support it simply ignore the extra parameters
+type OAuthProvider interface {
+	Name() string
+	AuthCodeURL(state string, codeVerifier string) string
+	Exchange(ctx context.Context, code string, codeVerifier string) (*oauth2.Token, error)
+	UserInfo(ctx context.Context, token *oauth2.Token) (*OAuthUserInfo, error)
+	Refresh(ctx context.Context, token *oauth2.Token) (*oauth2.Token, error)
+}
+
+type OAuthRegistry struct {
+	mu        sync.RWMutex
+	providers map[string]OAuthProvider
+}
+
+func NewOAuthRegistry() *OAuthRegistry {
+	return &OAuthRegistry{providers: make(map[string]OAuthProvider)}
+}
+
+func (r *OAuthRegistry) Register(provider OAuthProvider) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.providers[provider.Name()] = provider
+}
+
+func (r *OAuthRegistry) Get(name string) (OAuthProvider, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	provider, found := r.providers[name]
+	if !found {
+		return nil, ErrUnknownOAuthProvider
+	}
+	return provider, nil
+}
+
+var oauthProviders = NewOAuthRegistry()
+
+func oauthRedirectURL(provider string) string {
+	return AppConfig.BackendURL + "/auth/oauth/" + provider + "/callback"
+}
+
+// InitOAuthProviders registers every provider that has credentials configured
+func InitOAuthProviders() {
+	if AppConfig.GoogleClientID != "" {
+		oauthProviders.Register(NewGoogleProvider(&oauth2.Config{
+			ClientID:     AppConfig.GoogleClientID,
+			ClientSecret: AppConfig.GoogleClientSecret,
+			RedirectURL:  oauthRedirectURL("google"),
+			Scopes: []string{
+				"https://www.googleapis.com/auth/userinfo.email",
+				"https://www.googleapis.com/auth/userinfo.profile",
+			},
+			Endpoint: google.Endpoint,
+		}, googleUserInfoURL))
 	}
+
+	if AppConfig.GitHubClientID != "" {
+		oauthProviders.Register(NewGitHubProvider(&oauth2.Config{
+			ClientID:     AppConfig.GitHubClientID,
+			ClientSecret: AppConfig.GitHubClientSecret,
+			RedirectURL:  oauthRedirectURL("github"),
+			Scopes:       []string{"read:user", "user:email"},
+			Endpoint:     endpoints.GitHub,
+		}, githubAPIURL))
+	}
+}
+
+// oauthConfigProvider implements the parts every provider shares
+type oauthConfigProvider struct {
+	config *oauth2.Config
+}
+
+func (p oauthConfigProvider) AuthCodeURL(state string, codeVerifier string) string {
+	return p.config.AuthCodeURL(state, oauth2.AccessTypeOffline, oauth2.S256ChallengeOption(codeVerifier))
+}
+
+func (p oauthConfigProvider) Exchange(ctx context.Context, code string, codeVerifier string) (*oauth2.Token, error) {
+	return p.config.Exchange(ctx, code, oauth2.VerifierOption(codeVerifier))
 }
 
-func GetGoogleAuthURL(state string) string {
-	return googleOAuthConfig.AuthCodeURL(state, oauth2.AccessTypeOffline)
+// Refresh trades the refresh token for a new access token. The result keeps
+// the old refresh token when the provider does not send a new one
+func (p oauthConfigProvider) Refresh(ctx context.Context, token *oauth2.Token) (*oauth2.Token, error) {
+	return p.config.TokenSource(ctx, &oauth2.Token{RefreshToken: token.RefreshToken}).Token()
+}
+
+func (p oauthConfigProvider) getJSON(ctx context.Context, token *oauth2.Token, url string, target any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.config.Client(ctx, token).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to get %s: status %d", url, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(body, target)
+}
+
+type GoogleProvider struct {
+	oauthConfigProvider
+	userInfoURL string
+}
+
+func NewGoogleProvider(config *oauth2.Config, userInfoURL string) *GoogleProvider {
+	return &GoogleProvider{
+		oauthConfigProvider: oauthConfigProvider{config: config},
+		userInfoURL:         userInfoURL,
+	}
+}
+
+func (p *GoogleProvider) Name() string {
+	return "google"
 }
 
 type GoogleUserInfo struct {
@@ -39,32 +170,78 @@ type GoogleUserInfo struct {
 	Picture       string `json:"picture"`
 }
 
-func GetGoogleUserInfo(ctx context.Context, code string) (*GoogleUserInfo, *oauth2.Token, error) {
-	token, err := googleOAuthConfig.Exchange(ctx, code)
-	if err != nil {
-		return nil, nil, err
+func (p *GoogleProvider) UserInfo(ctx context.Context, token *oauth2.Token) (*OAuthUserInfo, error) {
+	var userInfo GoogleUserInfo
+	if err := p.getJSON(ctx, token, p.userInfoURL, &userInfo); err != nil {
+		return nil, err
 	}
 
-	client := googleOAuthConfig.Client(ctx, token)
-	resp, err := client.Get("https://www.googleapis.com/oauth2/v2/userinfo")
-	if err != nil {
-		return nil, nil, err
+	return &OAuthUserInfo{
+		ProviderUserID: userInfo.ID,
+		Email:          userInfo.Email,
+		EmailVerified:  userInfo.VerifiedEmail,
+		Name:           userInfo.Name,
+		Picture:        userInfo.Picture,
+	}, nil
+}
+
+type GitHubProvider struct {
+	oauthConfigProvider
+	apiURL string
+}
+
+func NewGitHubProvider(config *oauth2.Config, apiURL string) *GitHubProvider {
+	return &GitHubProvider{
+		oauthConfigProvider: oauthConfigProvider{config: config},
+		apiURL:              apiURL,
 	}
-	defer resp.Body.Close()
+}
+
+func (p *GitHubProvider) Name() string {
+	return "github"
+}
+
+type GitHubUser struct {
+	ID        int64  `json:"id"`
+	Login     string `json:"login"`
+	Name      string `json:"name"`
+	AvatarURL string `json:"avatar_url"`
+}
 
-	if resp.StatusCode != 200 {
-		return nil, nil, errors.New("failed to get user info from Google")
+type GitHubEmail struct {
+	Email    string `json:"email"`
+	Primary  bool   `json:"primary"`
+	Verified bool   `json:"verified"`
+}
+
+// UserInfo reads the profile and the primary email. The email on the
+// profile is only set when the user made it public, so it is not used
+func (p *GitHubProvider) UserInfo(ctx context.Context, token *oauth2.Token) (*OAuthUserInfo, error) {
+	var user GitHubUser
+	if err := p.getJSON(ctx, token, p.apiURL+"/user", &user); err != nil {
+		return nil, err
 	}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, nil, err
+	var emails []GitHubEmail
+	if err := p.getJSON(ctx, token, p.apiURL+"/user/emails", &emails); err != nil {
+		return nil, err
 	}
 
-	var userInfo GoogleUserInfo
-	if err := json.Unmarshal(body, &userInfo); err != nil {
-		return nil, nil, err
+	userInfo := &OAuthUserInfo{
+		ProviderUserID: strconv.FormatInt(user.ID, 10),
+		Name:           user.Name,
+		Picture:        user.AvatarURL,
+	}
+	if userInfo.Name == "" {
+		userInfo.Name = user.Login
+	}
+
+	for _, email := range emails {
+		if email.Primary {
+			userInfo.Email = email.Email
+			userInfo.EmailVerified = email.Verified
+		}
 	}
 
-	return &userInfo, token, nil
+	return userInfo, nil
 }
@@ -0,0 +1,200 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"restorapp/db/client"
+
+	"github.com/charmbracelet/log"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	oauthStateTTL        = 10 * time.Minute
+	oauthExchangeCodeTTL = 2 * time.Minute
+	oauthSweepInterval   = 10 * time.Minute
+)
+
+var ErrOAuthEntryNotFound = errors.New("oauth entry not found or expired")
+
+// OAuthStore keeps the short-lived values of an OAuth sign-in: the state
+// (with its PKCE verifier and, when linking, the user) between redirect and callback, and the one-time
+// exchange code the frontend trades for a session. Consume methods are single
+// use and return ErrOAuthEntryNotFound for unknown or expired keys
+type OAuthStore interface {
+	SaveState(ctx context.Context, state string, entry *oauthStateEntry, ttl time.Duration) error
+	ConsumeState(ctx context.Context, state string) (*oauthStateEntry, error)
+	SaveExchangeCode(ctx context.Context, code string, entry *oauthExchangeEntry, ttl time.Duration) error
+	ConsumeExchangeCode(ctx context.Context, code string) (*oauthExchangeEntry, error)
+	DeleteExpired(ctx context.Context) error
+}
+
+var oauthStore OAuthStore
+
+// startOAuthStoreSweeper removes expired entries in the background until
+// ctx is done
+func startOAuthStoreSweeper(ctx context.Context, store OAuthStore, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := store.DeleteExpired(ctx); err != nil {
+					log.Error("Failed to delete expired OAuth entries", "error", err)
+				}
+			}
+		}
+	}()
+}
+
+// PostgresOAuthStore shares OAuth flows between instances. Keys are hashed
+// and exchange codes only reference the user, tokens are issued when the
+// code is exchanged. A database dump holds nothing that signs anyone in
+type PostgresOAuthStore struct {
+	queries *client.Queries
+}
+
+func NewPostgresOAuthStore(queries *client.Queries) *PostgresOAuthStore {
+	return &PostgresOAuthStore{queries: queries}
+}
+
+func (s *PostgresOAuthStore) SaveState(ctx context.Context, state string, entry *oauthStateEntry, ttl time.Duration) error {
+	return s.queries.CreateOAuthState(ctx, client.CreateOAuthStateParams{
+		StateHash:    HashToken(state),
+		CodeVerifier: entry.CodeVerifier,
+		ExpiresAt:    pgtype.Timestamp{Time: time.Now().Add(ttl), Valid: true},
+		LinkUserID:   pgtype.UUID{Bytes: entry.LinkUserID, Valid: entry.LinkUserID != uuid.Nil},
+	})
+}
+
+func (s *PostgresOAuthStore) ConsumeState(ctx context.Context, state string) (*oauthStateEntry, error) {
+	row, err := s.queries.ConsumeOAuthState(ctx, HashToken(state))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrOAuthEntryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &oauthStateEntry{CodeVerifier: row.CodeVerifier}
+	if row.LinkUserID.Valid {
+		entry.LinkUserID = row.LinkUserID.Bytes
+	}
+	return entry, nil
+}
+
+func (s *PostgresOAuthStore) SaveExchangeCode(ctx context.Context, code string, entry *oauthExchangeEntry, ttl time.Duration) error {
+	return s.queries.CreateOAuthExchangeCode(ctx, client.CreateOAuthExchangeCodeParams{
+		CodeHash:  HashToken(code),
+		UserID:    entry.UserID,
+		ExpiresAt: pgtype.Timestamp{Time: time.Now().Add(ttl), Valid: true},
+	})
+}
+
+func (s *PostgresOAuthStore) ConsumeExchangeCode(ctx context.Context, code string) (*oauthExchangeEntry, error) {
+	userID, err := s.queries.ConsumeOAuthExchangeCode(ctx, HashToken(code))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrOAuthEntryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &oauthExchangeEntry{UserID: userID}, nil
+}
+
+func (s *PostgresOAuthStore) DeleteExpired(ctx context.Context) error {
+	if err := s.queries.DeleteExpiredOAuthStates(ctx); err != nil {
+		return err
+	}
+	return s.queries.DeleteExpiredOAuthExchangeCodes(ctx)
+}
+
+type memoryOAuthState struct {
+	entry     *oauthStateEntry
+	expiresAt time.Time
+}
+
+type memoryOAuthExchangeCode struct {
+	entry     *oauthExchangeEntry
+	expiresAt time.Time
+}
+
+// MemoryOAuthStore is a single-process OAuthStore for tests
+type MemoryOAuthStore struct {
+	mu            sync.Mutex
+	states        map[string]memoryOAuthState
+	exchangeCodes map[string]memoryOAuthExchangeCode
+}
+
+func NewMemoryOAuthStore() *MemoryOAuthStore {
+	return &MemoryOAuthStore{
+		states:        make(map[string]memoryOAuthState),
+		exchangeCodes: make(map[string]memoryOAuthExchangeCode),
+	}
+}
+
+func (s *MemoryOAuthStore) SaveState(ctx context.Context, state string, entry *oauthStateEntry, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.states[state] = memoryOAuthState{entry: entry, expiresAt: time.Now().Add(ttl)}
+	return nil
+}
+
+func (s *MemoryOAuthStore) ConsumeState(ctx context.Context, state string) (*oauthStateEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, exists := s.states[state]
+	delete(s.states, state)
+	if !exists || time.Now().After(stored.expiresAt) {
+		return nil, ErrOAuthEntryNotFound
+	}
+	return stored.entry, nil
+}
+
+func (s *MemoryOAuthStore) SaveExchangeCode(ctx context.Context, code string, entry *oauthExchangeEntry, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.exchangeCodes[code] = memoryOAuthExchangeCode{entry: entry, expiresAt: time.Now().Add(ttl)}
+	return nil
+}
+
+func (s *MemoryOAuthStore) ConsumeExchangeCode(ctx context.Context, code string) (*oauthExchangeEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, exists := s.exchangeCodes[code]
+	delete(s.exchangeCodes, code)
+	if !exists || time.Now().After(stored.expiresAt) {
+		return nil, ErrOAuthEntryNotFound
+	}
+	return stored.entry, nil
+}
+
+func (s *MemoryOAuthStore) DeleteExpired(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for state, stored := range s.states {
+		if now.After(stored.expiresAt) {
+			delete(s.states, state)
+		}
+	}
+	for code, stored := range s.exchangeCodes {
+		if now.After(stored.expiresAt) {
+			delete(s.exchangeCodes, code)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestMemoryOAuthStoreConsumesOnce(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryOAuthStore()
+
+	userID := uuid.New()
+	store.SaveExchangeCode(ctx, "code", &oauthExchangeEntry{UserID: userID}, time.Minute)
+	store.SaveState(ctx, "state", &oauthStateEntry{CodeVerifier: "verifier"}, time.Minute)
+
+	entry, err := store.ConsumeExchangeCode(ctx, "code")
+	if err != nil || entry.UserID != userID {
+		t.Fatalf("first exchange: got %v, %v", entry, err)
+	}
+	if _, err := store.ConsumeExchangeCode(ctx, "code"); err != ErrOAuthEntryNotFound {
+		t.Errorf("second exchange: got %v, want ErrOAuthEntryNotFound", err)
+	}
+
+	state, err := store.ConsumeState(ctx, "state")
+	if err != nil || state.CodeVerifier != "verifier" {
+		t.Fatalf("first state: got %v, %v", state, err)
+	}
+	if _, err := store.ConsumeState(ctx, "state"); err != ErrOAuthEntryNotFound {
+		t.Errorf("second state: got %v, want ErrOAuthEntryNotFound", err)
+	}
+}
+
+func TestMemoryOAuthStoreRejectsExpired(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryOAuthStore()
+
+	store.SaveExchangeCode(ctx, "code", &oauthExchangeEntry{UserID: uuid.New()}, -time.Second)
+	store.SaveState(ctx, "state", &oauthStateEntry{}, -time.Second)
+
+	if _, err := store.ConsumeExchangeCode(ctx, "code"); err != ErrOAuthEntryNotFound {
+		t.Errorf("expired exchange code: got %v, want ErrOAuthEntryNotFound", err)
+	}
+	if _, err := store.ConsumeState(ctx, "state"); err != ErrOAuthEntryNotFound {
+		t.Errorf("expired state: got %v, want ErrOAuthEntryNotFound", err)
+	}
+}
+
+func TestOAuthStoreSweeperDeletesExpired(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store := NewMemoryOAuthStore()
+
+	store.SaveExchangeCode(ctx, "expired", &oauthExchangeEntry{UserID: uuid.New()}, -time.Second)
+	store.SaveState(ctx, "expired", &oauthStateEntry{}, -time.Second)
+	store.SaveExchangeCode(ctx, "live", &oauthExchangeEntry{UserID: uuid.New()}, time.Minute)
+
+	startOAuthStoreSweeper(ctx, store, 10*time.Millisecond)
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		store.mu.Lock()
+		remaining := len(store.states) + len(store.exchangeCodes)
+		store.mu.Unlock()
+		if remaining == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("sweeper left %d entries, want 1", remaining)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if _, err := store.ConsumeExchangeCode(ctx, "live"); err != nil {
+		t.Errorf("live exchange code swept: %v", err)
+	}
+}
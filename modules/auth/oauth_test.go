@@ -0,0 +1,266 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	fakeAuthCode     = "fake-code"
+	fakeAccessToken  = "fake-access-token"
+	fakeRefreshToken = "fake-refresh-token"
+	fakeCodeVerifier = "fake-code-verifier-with-enough-entropy-for-pkce-0123456789"
+)
+
+// newFakeIdentityProvider serves a token endpoint plus the Google and GitHub
+// user endpoints. The token endpoint only accepts the expected code and
+// PKCE verifier, user endpoints only the issued access token
+func newFakeIdentityProvider(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	writeJSON := func(w http.ResponseWriter, v any) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(v)
+	}
+	authorized := func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Header.Get("Authorization") != "Bearer "+fakeAccessToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			return false
+		}
+		return true
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("grant_type") == "refresh_token" {
+			if r.Form.Get("refresh_token") != fakeRefreshToken {
+				w.WriteHeader(http.StatusBadRequest)
+				writeJSON(w, map[string]string{"error": "invalid_grant"})
+				return
+			}
+			writeJSON(w, map[string]any{
+				"access_token": fakeAccessToken,
+				"token_type":   "Bearer",
+				"expires_in":   3600,
+			})
+			return
+		}
+		if r.Form.Get("code") != fakeAuthCode || r.Form.Get("code_verifier") != fakeCodeVerifier {
+			w.WriteHeader(http.StatusBadRequest)
+			writeJSON(w, map[string]string{"error": "invalid_grant"})
+			return
+		}
+		writeJSON(w, map[string]any{
+			"access_token": fakeAccessToken,
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+		})
+	})
+	mux.HandleFunc("/google/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(w, r) {
+			return
+		}
+		writeJSON(w, GoogleUserInfo{
+			ID:            "google-123",
+			Email:         "ana@example.com",
+			VerifiedEmail: true,
+			Name:          "Ana",
+			Picture:       "https://example.com/ana.png",
+		})
+	})
+	mux.HandleFunc("/github/user", func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(w, r) {
+			return
+		}
+		writeJSON(w, GitHubUser{ID: 42, Login: "bruno", AvatarURL: "https://example.com/bruno.png"})
+	})
+	mux.HandleFunc("/github/user/emails", func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(w, r) {
+			return
+		}
+		writeJSON(w, []GitHubEmail{
+			{Email: "old@example.com", Primary: false, Verified: true},
+			{Email: "bruno@example.com", Primary: true, Verified: true},
+		})
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func fakeOAuthConfig(server *httptest.Server) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     "client-id",
+		ClientSecret: "client-secret",
+		RedirectURL:  "http://localhost:8080/auth/oauth/test/callback",
+		Endpoint: oauth2.Endpoint{
+			AuthURL:   server.URL + "/authorize",
+			TokenURL:  server.URL + "/token",
+			AuthStyle: oauth2.AuthStyleInParams,
+		},
+	}
+}
+
+func signInWithProvider(t *testing.T, provider OAuthProvider) *OAuthUserInfo {
+	t.Helper()
+	ctx := context.Background()
+
+	token, err := provider.Exchange(ctx, fakeAuthCode, fakeCodeVerifier)
+	if err != nil {
+		t.Fatalf("exchange: %v", err)
+	}
+
+	userInfo, err := provider.UserInfo(ctx, token)
+	if err != nil {
+		t.Fatalf("user info: %v", err)
+	}
+	return userInfo
+}
+
+func TestGoogleProvider(t *testing.T) {
+	server := newFakeIdentityProvider(t)
+	provider := NewGoogleProvider(fakeOAuthConfig(server), server.URL+"/google/userinfo")
+
+	userInfo := signInWithProvider(t, provider)
+	expected := OAuthUserInfo{
+		ProviderUserID: "google-123",
+		Email:          "ana@example.com",
+		EmailVerified:  true,
+		Name:           "Ana",
+		Picture:        "https://example.com/ana.png",
+	}
+	if *userInfo != expected {
+		t.Errorf("got %+v, want %+v", *userInfo, expected)
+	}
+}
+
+func TestGitHubProvider(t *testing.T) {
+	server := newFakeIdentityProvider(t)
+	provider := NewGitHubProvider(fakeOAuthConfig(server), server.URL+"/github")
+
+	userInfo := signInWithProvider(t, provider)
+	expected := OAuthUserInfo{
+		ProviderUserID: "42",
+		Email:          "bruno@example.com",
+		EmailVerified:  true,
+		Name:           "bruno",
+		Picture:        "https://example.com/bruno.png",
+	}
+	if *userInfo != expected {
+		t.Errorf("got %+v, want %+v", *userInfo, expected)
+	}
+}
+
+func TestNewOAuthUserRequiresVerifiedEmail(t *testing.T) {
+	server := newFakeIdentityProvider(t)
+	provider := NewGitHubProvider(fakeOAuthConfig(server), server.URL+"/github")
+	userInfo := signInWithProvider(t, provider)
+
+	params, err := newOAuthUserParams(userInfo)
+	if err != nil {
+		t.Fatalf("verified email: %v", err)
+	}
+	if params.Email != "bruno@example.com" || !params.EmailVerified.Bool || params.PasswordHash.Valid {
+		t.Errorf("unexpected params %+v", params)
+	}
+
+	// A GitHub primary address the user never verified can not take the email
+	unverified := *userInfo
+	unverified.EmailVerified = false
+	if _, err := newOAuthUserParams(&unverified); err != ErrOAuthEmailNotVerified {
+		t.Errorf("unverified email: got %v, want ErrOAuthEmailNotVerified", err)
+	}
+}
+
+func TestProviderExchangeRejectsWrongVerifier(t *testing.T) {
+	server := newFakeIdentityProvider(t)
+	provider := NewGitHubProvider(fakeOAuthConfig(server), server.URL+"/github")
+
+	if _, err := provider.Exchange(context.Background(), fakeAuthCode, "another-verifier"); err == nil {
+		t.Error("expected exchange with a wrong verifier to fail")
+	}
+}
+
+func TestProviderRefreshKeepsRefreshToken(t *testing.T) {
+	server := newFakeIdentityProvider(t)
+	provider := NewGoogleProvider(fakeOAuthConfig(server), server.URL+"/google/userinfo")
+
+	token, err := provider.Refresh(context.Background(), &oauth2.Token{
+		AccessToken:  "expired-access-token",
+		RefreshToken: fakeRefreshToken,
+	})
+	if err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if token.AccessToken != fakeAccessToken || token.RefreshToken != fakeRefreshToken || !token.Valid() {
+		t.Errorf("unexpected refreshed token %+v", token)
+	}
+}
+
+func TestFreshOAuthToken(t *testing.T) {
+	server := newFakeIdentityProvider(t)
+	provider := NewGoogleProvider(fakeOAuthConfig(server), server.URL+"/google/userinfo")
+	ctx := context.Background()
+	expired := time.Now().Add(-time.Minute)
+
+	// Valid tokens are used as they are
+	valid := &oauth2.Token{AccessToken: "still-valid", RefreshToken: fakeRefreshToken, Expiry: time.Now().Add(time.Hour)}
+	token, refreshed, err := freshOAuthToken(ctx, provider, valid)
+	if err != nil || refreshed || token.AccessToken != "still-valid" {
+		t.Errorf("valid token: got %v, %v, %v", token, refreshed, err)
+	}
+
+	token, refreshed, err = freshOAuthToken(ctx, provider, &oauth2.Token{AccessToken: "expired", RefreshToken: fakeRefreshToken, Expiry: expired})
+	if err != nil || !refreshed || token.AccessToken != fakeAccessToken || !token.Valid() {
+		t.Errorf("expired token: got %v, %v, %v", token, refreshed, err)
+	}
+
+	if _, _, err := freshOAuthToken(ctx, provider, &oauth2.Token{AccessToken: "expired", Expiry: expired}); err != ErrOAuthTokenExpired {
+		t.Errorf("expired without refresh token: got %v, want ErrOAuthTokenExpired", err)
+	}
+
+	if _, _, err := freshOAuthToken(ctx, provider, &oauth2.Token{AccessToken: "expired", RefreshToken: "revoked", Expiry: expired}); err == nil {
+		t.Error("expected a refresh token the provider refuses to fail")
+	}
+}
+
+func TestProviderAuthCodeURL(t *testing.T) {
+	server := newFakeIdentityProvider(t)
+	provider := NewGitHubProvider(fakeOAuthConfig(server), server.URL+"/github")
+
+	authURL, err := url.Parse(provider.AuthCodeURL("some-state", fakeCodeVerifier))
+	if err != nil {
+		t.Fatalf("parse auth url: %v", err)
+	}
+
+	query := authURL.Query()
+	if query.Get("state") != "some-state" {
+		t.Errorf("state = %q", query.Get("state"))
+	}
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") != oauth2.S256ChallengeFromVerifier(fakeCodeVerifier) {
+		t.Errorf("missing PKCE challenge in %s", authURL)
+	}
+}
+
+func TestOAuthRegistry(t *testing.T) {
+	registry := NewOAuthRegistry()
+	registry.Register(NewGitHubProvider(&oauth2.Config{}, githubAPIURL))
+
+	provider, err := registry.Get("github")
+	if err != nil || provider.Name() != "github" {
+		t.Errorf("expected github provider, got %v, %v", provider, err)
+	}
+
+	if _, err := registry.Get("myspace"); err != ErrUnknownOAuthProvider {
+		t.Errorf("expected ErrUnknownOAuthProvider, got %v", err)
+	}
+}
@@ -0,0 +1,241 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"restorapp/db"
+	"restorapp/db/client"
+
+	"github.com/charmbracelet/log"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// Rows re-encrypted per query by ReencryptOAuthTokens
+	oauthTokenReencryptBatchSize = 100
+
+	// Providers drop refresh tokens that go unused for months, so tokens not
+	// refreshed or replaced by a sign in for this long are refreshed
+	oauthTokenRefreshAge            = 30 * 24 * time.Hour
+	oauthTokenRefreshWorkerInterval = time.Hour
+	oauthTokenRefreshBatchSize      = 50
+)
+
+var ErrOAuthTokenExpired = errors.New("oauth token expired and can not be refreshed")
+
+// encryptedOAuthTokens holds the oauth_accounts token columns as stored
+type encryptedOAuthTokens struct {
+	AccessToken  pgtype.Text
+	RefreshToken pgtype.Text
+	ExpiresAt    pgtype.Timestamp
+}
+
+func encryptOAuthTokens(token *oauth2.Token) (encryptedOAuthTokens, error) {
+	accessToken, err := oauthTokenCipher.Encrypt(token.AccessToken)
+	if err != nil {
+		return encryptedOAuthTokens{}, err
+	}
+
+	refreshToken, err := oauthTokenCipher.Encrypt(token.RefreshToken)
+	if err != nil {
+		return encryptedOAuthTokens{}, err
+	}
+
+	return encryptedOAuthTokens{
+		AccessToken:  pgtype.Text{String: accessToken, Valid: true},
+		RefreshToken: pgtype.Text{String: refreshToken, Valid: refreshToken != ""},
+		ExpiresAt:    pgtype.Timestamp{Time: token.Expiry, Valid: !token.Expiry.IsZero()},
+	}, nil
+}
+
+// createOAuthAccount links a provider identity and stores its tokens encrypted
+func (s *AuthService) createOAuthAccount(ctx context.Context, userID uuid.UUID, provider string, providerUserID string, token *oauth2.Token) error {
+	tokens, err := encryptOAuthTokens(token)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.queries.CreateOAuthAccount(ctx, client.CreateOAuthAccountParams{
+		UserID:         userID,
+		Provider:       provider,
+		ProviderUserID: providerUserID,
+		AccessToken:    tokens.AccessToken,
+		RefreshToken:   tokens.RefreshToken,
+		ExpiresAt:      tokens.ExpiresAt,
+	})
+	return err
+}
+
+// storeOAuthTokens replaces the stored tokens. Providers only send a refresh
+// token now and then, so the stored one is kept when none came back
+func (s *AuthService) storeOAuthTokens(ctx context.Context, account client.OauthAccount, token *oauth2.Token) error {
+	tokens, err := encryptOAuthTokens(token)
+	if err != nil {
+		return err
+	}
+	if !tokens.RefreshToken.Valid {
+		tokens.RefreshToken = account.RefreshToken
+	}
+
+	return s.queries.UpdateOAuthTokens(ctx, client.UpdateOAuthTokensParams{
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresAt:    tokens.ExpiresAt,
+		ID:           account.ID,
+	})
+}
+
+// OAuthAccessToken returns a valid access token for calling the provider API
+// on behalf of the user, refreshing and storing it first when it expired
+func (s *AuthService) OAuthAccessToken(ctx context.Context, userID uuid.UUID, providerName string) (string, error) {
+	provider, err := oauthProviders.Get(providerName)
+	if err != nil {
+		return "", err
+	}
+
+	account, err := s.queries.GetOAuthAccountByUserId(ctx, client.GetOAuthAccountByUserIdParams{
+		UserID:   userID,
+		Provider: provider.Name(),
+	})
+	if err != nil {
+		return "", ErrIdentityNotLinked
+	}
+
+	accessToken, err := oauthTokenCipher.Decrypt(account.AccessToken.String)
+	if err != nil {
+		return "", err
+	}
+	refreshToken, err := oauthTokenCipher.Decrypt(account.RefreshToken.String)
+	if err != nil {
+		return "", err
+	}
+
+	token, refreshed, err := freshOAuthToken(ctx, provider, &oauth2.Token{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		Expiry:       account.ExpiresAt.Time,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if refreshed {
+		if err := s.storeOAuthTokens(ctx, account, token); err != nil {
+			return "", err
+		}
+	}
+
+	return token.AccessToken, nil
+}
+
+// freshOAuthToken returns token while it is valid, otherwise a new one from
+// the provider and true
+func freshOAuthToken(ctx context.Context, provider OAuthProvider, token *oauth2.Token) (*oauth2.Token, bool, error) {
+	if token.Valid() {
+		return token, false, nil
+	}
+	if token.RefreshToken == "" {
+		return nil, false, ErrOAuthTokenExpired
+	}
+
+	refreshed, err := provider.Refresh(ctx, token)
+	if err != nil {
+		return nil, false, err
+	}
+	return refreshed, true, nil
+}
+
+// StartOAuthTokenRefresher refreshes stale provider tokens in the background,
+// keeping refresh tokens in use so providers do not revoke them
+func StartOAuthTokenRefresher() {
+	go func() {
+		ticker := time.NewTicker(oauthTokenRefreshWorkerInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			refreshStaleOAuthTokens(context.Background())
+		}
+	}()
+	log.Info("OAuth token refresher started")
+}
+
+// refreshStaleOAuthTokens goes through the most recently used tokens first,
+// so tokens the provider keeps refusing do not hold back the rest
+func refreshStaleOAuthTokens(ctx context.Context) {
+	accounts, err := db.Queries.ListStaleOAuthAccounts(ctx, client.ListStaleOAuthAccountsParams{
+		ExpiresAt: pgtype.Timestamp{Time: time.Now().Add(-oauthTokenRefreshAge), Valid: true},
+		Limit:     oauthTokenRefreshBatchSize,
+	})
+	if err != nil {
+		log.Error("Failed to list stale OAuth tokens", "error", err)
+		return
+	}
+
+	for _, account := range accounts {
+		// Providers that are no longer configured keep their tokens as they are
+		if _, err := oauthProviders.Get(account.Provider); err != nil {
+			continue
+		}
+		if _, err := authService.OAuthAccessToken(ctx, account.UserID, account.Provider); err != nil {
+			log.Warn("Failed to refresh OAuth token", "user", account.UserID, "provider", account.Provider, "error", err)
+		}
+	}
+}
+
+// ReencryptOAuthTokens brings every stored token under the active key of
+// tokenCipher: plaintext tokens get encrypted and tokens under an older key
+// re-encrypted. It returns how many rows changed and is safe to run again
+func ReencryptOAuthTokens(ctx context.Context, queries *client.Queries, tokenCipher *TokenCipher) (int, error) {
+	if !tokenCipher.Enabled() {
+		return 0, errors.New("no active token key configured")
+	}
+
+	updated := 0
+	lastID := uuid.Nil
+	for {
+		accounts, err := queries.ListOAuthAccountsAfter(ctx, client.ListOAuthAccountsAfterParams{
+			ID:    lastID,
+			Limit: oauthTokenReencryptBatchSize,
+		})
+		if err != nil {
+			return updated, err
+		}
+		if len(accounts) == 0 {
+			return updated, nil
+		}
+
+		for _, account := range accounts {
+			lastID = account.ID
+
+			accessToken, accessChanged, err := tokenCipher.Reencrypt(account.AccessToken.String)
+			if err != nil {
+				return updated, err
+			}
+			refreshToken, refreshChanged, err := tokenCipher.Reencrypt(account.RefreshToken.String)
+			if err != nil {
+				return updated, err
+			}
+			if !accessChanged && !refreshChanged {
+				continue
+			}
+
+			// A sign in may have stored new tokens since the row was listed,
+			// those are already under the active key and are left alone
+			rows, err := queries.UpdateOAuthTokensIfUnchanged(ctx, client.UpdateOAuthTokensIfUnchangedParams{
+				AccessToken:     pgtype.Text{String: accessToken, Valid: account.AccessToken.Valid},
+				RefreshToken:    pgtype.Text{String: refreshToken, Valid: account.RefreshToken.Valid},
+				ID:              account.ID,
+				OldAccessToken:  account.AccessToken,
+				OldRefreshToken: account.RefreshToken,
+			})
+			if err != nil {
+				return updated, err
+			}
+			updated += int(rows)
+		}
+	}
+}
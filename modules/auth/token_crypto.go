@@ -0,0 +1,231 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/log"
+)
+
+// OAuth provider tokens are stored with envelope encryption. Every value gets
+// its own random data key that encrypts the token with AES-256-GCM, and the
+// data key is encrypted in turn with a key encryption key. Stored values read
+//
+//	enc:v1:<kid>:<encrypted data key>:<encrypted token>
+//
+// OAUTH_TOKEN_KEYS lists the key encryption keys as comma separated
+// <kid>:<base64 32 byte key> pairs and OAUTH_TOKEN_ACTIVE_KID picks the one
+// new values use. To rotate, add the new key, switch the active kid and run
+// cmd/encrypt-oauth-tokens, which re-encrypts the data keys only. The old key
+// can go once the command is done.
+const (
+	encryptedTokenPrefix = "enc:v1:"
+	tokenKeySize         = 32
+	encryptedTokenParts  = 3 // kid, data key, token
+)
+
+var (
+	ErrUnknownTokenKey    = errors.New("unknown token encryption key")
+	ErrMalformedEncrypted = errors.New("malformed encrypted token")
+)
+
+// TokenCipher encrypts and decrypts stored provider tokens. Without keys it
+// stores tokens as they are, which is only fit for development
+type TokenCipher struct {
+	activeKeyID string
+	keys        map[string]cipher.AEAD
+}
+
+var oauthTokenCipher *TokenCipher
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// ParseTokenKeys reads the OAUTH_TOKEN_KEYS format
+func ParseTokenKeys(spec string, activeKeyID string) (*TokenCipher, error) {
+	tokenCipher := &TokenCipher{activeKeyID: activeKeyID, keys: make(map[string]cipher.AEAD)}
+
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		keyID, encoded, found := strings.Cut(pair, ":")
+		if !found || keyID == "" {
+			return nil, fmt.Errorf("invalid key %q, expected <kid>:<base64 key>", pair)
+		}
+
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", keyID, err)
+		}
+		if len(key) != tokenKeySize {
+			return nil, fmt.Errorf("key %s must be %d bytes, got %d", keyID, tokenKeySize, len(key))
+		}
+
+		aead, err := newGCM(key)
+		if err != nil {
+			return nil, err
+		}
+		tokenCipher.keys[keyID] = aead
+	}
+
+	if activeKeyID != "" && tokenCipher.keys[activeKeyID] == nil {
+		return nil, fmt.Errorf("active key %q not found in the token keys", activeKeyID)
+	}
+	if activeKeyID == "" && len(tokenCipher.keys) > 0 {
+		return nil, errors.New("an active key is required when token keys are set")
+	}
+
+	return tokenCipher, nil
+}
+
+func initOAuthTokenCipher() {
+	var err error
+	oauthTokenCipher, err = ParseTokenKeys(AppConfig.OAuthTokenKeys, AppConfig.OAuthTokenActiveKeyID)
+	if err != nil {
+		log.Fatal("Failed to load OAuth token keys", "error", err)
+	}
+	if !oauthTokenCipher.Enabled() {
+		log.Warn("OAUTH_TOKEN_KEYS not set, OAuth provider tokens are stored unencrypted")
+	}
+}
+
+func (c *TokenCipher) Enabled() bool {
+	return c.activeKeyID != ""
+}
+
+func sealWithNonce(aead cipher.AEAD, plaintext []byte, additionalData []byte) []byte {
+	nonce := make([]byte, aead.NonceSize())
+	rand.Read(nonce)
+	return aead.Seal(nonce, nonce, plaintext, additionalData)
+}
+
+func openWithNonce(aead cipher.AEAD, sealed []byte, additionalData []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrMalformedEncrypted
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, additionalData)
+}
+
+// Encrypt returns the stored form of a token. Empty tokens stay empty
+func (c *TokenCipher) Encrypt(plaintext string) (string, error) {
+	if plaintext == "" || !c.Enabled() {
+		return plaintext, nil
+	}
+
+	dataKey := make([]byte, tokenKeySize)
+	rand.Read(dataKey)
+
+	dataAEAD, err := newGCM(dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	encryptedToken := sealWithNonce(dataAEAD, []byte(plaintext), nil)
+	return c.wrap(dataKey, encryptedToken), nil
+}
+
+// wrap encrypts the data key with the active key. The kid is authenticated
+// with it so a value can not be relabeled to another key
+func (c *TokenCipher) wrap(dataKey []byte, encryptedToken []byte) string {
+	encryptedKey := sealWithNonce(c.keys[c.activeKeyID], dataKey, []byte(c.activeKeyID))
+
+	return encryptedTokenPrefix + c.activeKeyID +
+		":" + base64.RawStdEncoding.EncodeToString(encryptedKey) +
+		":" + base64.RawStdEncoding.EncodeToString(encryptedToken)
+}
+
+// unwrap returns the key ID, data key and encrypted token of a stored value
+func (c *TokenCipher) unwrap(value string) (string, []byte, []byte, error) {
+	parts := strings.Split(strings.TrimPrefix(value, encryptedTokenPrefix), ":")
+	if len(parts) != encryptedTokenParts {
+		return "", nil, nil, ErrMalformedEncrypted
+	}
+
+	keyID := parts[0]
+	keyAEAD, ok := c.keys[keyID]
+	if !ok {
+		return "", nil, nil, ErrUnknownTokenKey
+	}
+
+	encryptedKey, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", nil, nil, ErrMalformedEncrypted
+	}
+	encryptedToken, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", nil, nil, ErrMalformedEncrypted
+	}
+
+	dataKey, err := openWithNonce(keyAEAD, encryptedKey, []byte(keyID))
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	return keyID, dataKey, encryptedToken, nil
+}
+
+func isEncryptedToken(value string) bool {
+	return strings.HasPrefix(value, encryptedTokenPrefix)
+}
+
+// Decrypt reads a stored token. Values written before encryption was enabled
+// are returned as they are
+func (c *TokenCipher) Decrypt(value string) (string, error) {
+	if !isEncryptedToken(value) {
+		return value, nil
+	}
+
+	_, dataKey, encryptedToken, err := c.unwrap(value)
+	if err != nil {
+		return "", err
+	}
+
+	dataAEAD, err := newGCM(dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	plaintext, err := openWithNonce(dataAEAD, encryptedToken, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// Reencrypt brings a stored value under the active key: plaintext values are
+// encrypted, values under an older key get their data key re-encrypted. The
+// second result reports whether the value changed
+func (c *TokenCipher) Reencrypt(value string) (string, bool, error) {
+	if value == "" || !c.Enabled() {
+		return value, false, nil
+	}
+
+	if !isEncryptedToken(value) {
+		encrypted, err := c.Encrypt(value)
+		return encrypted, err == nil, err
+	}
+
+	keyID, dataKey, encryptedToken, err := c.unwrap(value)
+	if err != nil {
+		return "", false, err
+	}
+	if keyID == c.activeKeyID {
+		return value, false, nil
+	}
+
+	return c.wrap(dataKey, encryptedToken), true, nil
+}
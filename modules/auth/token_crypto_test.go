@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func testTokenKey(b byte) string {
+	return base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string(b), tokenKeySize)))
+}
+
+func mustParseTokenKeys(t *testing.T, spec string, activeKeyID string) *TokenCipher {
+	t.Helper()
+	tokenCipher, err := ParseTokenKeys(spec, activeKeyID)
+	if err != nil {
+		t.Fatalf("parse token keys: %v", err)
+	}
+	return tokenCipher
+}
+
+func TestTokenCipherRoundTrip(t *testing.T) {
+	tokenCipher := mustParseTokenKeys(t, "k1:"+testTokenKey('a'), "k1")
+
+	encrypted, err := tokenCipher.Encrypt("ya29.access-token")
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	if !strings.HasPrefix(encrypted, "enc:v1:k1:") || strings.Contains(encrypted, "ya29") {
+		t.Fatalf("unexpected stored form %q", encrypted)
+	}
+
+	again, _ := tokenCipher.Encrypt("ya29.access-token")
+	if again == encrypted {
+		t.Error("encrypting twice gave the same value")
+	}
+
+	decrypted, err := tokenCipher.Decrypt(encrypted)
+	if err != nil || decrypted != "ya29.access-token" {
+		t.Errorf("decrypt = %q, %v", decrypted, err)
+	}
+}
+
+func TestTokenCipherRejectsTampering(t *testing.T) {
+	tokenCipher := mustParseTokenKeys(t, "k1:"+testTokenKey('a')+",k2:"+testTokenKey('b'), "k1")
+	encrypted, _ := tokenCipher.Encrypt("secret")
+
+	// Relabeling the value to another key must not decrypt
+	relabeled := strings.Replace(encrypted, "enc:v1:k1:", "enc:v1:k2:", 1)
+	if _, err := tokenCipher.Decrypt(relabeled); err == nil {
+		t.Error("expected relabeled value to fail")
+	}
+
+	parts := strings.Split(encrypted, ":")
+	token, _ := base64.RawStdEncoding.DecodeString(parts[4])
+	token[len(token)-1] ^= 1
+	parts[4] = base64.RawStdEncoding.EncodeToString(token)
+	if _, err := tokenCipher.Decrypt(strings.Join(parts, ":")); err == nil {
+		t.Error("expected modified ciphertext to fail")
+	}
+}
+
+func TestTokenCipherPlaintextPassthrough(t *testing.T) {
+	disabled := mustParseTokenKeys(t, "", "")
+	stored, _ := disabled.Encrypt("plain")
+	if stored != "plain" {
+		t.Errorf("without keys, stored %q", stored)
+	}
+
+	enabled := mustParseTokenKeys(t, "k1:"+testTokenKey('a'), "k1")
+	decrypted, err := enabled.Decrypt("legacy-plaintext")
+	if err != nil || decrypted != "legacy-plaintext" {
+		t.Errorf("legacy value = %q, %v", decrypted, err)
+	}
+}
+
+func TestTokenCipherReencrypt(t *testing.T) {
+	oldCipher := mustParseTokenKeys(t, "k1:"+testTokenKey('a'), "k1")
+	rotated := mustParseTokenKeys(t, "k1:"+testTokenKey('a')+",k2:"+testTokenKey('b'), "k2")
+	newOnly := mustParseTokenKeys(t, "k2:"+testTokenKey('b'), "k2")
+
+	underOldKey, _ := oldCipher.Encrypt("refresh-token")
+
+	reencrypted, changed, err := rotated.Reencrypt(underOldKey)
+	if err != nil || !changed || !strings.HasPrefix(reencrypted, "enc:v1:k2:") {
+		t.Fatalf("reencrypt = %q, %v, %v", reencrypted, changed, err)
+	}
+
+	// The old key is no longer needed once values are re-encrypted
+	decrypted, err := newOnly.Decrypt(reencrypted)
+	if err != nil || decrypted != "refresh-token" {
+		t.Errorf("decrypt with new key = %q, %v", decrypted, err)
+	}
+
+	if _, changed, _ := rotated.Reencrypt(reencrypted); changed {
+		t.Error("value under the active key was re-encrypted")
+	}
+
+	fromPlaintext, changed, err := rotated.Reencrypt("legacy-plaintext")
+	if err != nil || !changed || !isEncryptedToken(fromPlaintext) {
+		t.Errorf("plaintext reencrypt = %q, %v, %v", fromPlaintext, changed, err)
+	}
+}
+
+func TestParseTokenKeysErrors(t *testing.T) {
+	cases := map[string][2]string{
+		"missing active key": {"k1:" + testTokenKey('a'), "k2"},
+		"no active key":      {"k1:" + testTokenKey('a'), ""},
+		"short key":          {"k1:" + base64.StdEncoding.EncodeToString([]byte("short")), "k1"},
+		"no kid":             {testTokenKey('a'), "k1"},
+	}
+	for name, c := range cases {
+		if _, err := ParseTokenKeys(c[0], c[1]); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// RFC 6238 appendix B vectors for SHA1, truncated to six digits
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ" // "12345678901234567890"
+
+func TestTOTPCodeRFC6238(t *testing.T) {
+	vectors := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, v := range vectors {
+		code, err := totpCode(rfc6238Secret, totpStep(time.Unix(v.unix, 0)))
+		if err != nil {
+			t.Fatalf("totpCode: %v", err)
+		}
+		if code != v.code {
+			t.Errorf("at %d got %s, want %s", v.unix, code, v.code)
+		}
+	}
+}
+
+func TestValidateTOTPWindow(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	step := totpStep(now)
+
+	for _, offset := range []int64{-1, 0, 1} {
+		code, _ := totpCode(rfc6238Secret, step+offset)
+		got, ok := validateTOTP(rfc6238Secret, code, now)
+		if !ok || got != step+offset {
+			t.Errorf("offset %d: got step %d, ok %v", offset, got, ok)
+		}
+	}
+
+	code, _ := totpCode(rfc6238Secret, step+2)
+	if _, ok := validateTOTP(rfc6238Secret, code, now); ok {
+		t.Error("code two periods ahead should be rejected")
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri := totpURI("ABC", "ana@example.com")
+	if !strings.HasPrefix(uri, "otpauth://totp/Trompeventas:ana@example.com?") {
+		t.Errorf("unexpected uri %s", uri)
+	}
+	if !strings.Contains(uri, "secret=ABC") || !strings.Contains(uri, "issuer=Trompeventas") {
+		t.Errorf("missing parameters in %s", uri)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes := generateRecoveryCodes()
+	if len(codes) != mfaRecoveryCodeCount {
+		t.Fatalf("got %d codes", len(codes))
+	}
+	if hashRecoveryCode(codes[0]) != hashRecoveryCode(" "+strings.ToUpper(strings.ReplaceAll(codes[0], "-", ""))) {
+		t.Error("recovery codes should match regardless of case and dashes")
+	}
+}
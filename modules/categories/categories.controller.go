@@ -11,6 +11,7 @@ func CategoriesController(router *gin.Engine) {
 
 	categoriesRouter := router.Group("/categories")
 	categoriesRouter.Use(auth.AuthMiddleware())
+	categoriesRouter.Use(auth.RequireRole(auth.RoleAdmin))
 	categoriesRouter.POST("/", createCategoriesHandler)
 	categoriesRouter.DELETE("/:id", deleteCategoriesHandler)
 	categoriesRouter.PUT("/:id", updateCategoriesHandler)
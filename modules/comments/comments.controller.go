@@ -10,14 +10,18 @@ func CommentsController(router *gin.Engine) {
 	// Public with optional auth (to include user's votes)
 	router.GET("/products/:id/comments", auth.OptionalAuthMiddleware(), getCommentsHandler)
 
+	// Live comment and vote events (SSE), optional auth so sellers can follow
+	// their own hidden listings
+	router.GET("/products/:id/comments/stream", auth.OptionalAuthMiddleware(), streamCommentsHandler)
+
 	// Authenticated routes for comment CRUD
 	productComments := router.Group("/products/:id/comments")
-	productComments.Use(auth.AuthMiddleware())
+	productComments.Use(auth.AuthMiddleware(auth.ScopeCommentsWrite))
 	productComments.POST("/", createCommentHandler)
 
 	// Comment-level routes (delete, vote)
 	commentRoutes := router.Group("/comments")
-	commentRoutes.Use(auth.AuthMiddleware())
+	commentRoutes.Use(auth.AuthMiddleware(auth.ScopeCommentsWrite))
 	commentRoutes.DELETE("/:id", deleteCommentHandler)
 	commentRoutes.PUT("/:id/vote", voteCommentHandler)
 	commentRoutes.DELETE("/:id/vote", removeVoteHandler)
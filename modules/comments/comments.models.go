@@ -31,3 +31,12 @@ type CreateCommentRequest struct {
 type VoteRequest struct {
 	VoteType string `json:"voteType"`
 }
+
+type CommentDeletedEvent struct {
+	ID string `json:"id"`
+}
+
+type VoteChangedEvent struct {
+	CommentID  string     `json:"commentId"`
+	VoteCounts VoteCounts `json:"voteCounts"`
+}
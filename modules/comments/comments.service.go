@@ -1,19 +1,28 @@
 package comments
 
 import (
+	"context"
+	"errors"
+	"io"
 	"net/http"
 	"strings"
 	"time"
 
 	"restorapp/db"
 	"restorapp/db/client"
+	"restorapp/modules/products"
+	"restorapp/modules/realtime"
 
 	"github.com/charmbracelet/log"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+// Keeps proxies from closing idle streams
+const streamHeartbeatInterval = 25 * time.Second
+
 func formatTimestamp(t pgtype.Timestamp) string {
 	if !t.Valid {
 		return ""
@@ -29,6 +38,12 @@ func getCommentsHandler(ctx *gin.Context) {
 		return
 	}
 
+	product, err := db.Queries.GetProductById(ctx, productUUID)
+	if err != nil || !products.CanViewProduct(ctx, product) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+
 	userID := ctx.GetString("userId")
 	var comments []CommentResponse
 
@@ -161,7 +176,7 @@ func createCommentHandler(ctx *gin.Context) {
 
 	comment, err := db.Queries.CreateComment(ctx, client.CreateCommentParams{
 		ProductID: productUUID,
-		UserID:    userUUID,
+		UserID:    pgtype.UUID{Bytes: userUUID, Valid: true},
 		ParentID:  parentID,
 		Content:   content,
 	})
@@ -185,7 +200,7 @@ func createCommentHandler(ctx *gin.Context) {
 		responseParentID = &s
 	}
 
-	ctx.JSON(http.StatusCreated, CommentResponse{
+	response := CommentResponse{
 		ID:        comment.ID.String(),
 		ProductID: comment.ProductID.String(),
 		UserID:    comment.UserID.String(),
@@ -202,7 +217,11 @@ func createCommentHandler(ctx *gin.Context) {
 			Dislikes: 0,
 		},
 		UserVote: "",
-	})
+	}
+
+	realtime.Publish(ctx, realtime.ProductTopic(comment.ProductID), realtime.EventCommentCreated, response)
+
+	ctx.JSON(http.StatusCreated, response)
 }
 
 func deleteCommentHandler(ctx *gin.Context) {
@@ -224,16 +243,24 @@ func deleteCommentHandler(ctx *gin.Context) {
 		return
 	}
 
-	err = db.Queries.DeleteComment(ctx, client.DeleteCommentParams{
+	productUUID, err := db.Queries.DeleteComment(ctx, client.DeleteCommentParams{
 		ID:     commentUUID,
-		UserID: userUUID,
+		UserID: pgtype.UUID{Bytes: userUUID, Valid: true},
 	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+		return
+	}
 	if err != nil {
 		log.Error("Failed to delete comment", "error", err)
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete comment"})
 		return
 	}
 
+	realtime.Publish(ctx, realtime.ProductTopic(productUUID), realtime.EventCommentDeleted, CommentDeletedEvent{
+		ID: commentUUID.String(),
+	})
+
 	ctx.JSON(http.StatusOK, gin.H{"message": "Comment deleted successfully"})
 }
 
@@ -278,6 +305,8 @@ func voteCommentHandler(ctx *gin.Context) {
 		return
 	}
 
+	publishVoteChanged(ctx, commentUUID)
+
 	ctx.JSON(http.StatusOK, gin.H{"voteType": vote.VoteType})
 }
 
@@ -310,5 +339,71 @@ func removeVoteHandler(ctx *gin.Context) {
 		return
 	}
 
+	publishVoteChanged(ctx, commentUUID)
+
 	ctx.JSON(http.StatusOK, gin.H{"message": "Vote removed successfully"})
 }
+
+func publishVoteChanged(ctx context.Context, commentUUID uuid.UUID) {
+	counts, err := db.Queries.GetCommentVoteCounts(ctx, commentUUID)
+	if err != nil {
+		log.Error("Failed to get vote counts", "error", err)
+		return
+	}
+
+	realtime.Publish(ctx, realtime.ProductTopic(counts.ProductID), realtime.EventVoteChanged, VoteChangedEvent{
+		CommentID: counts.ID.String(),
+		VoteCounts: VoteCounts{
+			Likes:    counts.Likes,
+			Dislikes: counts.Dislikes,
+		},
+	})
+}
+
+// streamCommentsHandler pushes comment and vote events for a product as
+// Server-Sent Events until the client disconnects
+func streamCommentsHandler(ctx *gin.Context) {
+	productIdParam := ctx.Param("id")
+	productUUID, err := uuid.Parse(productIdParam)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return
+	}
+
+	product, err := db.Queries.GetProductById(ctx, productUUID)
+	if err != nil || !products.CanViewProduct(ctx, product) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+
+	events, unsubscribe := realtime.DefaultHub.Subscribe(realtime.ProductTopic(productUUID))
+	defer unsubscribe()
+
+	heartbeat := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	ctx.Header("Content-Type", "text/event-stream")
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("Connection", "keep-alive")
+	// Disable response buffering on nginx based proxies
+	ctx.Header("X-Accel-Buffering", "no")
+	// Send headers right away so the client knows the stream is open
+	ctx.Status(http.StatusOK)
+	ctx.Writer.Flush()
+
+	ctx.Stream(func(w io.Writer) bool {
+		select {
+		case <-ctx.Request.Context().Done():
+			return false
+		case event, ok := <-events:
+			if !ok {
+				return false
+			}
+			ctx.SSEvent(event.Type, event.Data)
+			return true
+		case <-heartbeat.C:
+			ctx.SSEvent("ping", "")
+			return true
+		}
+	})
+}
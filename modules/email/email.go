@@ -2,7 +2,9 @@ package email
 
 import (
 	"fmt"
+	"html"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/charmbracelet/log"
@@ -23,20 +25,134 @@ func InitResendClient() {
 }
 
 func SendVerificationEmail(toEmail, userName, verificationLink string) error {
+	return sendTemplateEmail(toEmail, "Verifica tu Email - Trompeventas", "verification_email.html", map[string]string{
+		"{{USER_NAME}}":         userName,
+		"{{VERIFICATION_LINK}}": verificationLink,
+	})
+}
+
+func SendPasswordResetEmail(toEmail, userName, resetLink, expiresIn string) error {
+	return sendTemplateEmail(toEmail, "Restablece tu Contraseña - Trompeventas", "password_reset_email.html", map[string]string{
+		"{{USER_NAME}}":  userName,
+		"{{RESET_LINK}}": resetLink,
+		"{{EXPIRES_IN}}": expiresIn,
+	})
+}
+
+func SendMagicLinkEmail(toEmail, userName, signInLink, expiresIn string) error {
+	return sendTemplateEmail(toEmail, "Inicia Sesión en Trompeventas", "magic_link_email.html", map[string]string{
+		"{{USER_NAME}}":    userName,
+		"{{SIGN_IN_LINK}}": signInLink,
+		"{{EXPIRES_IN}}":   expiresIn,
+	})
+}
+
+func SendAccountLockedEmail(toEmail, userName, ipAddress, lockedFor, resetLink string) error {
+	return sendTemplateEmail(toEmail, "Inicio de Sesión Bloqueado - Trompeventas", "account_locked_email.html", map[string]string{
+		"{{USER_NAME}}":  userName,
+		"{{IP_ADDRESS}}": ipAddress,
+		"{{LOCKED_FOR}}": lockedFor,
+		"{{RESET_LINK}}": resetLink,
+	})
+}
+
+func SendEmailChangeEmail(toEmail, userName, confirmLink, expiresIn string) error {
+	return sendTemplateEmail(toEmail, "Confirma tu Nuevo Email - Trompeventas", "email_change_email.html", map[string]string{
+		"{{USER_NAME}}":    userName,
+		"{{CONFIRM_LINK}}": confirmLink,
+		"{{EXPIRES_IN}}":   expiresIn,
+	})
+}
+
+func SendEmailChangeNoticeEmail(toEmail, userName, newEmail, resetLink string) error {
+	return sendTemplateEmail(toEmail, "Cambio de Email Solicitado - Trompeventas", "email_change_notice_email.html", map[string]string{
+		"{{USER_NAME}}":  userName,
+		"{{NEW_EMAIL}}":  html.EscapeString(newEmail),
+		"{{RESET_LINK}}": resetLink,
+	})
+}
+
+func SendAccountDeletionEmail(toEmail, userName, deletionDate, signInLink string) error {
+	return sendTemplateEmail(toEmail, "Eliminación de Cuenta Programada - Trompeventas", "account_deletion_email.html", map[string]string{
+		"{{USER_NAME}}":     userName,
+		"{{DELETION_DATE}}": deletionDate,
+		"{{SIGN_IN_LINK}}":  signInLink,
+	})
+}
+
+// FavoriteUpdate is one line of the favorites digest
+type FavoriteUpdate struct {
+	ProductName string
+	ProductLink string
+	OldPrice    int64
+	NewPrice    int64
+	Sold        bool
+}
+
+func SendFavoritesDigestEmail(toEmail, userName, favoritesLink string, updates []FavoriteUpdate) error {
+	var items strings.Builder
+	for _, update := range updates {
+		detail := fmt.Sprintf("Bajó de %s a <strong>%s</strong>", formatPrice(update.OldPrice), formatPrice(update.NewPrice))
+		if update.Sold {
+			detail = "Este producto fue vendido"
+		}
+		fmt.Fprintf(&items, `                <div class="info-box">
+                  <p>
+                    <a href="%s"><strong>%s</strong></a><br />
+                    %s
+                  </p>
+                </div>
+`, update.ProductLink, html.EscapeString(update.ProductName), detail)
+	}
+
+	subject := "Novedades de tus Favoritos - Trompeventas"
+	if len(updates) == 1 {
+		subject = fmt.Sprintf("%s - Novedades de tus Favoritos", updates[0].ProductName)
+	}
+
+	return sendTemplateEmail(toEmail, subject, "favorites_digest_email.html", map[string]string{
+		"{{USER_NAME}}":      userName,
+		"{{ITEMS}}":          strings.TrimRight(items.String(), "\n"),
+		"{{FAVORITES_LINK}}": favoritesLink,
+	})
+}
+
+// formatPrice renders CLP amounts with dot thousands separators, e.g. $1.250.000
+func formatPrice(price int64) string {
+	digits := strconv.FormatInt(price, 10)
+	sign := ""
+	if strings.HasPrefix(digits, "-") {
+		sign, digits = "-", digits[1:]
+	}
+
+	var out strings.Builder
+	for i, digit := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			out.WriteByte('.')
+		}
+		out.WriteRune(digit)
+	}
+	return sign + "$" + out.String()
+}
+
+// sendTemplateEmail fills the placeholders of a template in
+// modules/email/templates and sends it through Resend
+func sendTemplateEmail(toEmail, subject, templateName string, replacements map[string]string) error {
 	// Check if EmailClient is initialized
 	if EmailClient == nil {
 		return fmt.Errorf("email client not initialized - check RESEND_API_KEY env variable")
 	}
 
 	// Read template
-	templateBytes, err := os.ReadFile("modules/email/templates/verification_email.html")
+	templateBytes, err := os.ReadFile("modules/email/templates/" + templateName)
 	if err != nil {
 		return fmt.Errorf("failed to read email template: %w", err)
 	}
 
 	htmlContent := string(templateBytes)
-	htmlContent = strings.ReplaceAll(htmlContent, "{{USER_NAME}}", userName)
-	htmlContent = strings.ReplaceAll(htmlContent, "{{VERIFICATION_LINK}}", verificationLink)
+	for placeholder, value := range replacements {
+		htmlContent = strings.ReplaceAll(htmlContent, placeholder, value)
+	}
 
 	fromEmail := os.Getenv("EMAIL_FROM")
 	if fromEmail == "" {
@@ -46,19 +162,19 @@ func SendVerificationEmail(toEmail, userName, verificationLink string) error {
 	params := &resend.SendEmailRequest{
 		From:    fromEmail,
 		To:      []string{toEmail},
-		Subject: "Verifica tu Email - Trompeventas",
+		Subject: subject,
 		Html:    htmlContent,
 	}
 
-	log.Infof("Sending verification email to: %s", toEmail)
+	log.Infof("Sending %s to: %s", templateName, toEmail)
 	log.Debugf("Email from: %s | Subject: %s", params.From, params.Subject)
 
 	sent, err := EmailClient.Emails.Send(params)
 	if err != nil {
-		log.Error("Failed to send verification email", "error", err, "to", toEmail)
-		return fmt.Errorf("failed to send verification email: %w", err)
+		log.Error("Failed to send email", "error", err, "template", templateName, "to", toEmail)
+		return fmt.Errorf("failed to send email: %w", err)
 	}
 
-	log.Infof("Verification email sent successfully! ID: %s", sent.Id)
+	log.Infof("Email sent successfully! ID: %s", sent.Id)
 	return nil
 }
@@ -0,0 +1,28 @@
+package messages
+
+import (
+	"restorapp/modules/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+func MessagesController(router *gin.Engine) {
+	// Start (or resume) a conversation with the seller from a product page
+	productConversations := router.Group("/products/:id/conversations")
+	productConversations.Use(auth.AuthMiddleware())
+	productConversations.Use(auth.EmailVerifiedMiddleware())
+	productConversations.POST("/", startConversationHandler)
+
+	// Inbox
+	router.GET("/conversations", auth.AuthMiddleware(), getConversationsHandler)
+
+	conversations := router.Group("/conversations")
+	conversations.Use(auth.AuthMiddleware())
+	conversations.GET("/:id/messages", getMessagesHandler)
+	conversations.POST("/:id/read", markConversationReadHandler)
+
+	sendMessages := router.Group("/conversations")
+	sendMessages.Use(auth.AuthMiddleware())
+	sendMessages.Use(auth.EmailVerifiedMiddleware())
+	sendMessages.POST("/:id/messages", sendMessageHandler)
+}
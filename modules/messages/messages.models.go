@@ -0,0 +1,40 @@
+package messages
+
+type Counterpart struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Image string `json:"image"`
+}
+
+type ConversationResponse struct {
+	ID            string      `json:"id"`
+	ProductID     string      `json:"productId"`
+	ProductName   string      `json:"productName"`
+	Role          string      `json:"role"` // "buyer" or "seller", from the current user's point of view
+	Counterpart   Counterpart `json:"counterpart"`
+	LastMessage   string      `json:"lastMessage"`
+	LastMessageAt string      `json:"lastMessageAt"`
+	UnreadCount   int64       `json:"unreadCount"`
+	CreatedAt     string      `json:"createdAt"`
+}
+
+type MessageResponse struct {
+	ID             string `json:"id"`
+	ConversationID string `json:"conversationId"`
+	SenderID       string `json:"senderId"`
+	Content        string `json:"content"`
+	CreatedAt      string `json:"createdAt"`
+}
+
+type MessagesPage struct {
+	Messages   []MessageResponse `json:"messages"`
+	NextCursor *string           `json:"nextCursor"`
+}
+
+type StartConversationRequest struct {
+	Content string `json:"content" binding:"max=2000"`
+}
+
+type SendMessageRequest struct {
+	Content string `json:"content" binding:"max=2000"`
+}
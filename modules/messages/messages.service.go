@@ -0,0 +1,369 @@
+package messages
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"restorapp/db"
+	"restorapp/db/client"
+	"restorapp/modules/pagination"
+	"restorapp/modules/products"
+
+	"github.com/charmbracelet/log"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	defaultMessagesPageSize = 30
+	maxMessagesPageSize     = 100
+)
+
+func formatTimestamp(t pgtype.Timestamp) string {
+	if !t.Valid {
+		return ""
+	}
+	return t.Time.Format(time.RFC3339)
+}
+
+func messageToResponse(message client.Message) MessageResponse {
+	return MessageResponse{
+		ID:             message.ID.String(),
+		ConversationID: message.ConversationID.String(),
+		SenderID:       message.SenderID.String(),
+		Content:        message.Content,
+		CreatedAt:      formatTimestamp(message.CreatedAt),
+	}
+}
+
+func isParticipant(conversation client.Conversation, userUUID uuid.UUID) bool {
+	return conversation.BuyerID == userUUID || conversation.SellerID == userUUID
+}
+
+// postMessage stores a message and bumps the conversation in both inboxes.
+// The sender has obviously read everything up to their own message
+func postMessage(ctx context.Context, conversationID uuid.UUID, senderID uuid.UUID, content string) (client.Message, error) {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return client.Message{}, err
+	}
+	defer tx.Rollback(context.Background())
+
+	qtx := db.Queries.WithTx(tx)
+
+	message, err := qtx.CreateMessage(ctx, client.CreateMessageParams{
+		ConversationID: conversationID,
+		SenderID:       senderID,
+		Content:        content,
+	})
+	if err != nil {
+		return client.Message{}, err
+	}
+
+	err = qtx.UpdateConversationLastMessageAt(ctx, client.UpdateConversationLastMessageAtParams{
+		LastMessageAt: message.CreatedAt,
+		ID:            conversationID,
+	})
+	if err != nil {
+		return client.Message{}, err
+	}
+
+	err = qtx.MarkConversationRead(ctx, client.MarkConversationReadParams{
+		UserID: senderID,
+		ID:     conversationID,
+	})
+	if err != nil {
+		return client.Message{}, err
+	}
+
+	return message, tx.Commit(ctx)
+}
+
+func startConversationHandler(ctx *gin.Context) {
+	productUUID, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return
+	}
+
+	userID := ctx.GetString("userId")
+	if userID == "" {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	// The body is optional, a conversation can start without a message
+	var req StartConversationRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil && err != io.EOF {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	// Drafts, paused and expired listings cannot be asked about
+	product, err := db.Queries.GetProductById(ctx, productUUID)
+	if err != nil || !products.CanViewProduct(ctx, product) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+
+	// The seller is whoever published the product
+	if !product.UserID.Valid {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "This product has no seller to contact"})
+		return
+	}
+	sellerUUID := uuid.UUID(product.UserID.Bytes)
+	if sellerUUID == userUUID {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "You cannot start a conversation with yourself"})
+		return
+	}
+
+	conversation, err := db.Queries.CreateConversation(ctx, client.CreateConversationParams{
+		ProductID: productUUID,
+		BuyerID:   userUUID,
+		SellerID:  sellerUUID,
+	})
+	if err != nil {
+		log.Error("Failed to create conversation", "error", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start conversation"})
+		return
+	}
+
+	var firstMessage *MessageResponse
+	content := strings.TrimSpace(req.Content)
+	if content != "" {
+		message, err := postMessage(ctx, conversation.ID, userUUID, content)
+		if err != nil {
+			log.Error("Failed to send message", "error", err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send message"})
+			return
+		}
+		response := messageToResponse(message)
+		firstMessage = &response
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{
+		"conversationId": conversation.ID.String(),
+		"message":        firstMessage,
+	})
+}
+
+func getConversationsHandler(ctx *gin.Context) {
+	userID := ctx.GetString("userId")
+	if userID == "" {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	rows, err := db.Queries.GetUserConversations(ctx, userUUID)
+	if err != nil {
+		log.Error("Failed to get conversations", "error", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get conversations"})
+		return
+	}
+
+	conversations := []ConversationResponse{}
+	var totalUnread int64
+	for _, row := range rows {
+		role := "buyer"
+		if row.SellerID == userUUID {
+			role = "seller"
+		}
+
+		conversations = append(conversations, ConversationResponse{
+			ID:          row.ID.String(),
+			ProductID:   row.ProductID.String(),
+			ProductName: row.ProductName,
+			Role:        role,
+			Counterpart: Counterpart{
+				ID:    row.CounterpartID.String(),
+				Name:  row.CounterpartName,
+				Image: row.CounterpartImage.String,
+			},
+			LastMessage:   row.LastMessage,
+			LastMessageAt: formatTimestamp(row.LastMessageAt),
+			UnreadCount:   row.UnreadCount,
+			CreatedAt:     formatTimestamp(row.CreatedAt),
+		})
+		totalUnread += row.UnreadCount
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"conversations": conversations,
+		"unreadCount":   totalUnread,
+	})
+}
+
+func getMessagesHandler(ctx *gin.Context) {
+	conversationUUID, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid conversation ID"})
+		return
+	}
+
+	userID := ctx.GetString("userId")
+	if userID == "" {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	conversation, err := db.Queries.GetConversationById(ctx, conversationUUID)
+	if err != nil || !isParticipant(conversation, userUUID) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
+		return
+	}
+
+	pageSize := int32(defaultMessagesPageSize)
+	if limitParam := ctx.Query("limit"); limitParam != "" {
+		limit, err := strconv.Atoi(limitParam)
+		if err != nil || limit <= 0 {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+		pageSize = int32(min(limit, maxMessagesPageSize))
+	}
+
+	params := client.GetConversationMessagesParams{
+		ConversationID: conversationUUID,
+		// Fetch one extra row to know whether there is a next page
+		PageLimit: pageSize + 1,
+	}
+
+	if cursor := ctx.Query("cursor"); cursor != "" {
+		createdAt, id, err := pagination.DecodeCursor(cursor)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
+		params.CursorCreatedAt = createdAt
+		params.CursorID = id
+	}
+
+	rows, err := db.Queries.GetConversationMessages(ctx, params)
+	if err != nil {
+		log.Error("Failed to get messages", "error", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get messages"})
+		return
+	}
+
+	var nextCursor *string
+	if len(rows) > int(pageSize) {
+		rows = rows[:pageSize]
+		last := rows[len(rows)-1]
+		cursor := pagination.EncodeCursor(last.CreatedAt, last.ID)
+		nextCursor = &cursor
+	}
+
+	// Newest first, the client walks back in time with nextCursor
+	messages := []MessageResponse{}
+	for _, row := range rows {
+		messages = append(messages, messageToResponse(row))
+	}
+
+	ctx.JSON(http.StatusOK, MessagesPage{
+		Messages:   messages,
+		NextCursor: nextCursor,
+	})
+}
+
+func sendMessageHandler(ctx *gin.Context) {
+	conversationUUID, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid conversation ID"})
+		return
+	}
+
+	userID := ctx.GetString("userId")
+	if userID == "" {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req SendMessageRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	content := strings.TrimSpace(req.Content)
+	if content == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Content is required"})
+		return
+	}
+
+	conversation, err := db.Queries.GetConversationById(ctx, conversationUUID)
+	if err != nil || !isParticipant(conversation, userUUID) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
+		return
+	}
+
+	message, err := postMessage(ctx, conversation.ID, userUUID, content)
+	if err != nil {
+		log.Error("Failed to send message", "error", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send message"})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, messageToResponse(message))
+}
+
+func markConversationReadHandler(ctx *gin.Context) {
+	conversationUUID, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid conversation ID"})
+		return
+	}
+
+	userID := ctx.GetString("userId")
+	if userID == "" {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	conversation, err := db.Queries.GetConversationById(ctx, conversationUUID)
+	if err != nil || !isParticipant(conversation, userUUID) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
+		return
+	}
+
+	err = db.Queries.MarkConversationRead(ctx, client.MarkConversationReadParams{
+		UserID: userUUID,
+		ID:     conversation.ID,
+	})
+	if err != nil {
+		log.Error("Failed to mark conversation as read", "error", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark conversation as read"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Conversation marked as read"})
+}
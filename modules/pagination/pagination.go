@@ -0,0 +1,48 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+var ErrMalformedCursor = errors.New("malformed cursor")
+
+// EncodeCursor builds a keyset cursor from the (created_at, id) pair of the
+// last row of a page. Queries that use it order by created_at DESC, id DESC
+func EncodeCursor(createdAt pgtype.Timestamp, id uuid.UUID) string {
+	raw := fmt.Sprintf("%d|%s", createdAt.Time.UnixMicro(), id.String())
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeCursor returns the pair EncodeCursor was given, ready to be passed
+// as the cursor parameters of a query
+func DecodeCursor(cursor string) (pgtype.Timestamp, pgtype.UUID, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return pgtype.Timestamp{}, pgtype.UUID{}, err
+	}
+
+	createdAtStr, idStr, found := strings.Cut(string(raw), "|")
+	if !found {
+		return pgtype.Timestamp{}, pgtype.UUID{}, ErrMalformedCursor
+	}
+
+	micros, err := strconv.ParseInt(createdAtStr, 10, 64)
+	if err != nil {
+		return pgtype.Timestamp{}, pgtype.UUID{}, err
+	}
+
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return pgtype.Timestamp{}, pgtype.UUID{}, err
+	}
+
+	return pgtype.Timestamp{Time: time.UnixMicro(micros).UTC(), Valid: true}, pgtype.UUID{Bytes: id, Valid: true}, nil
+}
@@ -0,0 +1,171 @@
+package products
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"restorapp/db"
+	"restorapp/db/client"
+	"restorapp/modules/auth"
+	"restorapp/modules/email"
+
+	"github.com/charmbracelet/log"
+)
+
+const (
+	favoriteNotificationPriceDrop = "price_drop"
+	favoriteNotificationSold      = "sold"
+
+	favoritesNotifierInterval = time.Minute
+	// A user gets at most one digest per interval, everything that happens
+	// in between is batched into the next one
+	favoritesDigestInterval = time.Hour
+	// Users handled per tick, the rest wait for the next tick
+	favoritesDigestBatchSize = 50
+	// Resend allows 2 requests per second
+	favoritesEmailDelay = 600 * time.Millisecond
+)
+
+// enqueueFavoriteNotifications records price drops and sales for everyone
+// who favorited the product. Emails are sent later by the notifier
+func enqueueFavoriteNotifications(ctx context.Context, before client.Product, after client.Product) {
+	kind := ""
+	switch {
+	case after.State == productStateSold && before.State != productStateSold:
+		kind = favoriteNotificationSold
+	case after.Price < before.Price:
+		kind = favoriteNotificationPriceDrop
+	default:
+		return
+	}
+
+	err := db.Queries.EnqueueFavoriteNotifications(ctx, client.EnqueueFavoriteNotificationsParams{
+		Kind:      kind,
+		OldPrice:  before.Price,
+		NewPrice:  after.Price,
+		ProductID: after.ID,
+	})
+	if err != nil {
+		log.Error("Failed to enqueue favorite notifications", "product", after.ID, "error", err)
+	}
+}
+
+// StartFavoritesNotifier sends pending favorite notifications as digests
+// in the background
+func StartFavoritesNotifier() {
+	if email.EmailClient == nil {
+		log.Warn("Email client not initialized - favorite notifications will not be sent")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(favoritesNotifierInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			sendFavoritesDigests(context.Background())
+		}
+	}()
+	log.Info("Favorites notifier started")
+}
+
+func sendFavoritesDigests(ctx context.Context) {
+	sentSince := pgtype.Timestamp{Time: time.Now().Add(-favoritesDigestInterval), Valid: true}
+	userIDs, err := db.Queries.GetUsersWithPendingFavoriteNotifications(ctx, client.GetUsersWithPendingFavoriteNotificationsParams{
+		SentSince: sentSince,
+		MaxUsers:  favoritesDigestBatchSize,
+	})
+	if err != nil {
+		log.Error("Failed to get pending favorite notifications", "error", err)
+		return
+	}
+
+	for _, userID := range userIDs {
+		if err := sendFavoritesDigest(ctx, userID, sentSince); err != nil {
+			log.Error("Failed to send favorites digest", "user", userID, "error", err)
+		}
+		time.Sleep(favoritesEmailDelay)
+	}
+}
+
+// sendFavoritesDigest holds the user's pending rows locked until the digest
+// is out, so another instance running the notifier skips them instead of
+// sending the same digest twice
+func sendFavoritesDigest(ctx context.Context, userID uuid.UUID, sentSince pgtype.Timestamp) error {
+	user, err := db.Queries.GetUserById(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(context.Background())
+
+	qtx := db.Queries.WithTx(tx)
+
+	notifications, err := qtx.ClaimFavoriteNotificationsByUser(ctx, client.ClaimFavoriteNotificationsByUserParams{
+		UserID:    userID,
+		SentSince: sentSince,
+	})
+	if err != nil {
+		return err
+	}
+	if len(notifications) == 0 {
+		return nil
+	}
+
+	// Several changes to the same product collapse into one line, from the
+	// first known price to the latest one
+	ids := make([]uuid.UUID, 0, len(notifications))
+	updates := []email.FavoriteUpdate{}
+	byProduct := make(map[uuid.UUID]int)
+	for _, notification := range notifications {
+		ids = append(ids, notification.ID)
+
+		i, found := byProduct[notification.ProductID]
+		if !found {
+			byProduct[notification.ProductID] = len(updates)
+			updates = append(updates, email.FavoriteUpdate{
+				ProductName: notification.ProductName,
+				ProductLink: fmt.Sprintf("%s/products/%s", auth.AppConfig.FrontendURL, notification.ProductID),
+				OldPrice:    notification.OldPrice,
+			})
+			i = len(updates) - 1
+		}
+		updates[i].NewPrice = notification.NewPrice
+		updates[i].Sold = updates[i].Sold || notification.Kind == favoriteNotificationSold
+	}
+
+	// Drop products whose price went back up before the digest went out
+	pending := updates[:0]
+	for _, update := range updates {
+		if update.Sold || update.NewPrice < update.OldPrice {
+			pending = append(pending, update)
+		}
+	}
+
+	// Nothing left to tell, drop the rows without starting the user's
+	// digest interval
+	if len(pending) == 0 {
+		if err := qtx.DeleteFavoriteNotifications(ctx, ids); err != nil {
+			return err
+		}
+		return tx.Commit(ctx)
+	}
+
+	favoritesLink := auth.AppConfig.FrontendURL + "/favorites"
+	if err := email.SendFavoritesDigestEmail(user.Email, user.Name, favoritesLink, pending); err != nil {
+		return err
+	}
+
+	if err := qtx.MarkFavoriteNotificationsSent(ctx, ids); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,119 @@
+package products
+
+import (
+	"net/http"
+
+	"github.com/google/uuid"
+
+	"restorapp/db"
+	"restorapp/db/client"
+
+	"github.com/charmbracelet/log"
+	"github.com/gin-gonic/gin"
+)
+
+func addFavoriteHandler(ctx *gin.Context) {
+	userID := ctx.GetString("userId")
+	if userID == "" {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	productUUID, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return
+	}
+
+	if _, err := db.Queries.GetProductById(ctx, productUUID); err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+
+	err = db.Queries.AddFavorite(ctx, client.AddFavoriteParams{
+		UserID:    userUUID,
+		ProductID: productUUID,
+	})
+	if err != nil {
+		log.Error("Error adding favorite", "error", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add favorite"})
+		return
+	}
+
+	favoriteCount, err := db.Queries.GetProductFavoriteCount(ctx, productUUID)
+	if err != nil {
+		log.Error("Error counting favorites", "error", err)
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"favorited": true, "favoriteCount": favoriteCount})
+}
+
+func removeFavoriteHandler(ctx *gin.Context) {
+	userID := ctx.GetString("userId")
+	if userID == "" {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	productUUID, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return
+	}
+
+	err = db.Queries.RemoveFavorite(ctx, client.RemoveFavoriteParams{
+		UserID:    userUUID,
+		ProductID: productUUID,
+	})
+	if err != nil {
+		log.Error("Error removing favorite", "error", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove favorite"})
+		return
+	}
+
+	favoriteCount, err := db.Queries.GetProductFavoriteCount(ctx, productUUID)
+	if err != nil {
+		log.Error("Error counting favorites", "error", err)
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"favorited": false, "favoriteCount": favoriteCount})
+}
+
+func getMyFavoritesHandler(ctx *gin.Context) {
+	userID := ctx.GetString("userId")
+	if userID == "" {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	products, err := db.Queries.GetUserFavoriteProducts(ctx, userUUID)
+	if err != nil {
+		log.Error("Could not retrieve favorite products", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get favorites"})
+		return
+	}
+
+	productList, err := loadProductsImagesAndCategories(ctx, products)
+	if err != nil {
+		log.Error("Could not retrieve images and categories of favorite products", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get favorites"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, productList)
+}
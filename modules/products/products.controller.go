@@ -8,17 +8,43 @@ import (
 
 func ProductsController(router *gin.Engine) {
 	router.GET("/products", getProductsHandler)
-	router.GET("/products/:id", getProductByIdHandler)
+	router.GET("/products/suggestions", getProductSuggestionsHandler)
+	router.GET("/products/:id", auth.OptionalAuthMiddleware(), getProductByIdHandler)
+	router.GET("/users/:id/profile", getSellerProfileHandler)
+	router.GET("/users/:id/products", getSellerProductsHandler)
+
+	// API keys reach the listing routes with the matching scope
+	readProducts := router.Group("/products")
+	readProducts.Use(auth.AuthMiddleware(auth.ScopeProductsRead))
+	readProducts.GET("/me", getMyProductsHandler)
+	readProducts.GET("/me/:id/history", getMyProductStateHistoryHandler)
+	readProducts.GET("/me/:id/images", getMyProductImagesHandler)
+	readProducts.GET("/me/import/:importId", getProductImportHandler)
 
 	products := router.Group("/products")
-	products.Use(auth.AuthMiddleware())
+	products.Use(auth.AuthMiddleware(auth.ScopeProductsWrite))
 	products.POST("/", createProductHandler)
-	products.GET("/me", getMyProductsHandler)
 	products.DELETE("/me/:id", deleteMyProductHandler)
 	products.PUT("/me/:id", updateMyProductHandler)
+	products.POST("/me/:id/reserve", reserveMyProductHandler)
+	products.POST("/me/:id/mark-sold", markMyProductSoldHandler)
+	products.POST("/me/:id/relist", relistMyProductHandler)
+	products.POST("/me/:id/images", addMyProductImagesHandler)
+	products.PUT("/me/:id/images", reorderMyProductImagesHandler)
+	products.DELETE("/me/:id/images/:imageId", deleteMyProductImageHandler)
+
+	favorites := router.Group("/products")
+	favorites.Use(auth.AuthMiddleware())
+	favorites.POST("/:id/favorite", addFavoriteHandler)
+	favorites.DELETE("/:id/favorite", removeFavoriteHandler)
+
+	me := router.Group("/me")
+	me.Use(auth.AuthMiddleware())
+	me.GET("/favorites", getMyFavoritesHandler)
 
 	publish := router.Group("/products")
-	publish.Use(auth.AuthMiddleware())
+	publish.Use(auth.AuthMiddleware(auth.ScopeProductsWrite))
 	publish.Use(auth.EmailVerifiedMiddleware())
 	publish.POST("/publish", publishProductHandler)
+	publish.POST("/me/import", importProductsHandler)
 }
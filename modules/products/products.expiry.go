@@ -0,0 +1,44 @@
+package products
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"restorapp/db"
+
+	"github.com/charmbracelet/log"
+)
+
+const (
+	productExpiryWorkerInterval = time.Hour
+	// Available listings nobody touched for this long expire, relisting
+	// brings them back
+	productExpiryAge = 60 * 24 * time.Hour
+)
+
+// StartProductExpiryWorker expires stale listings in the background
+func StartProductExpiryWorker() {
+	go func() {
+		ticker := time.NewTicker(productExpiryWorkerInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			expireStaleProducts(context.Background())
+		}
+	}()
+	log.Info("Product expiry worker started")
+}
+
+func expireStaleProducts(ctx context.Context) {
+	updatedBefore := pgtype.Timestamp{Time: time.Now().Add(-productExpiryAge), Valid: true}
+	rows, err := db.Queries.ExpireStaleProducts(ctx, updatedBefore)
+	if err != nil {
+		log.Error("Failed to expire stale products", "error", err)
+		return
+	}
+	if rows > 0 {
+		log.Info("Products expired", "count", rows)
+	}
+}
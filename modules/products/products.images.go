@@ -0,0 +1,361 @@
+package products
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/google/uuid"
+
+	"restorapp/db"
+	"restorapp/db/client"
+	"restorapp/modules/storage"
+
+	"github.com/charmbracelet/log"
+	"github.com/gin-gonic/gin"
+)
+
+const maxProductImages = 10
+
+var (
+	ErrTooManyImages     = errors.New("too many product images")
+	ErrLastImage         = errors.New("product needs at least one image")
+	ErrImageNotFound     = errors.New("product image not found")
+	ErrInvalidImageOrder = errors.New("image order must list every image once")
+	ErrInvalidCoverImage = errors.New("cover image is not an image of the product")
+	ErrImageNotUploaded  = errors.New("image is not an upload from our storage")
+)
+
+type AddProductImagesRequest struct {
+	ImageUrls []string `json:"imageUrls"`
+}
+
+// ReorderProductImagesRequest lists every image of the product in the new
+// order. The cover stays the same unless CoverImageID is set
+type ReorderProductImagesRequest struct {
+	ImageIDs     []string `json:"imageIds"`
+	CoverImageID string   `json:"coverImageId"`
+}
+
+// addProductImages appends images after the existing ones. A product without
+// images gets the first one as cover
+func addProductImages(ctx context.Context, productID uuid.UUID, imageUrls []string) error {
+	for _, imageUrl := range imageUrls {
+		if !storage.IsUploadedURL(imageUrl) {
+			return ErrImageNotUploaded
+		}
+	}
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(context.Background())
+
+	qtx := db.Queries.WithTx(tx)
+
+	// Locking the images keeps concurrent changes from mixing up positions
+	existing, err := qtx.LockProductImages(ctx, productID)
+	if err != nil {
+		return err
+	}
+	if len(existing)+len(imageUrls) > maxProductImages {
+		return ErrTooManyImages
+	}
+
+	for i, imageUrl := range imageUrls {
+		_, err := qtx.CreateProductImage(ctx, client.CreateProductImageParams{
+			ProductID: productID,
+			ImageUrl:  imageUrl,
+			Position:  int32(len(existing) + i),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := qtx.EnsureProductImageCover(ctx, productID); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// deleteProductImage removes an image and closes the gap it leaves. Removing
+// the cover makes the next image the cover
+func deleteProductImage(ctx context.Context, productID uuid.UUID, imageID uuid.UUID) error {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(context.Background())
+
+	qtx := db.Queries.WithTx(tx)
+
+	existing, err := qtx.LockProductImages(ctx, productID)
+	if err != nil {
+		return err
+	}
+
+	found := slices.ContainsFunc(existing, func(image client.ProductImage) bool {
+		return image.ID == imageID
+	})
+	if !found {
+		return ErrImageNotFound
+	}
+	if len(existing) == 1 {
+		return ErrLastImage
+	}
+
+	_, err = qtx.DeleteProductImage(ctx, client.DeleteProductImageParams{
+		ID:        imageID,
+		ProductID: productID,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := qtx.CompactProductImagePositions(ctx, productID); err != nil {
+		return err
+	}
+	if err := qtx.EnsureProductImageCover(ctx, productID); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// reorderProductImages sets every position, and the cover, in one transaction
+func reorderProductImages(ctx context.Context, productID uuid.UUID, imageIDs []uuid.UUID, coverID uuid.UUID) error {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(context.Background())
+
+	qtx := db.Queries.WithTx(tx)
+
+	existing, err := qtx.LockProductImages(ctx, productID)
+	if err != nil {
+		return err
+	}
+
+	productImages := make(map[uuid.UUID]bool, len(existing))
+	for _, image := range existing {
+		productImages[image.ID] = true
+		if coverID == uuid.Nil && image.IsCover {
+			coverID = image.ID
+		}
+	}
+
+	if len(imageIDs) != len(existing) {
+		return ErrInvalidImageOrder
+	}
+	listed := make(map[uuid.UUID]bool, len(imageIDs))
+	for _, id := range imageIDs {
+		if !productImages[id] || listed[id] {
+			return ErrInvalidImageOrder
+		}
+		listed[id] = true
+	}
+	if coverID != uuid.Nil && !productImages[coverID] {
+		return ErrInvalidCoverImage
+	}
+
+	// Only one cover is allowed at a time, so the old one goes first
+	if err := qtx.ClearProductImageCover(ctx, productID); err != nil {
+		return err
+	}
+
+	_, err = qtx.ReorderProductImages(ctx, client.ReorderProductImagesParams{
+		CoverID:   coverID,
+		ImageIds:  imageIDs,
+		ProductID: productID,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := qtx.EnsureProductImageCover(ctx, productID); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// getMyProduct loads the product in the path and checks it belongs to the
+// signed-in user. It writes the error response when it returns false
+func getMyProduct(ctx *gin.Context) (client.Product, bool) {
+	userID := ctx.GetString("userId")
+	if userID == "" {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return client.Product{}, false
+	}
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return client.Product{}, false
+	}
+
+	productUUID, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return client.Product{}, false
+	}
+
+	product, err := db.Queries.GetProductById(ctx, productUUID)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return client.Product{}, false
+	}
+	if product.UserID.Bytes != userUUID {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "Not your product"})
+		return client.Product{}, false
+	}
+
+	return product, true
+}
+
+// respondWithProductImages answers with the images in display order
+func respondWithProductImages(ctx *gin.Context, status int, productID uuid.UUID, message string) {
+	images, err := db.Queries.GetProductImagesById(ctx, productID)
+	if err != nil {
+		log.Error("Could not retrieve product images", "error", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get product images"})
+		return
+	}
+	if images == nil {
+		images = []client.ProductImage{}
+	}
+
+	if message == "" {
+		ctx.JSON(status, gin.H{"images": images})
+		return
+	}
+	ctx.JSON(status, gin.H{"message": message, "images": images})
+}
+
+func getMyProductImagesHandler(ctx *gin.Context) {
+	product, ok := getMyProduct(ctx)
+	if !ok {
+		return
+	}
+
+	respondWithProductImages(ctx, http.StatusOK, product.ID, "")
+}
+
+func addMyProductImagesHandler(ctx *gin.Context) {
+	product, ok := getMyProduct(ctx)
+	if !ok {
+		return
+	}
+
+	var req AddProductImagesRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if len(req.ImageUrls) == 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "At least one image is required"})
+		return
+	}
+
+	err := addProductImages(ctx, product.ID, req.ImageUrls)
+	if err == ErrImageNotUploaded {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Images must be uploaded through /upload/presign"})
+		return
+	}
+	if err == ErrTooManyImages {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("A product can have at most %d images", maxProductImages)})
+		return
+	}
+	if err != nil {
+		log.Error("Failed to add product images", "error", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add product images"})
+		return
+	}
+
+	respondWithProductImages(ctx, http.StatusCreated, product.ID, "Images added successfully")
+}
+
+func deleteMyProductImageHandler(ctx *gin.Context) {
+	product, ok := getMyProduct(ctx)
+	if !ok {
+		return
+	}
+
+	imageUUID, err := uuid.Parse(ctx.Param("imageId"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid image ID"})
+		return
+	}
+
+	err = deleteProductImage(ctx, product.ID, imageUUID)
+	if err == ErrImageNotFound {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+		return
+	}
+	if err == ErrLastImage {
+		ctx.JSON(http.StatusConflict, gin.H{"error": "A product needs at least one image"})
+		return
+	}
+	if err != nil {
+		log.Error("Failed to delete product image", "error", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete product image"})
+		return
+	}
+
+	respondWithProductImages(ctx, http.StatusOK, product.ID, "Image deleted successfully")
+}
+
+func reorderMyProductImagesHandler(ctx *gin.Context) {
+	product, ok := getMyProduct(ctx)
+	if !ok {
+		return
+	}
+
+	var req ReorderProductImagesRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	imageIDs := make([]uuid.UUID, 0, len(req.ImageIDs))
+	for _, idStr := range req.ImageIDs {
+		id, err := uuid.Parse(idStr)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid image ID: %s", idStr)})
+			return
+		}
+		imageIDs = append(imageIDs, id)
+	}
+
+	coverID := uuid.Nil
+	if req.CoverImageID != "" {
+		id, err := uuid.Parse(req.CoverImageID)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cover image ID"})
+			return
+		}
+		coverID = id
+	}
+
+	err := reorderProductImages(ctx, product.ID, imageIDs, coverID)
+	if err == ErrInvalidImageOrder {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "imageIds must list every image of the product once"})
+		return
+	}
+	if err == ErrInvalidCoverImage {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "The cover must be one of the product images"})
+		return
+	}
+	if err != nil {
+		log.Error("Failed to reorder product images", "error", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reorder product images"})
+		return
+	}
+
+	respondWithProductImages(ctx, http.StatusOK, product.ID, "Images reordered successfully")
+}
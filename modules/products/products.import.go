@@ -0,0 +1,524 @@
+package products
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"restorapp/db"
+	"restorapp/db/client"
+
+	"github.com/charmbracelet/log"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxImportRows  = 1000
+	maxImportBytes = 5 << 20
+	// Separates several categories or image URLs in one CSV cell
+	importListSeparator = "|"
+
+	productImportWorkerInterval = 5 * time.Second
+)
+
+var (
+	ErrImportEmpty         = errors.New("import has no rows")
+	ErrImportTooManyRows   = fmt.Errorf("import has more than %d rows", maxImportRows)
+	ErrImportFormat        = errors.New("unsupported import format")
+	ErrImportMissingColumn = errors.New("missing required column")
+
+	// The row was already recorded, another worker took the import over
+	errImportTakenOver = errors.New("import taken over")
+)
+
+// Wakes the worker when an import is queued instead of waiting for the tick
+var productImportQueued = make(chan struct{}, 1)
+
+// ImportProductRow is one listing of an import. Categories are names
+type ImportProductRow struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Price       int64    `json:"price"`
+	Condition   string   `json:"condition"`
+	Negotiable  string   `json:"negotiable"`
+	Categories  []string `json:"categories"`
+	ImageUrls   []string `json:"imageUrls"`
+}
+
+// importItem is a row as stored with the job. Rows that could not be parsed
+// are kept too, so their error is reported in order with the others
+type importItem struct {
+	Row        int              `json:"row"`
+	Product    ImportProductRow `json:"product"`
+	ParseError string           `json:"parseError,omitempty"`
+}
+
+type ImportRowError struct {
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+type ProductImportResponse struct {
+	ID            uuid.UUID        `json:"id"`
+	Status        string           `json:"status"`
+	TotalRows     int32            `json:"totalRows"`
+	ProcessedRows int32            `json:"processedRows"`
+	CreatedRows   int32            `json:"createdRows"`
+	FailedRows    int32            `json:"failedRows"`
+	Errors        []ImportRowError `json:"errors"`
+	CreatedAt     time.Time        `json:"createdAt"`
+	StartedAt     *time.Time       `json:"startedAt"`
+	FinishedAt    *time.Time       `json:"finishedAt"`
+}
+
+// parseImport reads CSV or JSON lines depending on the content type
+func parseImport(contentType string, body []byte) ([]importItem, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, ErrImportFormat
+	}
+
+	var items []importItem
+	switch mediaType {
+	case "text/csv":
+		items, err = parseImportCSV(body)
+	case "application/x-ndjson", "application/jsonl", "application/json":
+		items, err = parseImportJSONLines(body)
+	default:
+		return nil, ErrImportFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(items) == 0 {
+		return nil, ErrImportEmpty
+	}
+	if len(items) > maxImportRows {
+		return nil, ErrImportTooManyRows
+	}
+	return items, nil
+}
+
+// parseImportCSV expects a header row. Column names are matched ignoring case
+// and order, categories and imageUrls hold several values separated by |
+func parseImportCSV(body []byte) ([]importItem, error) {
+	reader := csv.NewReader(bytes.NewReader(body))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, ErrImportEmpty
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"name", "price"} {
+		if _, found := columns[required]; !found {
+			return nil, fmt.Errorf("%w: %s", ErrImportMissingColumn, required)
+		}
+	}
+
+	var items []importItem
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return items, nil
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, err
+			}
+			items = append(items, importItem{Row: parseErr.StartLine, ParseError: "Invalid CSV row"})
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+
+		field := func(name string) string {
+			i, found := columns[name]
+			if !found || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+
+		item := importItem{
+			Row: line,
+			Product: ImportProductRow{
+				Name:        field("name"),
+				Description: field("description"),
+				Condition:   field("condition"),
+				Negotiable:  field("negotiable"),
+				Categories:  splitImportList(field("categories")),
+				ImageUrls:   splitImportList(field("imageurls")),
+			},
+		}
+
+		if price := field("price"); price != "" {
+			item.Product.Price, err = strconv.ParseInt(price, 10, 64)
+			if err != nil {
+				item.ParseError = "Price must be a whole number"
+			}
+		}
+
+		items = append(items, item)
+	}
+}
+
+func splitImportList(value string) []string {
+	var values []string
+	for _, part := range strings.Split(value, importListSeparator) {
+		if part = strings.TrimSpace(part); part != "" {
+			values = append(values, part)
+		}
+	}
+	return values
+}
+
+// parseImportJSONLines reads one JSON object per line, blank lines are
+// skipped. A JSON array of objects is accepted too
+func parseImportJSONLines(body []byte) ([]importItem, error) {
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '[' {
+		var rows []json.RawMessage
+		if err := json.Unmarshal(trimmed, &rows); err != nil {
+			return nil, err
+		}
+
+		items := make([]importItem, 0, len(rows))
+		for i, row := range rows {
+			item := importItem{Row: i + 1}
+			if err := json.Unmarshal(row, &item.Product); err != nil {
+				item.ParseError = "Invalid JSON"
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	}
+
+	var items []importItem
+	for i, line := range bytes.Split(body, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		item := importItem{Row: i + 1}
+		if err := json.Unmarshal(line, &item.Product); err != nil {
+			item.ParseError = "Invalid JSON"
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// validateImportItem applies the publish rules to a row and resolves its
+// category names
+func validateImportItem(item importItem, categories map[string]uuid.UUID) (PublishProductRequest, []uuid.UUID, error) {
+	if item.ParseError != "" {
+		return PublishProductRequest{}, nil, errors.New(item.ParseError)
+	}
+
+	req := PublishProductRequest{
+		Name:        item.Product.Name,
+		Description: item.Product.Description,
+		Price:       item.Product.Price,
+		Condition:   item.Product.Condition,
+		Negotiable:  item.Product.Negotiable,
+		ImageUrls:   item.Product.ImageUrls,
+	}
+	if err := validatePublishRequest(&req); err != nil {
+		return PublishProductRequest{}, nil, err
+	}
+
+	categoryIDs := make([]uuid.UUID, 0, len(item.Product.Categories))
+	for _, name := range item.Product.Categories {
+		categoryID, found := categories[strings.ToLower(strings.TrimSpace(name))]
+		if !found {
+			return PublishProductRequest{}, nil, fmt.Errorf("Unknown category: %s", name)
+		}
+		categoryIDs = append(categoryIDs, categoryID)
+	}
+
+	return req, categoryIDs, nil
+}
+
+// StartProductImportWorker runs queued imports in the background. Jobs left
+// running by a stopped instance are picked up again once they go quiet
+func StartProductImportWorker() {
+	go func() {
+		ticker := time.NewTicker(productImportWorkerInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+			case <-productImportQueued:
+			}
+			runProductImports(context.Background())
+		}
+	}()
+	log.Info("Product import worker started")
+}
+
+func runProductImports(ctx context.Context) {
+	for {
+		job, err := db.Queries.ClaimProductImport(ctx)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return
+		}
+		if err != nil {
+			log.Error("Failed to claim product import", "error", err)
+			return
+		}
+
+		if err := runProductImport(ctx, job); err != nil {
+			log.Error("Product import stopped", "import", job.ID, "error", err)
+		}
+	}
+}
+
+func runProductImport(ctx context.Context, job client.ProductImport) error {
+	var items []importItem
+	if err := json.Unmarshal(job.Items, &items); err != nil {
+		return err
+	}
+
+	allCategories, err := db.Queries.GetCategories(ctx)
+	if err != nil {
+		return err
+	}
+	categories := make(map[string]uuid.UUID, len(allCategories))
+	for _, category := range allCategories {
+		categories[strings.ToLower(category.Name)] = category.ID
+	}
+
+	// Resumes after the last recorded row
+	for processed := job.ProcessedRows; int(processed) < len(items); processed++ {
+		err := importProductRow(ctx, job, processed, items[processed], categories)
+		if err == errImportTakenOver {
+			log.Warn("Product import taken over by another worker", "import", job.ID)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := db.Queries.FinishProductImport(ctx, job.ID); err != nil {
+		return err
+	}
+
+	log.Info("Product import completed", "import", job.ID, "rows", len(items))
+	return nil
+}
+
+// importProductRow creates one listing and records the row in the same
+// transaction, so a resumed import never creates a row twice
+func importProductRow(ctx context.Context, job client.ProductImport, processed int32, item importItem, categories map[string]uuid.UUID) error {
+	req, categoryIDs, err := validateImportItem(item, categories)
+	if err != nil {
+		return recordImportRowFailed(ctx, job.ID, processed, item.Row, err.Error())
+	}
+
+	err = createImportedProduct(ctx, job, processed, req, categoryIDs)
+	if err == nil || err == errImportTakenOver {
+		return err
+	}
+
+	log.Error("Failed to import product row", "import", job.ID, "row", item.Row, "error", err)
+	return recordImportRowFailed(ctx, job.ID, processed, item.Row, "Could not save the product")
+}
+
+func createImportedProduct(ctx context.Context, job client.ProductImport, processed int32, req PublishProductRequest, categoryIDs []uuid.UUID) error {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(context.Background())
+
+	qtx := db.Queries.WithTx(tx)
+
+	if _, _, err := publishProduct(ctx, qtx, job.UserID, req, categoryIDs); err != nil {
+		return err
+	}
+
+	rows, err := qtx.RecordProductImportRowCreated(ctx, client.RecordProductImportRowCreatedParams{
+		ID:            job.ID,
+		ProcessedRows: processed,
+	})
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return errImportTakenOver
+	}
+
+	return tx.Commit(ctx)
+}
+
+func recordImportRowFailed(ctx context.Context, importID uuid.UUID, processed int32, row int, message string) error {
+	rowError, err := json.Marshal([]ImportRowError{{Row: row, Error: message}})
+	if err != nil {
+		return err
+	}
+
+	rows, err := db.Queries.RecordProductImportRowFailed(ctx, client.RecordProductImportRowFailedParams{
+		RowError:      rowError,
+		ID:            importID,
+		ProcessedRows: processed,
+	})
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return errImportTakenOver
+	}
+	return nil
+}
+
+func productImportToResponse(job client.GetProductImportByUserRow) (ProductImportResponse, error) {
+	response := ProductImportResponse{
+		ID:            job.ID,
+		Status:        job.Status,
+		TotalRows:     job.TotalRows,
+		ProcessedRows: job.ProcessedRows,
+		CreatedRows:   job.CreatedRows,
+		FailedRows:    job.FailedRows,
+		Errors:        []ImportRowError{},
+		CreatedAt:     job.CreatedAt.Time,
+	}
+	if job.StartedAt.Valid {
+		response.StartedAt = &job.StartedAt.Time
+	}
+	if job.FinishedAt.Valid {
+		response.FinishedAt = &job.FinishedAt.Time
+	}
+
+	if err := json.Unmarshal(job.RowErrors, &response.Errors); err != nil {
+		return ProductImportResponse{}, err
+	}
+	return response, nil
+}
+
+func importProductsHandler(ctx *gin.Context) {
+	userID := ctx.GetString("userId")
+	if userID == "" {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxImportBytes))
+	if err != nil {
+		ctx.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Imports are limited to %d MB", maxImportBytes>>20)})
+		return
+	}
+
+	items, err := parseImport(ctx.ContentType(), body)
+	if err == ErrImportFormat {
+		ctx.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Send text/csv or application/x-ndjson"})
+		return
+	}
+	if err == ErrImportEmpty {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "The import has no rows"})
+		return
+	}
+	if err == ErrImportTooManyRows {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Imports are limited to %d rows", maxImportRows)})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Could not read the import: %v", err)})
+		return
+	}
+
+	encoded, err := json.Marshal(items)
+	if err != nil {
+		log.Error("Failed to encode product import", "error", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue import"})
+		return
+	}
+
+	job, err := db.Queries.CreateProductImport(ctx, client.CreateProductImportParams{
+		UserID:    userUUID,
+		Items:     encoded,
+		TotalRows: int32(len(items)),
+	})
+	if err != nil {
+		log.Error("Failed to create product import", "error", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue import"})
+		return
+	}
+
+	select {
+	case productImportQueued <- struct{}{}:
+	default:
+	}
+
+	ctx.JSON(http.StatusAccepted, gin.H{
+		"message":   "Import queued",
+		"importId":  job.ID,
+		"status":    job.Status,
+		"totalRows": job.TotalRows,
+	})
+}
+
+func getProductImportHandler(ctx *gin.Context) {
+	userID := ctx.GetString("userId")
+	if userID == "" {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	importUUID, err := uuid.Parse(ctx.Param("importId"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid import ID"})
+		return
+	}
+
+	job, err := db.Queries.GetProductImportByUser(ctx, client.GetProductImportByUserParams{
+		ID:     importUUID,
+		UserID: userUUID,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Import not found"})
+		return
+	}
+
+	response, err := productImportToResponse(job)
+	if err != nil {
+		log.Error("Failed to decode product import errors", "error", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get import"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
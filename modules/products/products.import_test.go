@@ -0,0 +1,125 @@
+package products
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"restorapp/modules/storage"
+
+	"github.com/google/uuid"
+)
+
+func TestParseImportCSV(t *testing.T) {
+	body := "Name,Price,categories,imageUrls,Condition\n" +
+		"Bicicleta,50000,Deportes | Hogar,https://img/1.jpg|https://img/2.jpg,Usado\n" +
+		"\n" +
+		"Mesa,barata,,https://img/3.jpg\n"
+
+	items, err := parseImport("text/csv; charset=utf-8", []byte(body))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("got %d items, want 2", len(items))
+	}
+
+	first := items[0]
+	if first.Row != 2 || first.Product.Name != "Bicicleta" || first.Product.Price != 50000 || first.Product.Condition != "Usado" {
+		t.Errorf("unexpected first row %+v", first)
+	}
+	if !slices.Equal(first.Product.Categories, []string{"Deportes", "Hogar"}) {
+		t.Errorf("categories = %v", first.Product.Categories)
+	}
+	if !slices.Equal(first.Product.ImageUrls, []string{"https://img/1.jpg", "https://img/2.jpg"}) {
+		t.Errorf("image urls = %v", first.Product.ImageUrls)
+	}
+
+	if items[1].Row != 4 || items[1].ParseError == "" {
+		t.Errorf("expected a price error on row 4, got %+v", items[1])
+	}
+}
+
+func TestParseImportCSVMissingColumn(t *testing.T) {
+	_, err := parseImport("text/csv", []byte("name,description\nMesa,Roble\n"))
+	if !errors.Is(err, ErrImportMissingColumn) {
+		t.Errorf("expected missing column error, got %v", err)
+	}
+}
+
+func TestParseImportJSONLines(t *testing.T) {
+	body := `{"name":"Silla","price":10000,"imageUrls":["https://img/1.jpg"]}
+
+{"name":
+{"name":"Lampara","price":5000,"categories":["Hogar"],"imageUrls":["https://img/2.jpg"]}
+`
+	items, err := parseImport("application/x-ndjson", []byte(body))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(items) != 3 {
+		t.Fatalf("got %d items, want 3", len(items))
+	}
+	if items[0].Row != 1 || items[0].Product.Name != "Silla" {
+		t.Errorf("unexpected first row %+v", items[0])
+	}
+	if items[1].Row != 3 || items[1].ParseError == "" {
+		t.Errorf("expected a JSON error on row 3, got %+v", items[1])
+	}
+	if items[2].Row != 4 || items[2].Product.Categories[0] != "Hogar" {
+		t.Errorf("unexpected last row %+v", items[2])
+	}
+}
+
+func TestParseImportRejects(t *testing.T) {
+	if _, err := parseImport("text/plain", []byte("name,price\n")); err != ErrImportFormat {
+		t.Errorf("text/plain: got %v", err)
+	}
+	if _, err := parseImport("text/csv", []byte("name,price\n")); err != ErrImportEmpty {
+		t.Errorf("header only: got %v", err)
+	}
+}
+
+func TestValidateImportItem(t *testing.T) {
+	t.Setenv("TIGRIS_BUCKET", "trompeventas")
+	t.Setenv("TIGRIS_ENDPOINT_URL", "https://t3.storage.dev")
+	storage.InitStorage()
+
+	hogar := uuid.New()
+	categories := map[string]uuid.UUID{"hogar": hogar}
+
+	valid := importItem{Row: 2, Product: ImportProductRow{
+		Name:       "Lampara",
+		Price:      5000,
+		Categories: []string{"HOGAR"},
+		ImageUrls:  []string{"https://trompeventas.t3.storage.dev/products/1.jpg"},
+	}}
+	req, categoryIDs, err := validateImportItem(valid, categories)
+	if err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	if req.Condition != "Nuevo" || req.Negotiable != "No conversable" {
+		t.Errorf("defaults not applied: %+v", req)
+	}
+	if len(categoryIDs) != 1 || categoryIDs[0] != hogar {
+		t.Errorf("category ids = %v", categoryIDs)
+	}
+
+	noImages := valid
+	noImages.Product.ImageUrls = nil
+	if _, _, err := validateImportItem(noImages, categories); err != ErrProductImageMissing {
+		t.Errorf("no images: got %v", err)
+	}
+
+	foreignImage := valid
+	foreignImage.Product.ImageUrls = []string{"https://img/1.jpg"}
+	if _, _, err := validateImportItem(foreignImage, categories); err != ErrProductImageForeign {
+		t.Errorf("foreign image: got %v", err)
+	}
+
+	unknownCategory := valid
+	unknownCategory.Product.Categories = []string{"Autos"}
+	if _, _, err := validateImportItem(unknownCategory, categories); err == nil {
+		t.Error("expected unknown category error")
+	}
+}
@@ -5,21 +5,47 @@ import (
 )
 
 type ProductsWithImagesAndCategories struct {
-	Product    client.Product                    `json:"product"`
-	Images     []client.ProductImage             `json:"images"`
-	Categories []client.GetProductsCategoriesRow `json:"categories"`
+	Product       client.Product                                `json:"product"`
+	Images        []client.ProductImage                         `json:"images"`
+	Categories    []client.GetProductsCategoriesByProductIdsRow `json:"categories"`
+	FavoriteCount int64                                         `json:"favoriteCount"`
+	Highlight     *ProductHighlight                             `json:"highlight,omitempty"`
+}
+
+// Search matches wrapped in <mark> tags, only present on GET /products?q=
+type ProductHighlight struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Rank        float32 `json:"rank"`
+}
+
+type ProductsPage struct {
+	Products   []ProductsWithImagesAndCategories `json:"products"`
+	NextCursor *string                           `json:"nextCursor"`
 }
 
 type SellerInfo struct {
-	Name   string `json:"name"`
-	Image  string `json:"image"`
-	Region string `json:"region"`
-	City   string `json:"city"`
+	Name          string  `json:"name"`
+	Image         string  `json:"image"`
+	Region        string  `json:"region"`
+	City          string  `json:"city"`
+	AverageRating float64 `json:"averageRating"`
+	ReviewCount   int64   `json:"reviewCount"`
+}
+
+// Public seller profile, never includes the email
+type SellerProfile struct {
+	ID string `json:"id"`
+	SellerInfo
+	MemberSince   string           `json:"memberSince"`
+	EmailVerified bool             `json:"emailVerified"`
+	ListingCounts map[string]int64 `json:"listingCounts"` // by product state
 }
 
 type ProductWithImagesAndCategories struct {
-	Product    client.Product                       `json:"product"`
-	Images     []client.ProductImage                `json:"images"`
-	Categories []client.GetProductCategoriesByIdRow `json:"categories"`
-	Seller     *SellerInfo                          `json:"seller"`
+	Product       client.Product                       `json:"product"`
+	Images        []client.ProductImage                `json:"images"`
+	Categories    []client.GetProductCategoriesByIdRow `json:"categories"`
+	Seller        *SellerInfo                          `json:"seller"`
+	FavoriteCount int64                                `json:"favoriteCount"`
 }
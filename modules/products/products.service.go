@@ -2,54 +2,315 @@ package products
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgtype"
 
 	"restorapp/db"
 	"restorapp/db/client"
+	"restorapp/modules/pagination"
+	"restorapp/modules/storage"
 
 	"github.com/charmbracelet/log"
 	"github.com/gin-gonic/gin"
 )
 
+const (
+	defaultProductsPageSize = 20
+	maxProductsPageSize     = 100
+	maxSuggestions          = 8
+)
+
 func getProductsHandler(ctx *gin.Context) {
-	productList := []ProductsWithImagesAndCategories{}
+	filters, pageSize, err := parseProductsFilters(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if query := buildSearchQuery(ctx.Query("q"), false); query != "" {
+		searchProducts(ctx, query, filters, pageSize)
+		return
+	}
+
+	params := client.ListProductsParams{
+		CategoryIds: filters.CategoryIds,
+		MinPrice:    filters.MinPrice,
+		MaxPrice:    filters.MaxPrice,
+		Condition:   filters.Condition,
+		State:       filters.State,
+		Negotiable:  filters.Negotiable,
+		Region:      filters.Region,
+		City:        filters.City,
+	}
 
-	q := ctx.Query("q")
-	var products []client.Product
-	var err error
+	listProducts(ctx, params, pageSize)
+}
+
+// listProducts serves one keyset page of ListProducts, shared by every
+// endpoint that returns a ProductsPage
+func listProducts(ctx *gin.Context, params client.ListProductsParams, pageSize int32) {
+	// Fetch one extra row to know whether there is a next page
+	params.PageLimit = pageSize + 1
 
-	if q != "" {
-		products, err = db.Queries.SearchProducts(ctx, pgtype.Text{String: q, Valid: true})
-	} else {
-		products, err = db.Queries.GetProducts(ctx)
+	if cursor := ctx.Query("cursor"); cursor != "" {
+		createdAt, id, err := pagination.DecodeCursor(cursor)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
+		params.CursorCreatedAt = createdAt
+		params.CursorID = id
 	}
+
+	products, err := db.Queries.ListProducts(ctx, params)
 	if err != nil {
 		log.Error("Could not retrieve data of products", err)
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get products"})
 		return
 	}
 
-	images, err := db.Queries.GetProductsImages(ctx)
+	var nextCursor *string
+	if len(products) > int(pageSize) {
+		products = products[:pageSize]
+		last := products[len(products)-1]
+		cursor := pagination.EncodeCursor(last.CreatedAt, last.ID)
+		nextCursor = &cursor
+	}
+
+	productList, err := loadProductsImagesAndCategories(ctx, products)
 	if err != nil {
-		log.Error("Could not retrieve data of product images", err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get product images"})
+		log.Error("Could not retrieve images and categories of products", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get products"})
 		return
 	}
 
-	categories, err := db.Queries.GetProductsCategories(ctx)
+	ctx.JSON(http.StatusOK, ProductsPage{
+		Products:   productList,
+		NextCursor: nextCursor,
+	})
+}
+
+// searchProducts serves GET /products?q= ordered by relevance. Ranked results
+// have no stable keyset, so the cursor here wraps an offset instead
+func searchProducts(ctx *gin.Context, query string, filters client.SearchProductsParams, pageSize int32) {
+	offset := int32(0)
+	if cursor := ctx.Query("cursor"); cursor != "" {
+		decoded, err := decodeSearchCursor(cursor)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
+		offset = decoded
+	}
+
+	filters.Query = query
+	filters.PageLimit = pageSize + 1
+	filters.PageOffset = offset
+
+	rows, err := db.Queries.SearchProducts(ctx, filters)
 	if err != nil {
-		log.Error("Could not retrieve data of product categories", err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get product categories"})
+		log.Error("Could not search products", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get products"})
 		return
 	}
 
+	var nextCursor *string
+	if len(rows) > int(pageSize) {
+		rows = rows[:pageSize]
+		cursor := encodeSearchCursor(offset + pageSize)
+		nextCursor = &cursor
+	}
+
+	products := make([]client.Product, 0, len(rows))
+	for _, row := range rows {
+		products = append(products, row.Product)
+	}
+
+	productList, err := loadProductsImagesAndCategories(ctx, products)
+	if err != nil {
+		log.Error("Could not retrieve images and categories of products", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get products"})
+		return
+	}
+
+	for i, row := range rows {
+		productList[i].Highlight = &ProductHighlight{
+			Name:        row.NameHighlight,
+			Description: row.DescriptionHighlight,
+			Rank:        row.Rank,
+		}
+	}
+
+	ctx.JSON(http.StatusOK, ProductsPage{
+		Products:   productList,
+		NextCursor: nextCursor,
+	})
+}
+
+func getProductSuggestionsHandler(ctx *gin.Context) {
+	query := buildSearchQuery(ctx.Query("q"), true)
+	if query == "" {
+		ctx.JSON(http.StatusOK, gin.H{"suggestions": []client.SuggestProductsRow{}})
+		return
+	}
+
+	suggestions, err := db.Queries.SuggestProducts(ctx, client.SuggestProductsParams{
+		Query:     query,
+		PageLimit: maxSuggestions,
+	})
+	if err != nil {
+		log.Error("Could not retrieve product suggestions", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get suggestions"})
+		return
+	}
+
+	if suggestions == nil {
+		suggestions = []client.SuggestProductsRow{}
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"suggestions": suggestions})
+}
+
+// buildSearchQuery turns free text into a to_tsquery expression. Only letters
+// and digits are kept so user input can never produce tsquery syntax errors.
+// With prefix set every term also matches words it is a prefix of, which is
+// what typeahead needs while the user is still typing
+func buildSearchQuery(q string, prefix bool) string {
+	terms := strings.FieldsFunc(q, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	if prefix {
+		for i, term := range terms {
+			terms[i] = term + ":*"
+		}
+	}
+
+	return strings.Join(terms, " & ")
+}
+
+// parseProductsFilters reads the page size and filter query params of GET /products
+func parseProductsFilters(ctx *gin.Context) (client.SearchProductsParams, int32, error) {
+	filters := client.SearchProductsParams{}
+	pageSize := int32(defaultProductsPageSize)
+
+	if limitParam := ctx.Query("limit"); limitParam != "" {
+		limit, err := strconv.Atoi(limitParam)
+		if err != nil || limit <= 0 {
+			return filters, 0, errors.New("Invalid limit")
+		}
+		pageSize = int32(min(limit, maxProductsPageSize))
+	}
+
+	for _, categoryParam := range ctx.QueryArray("category") {
+		for _, catIdStr := range strings.Split(categoryParam, ",") {
+			catUUID, err := uuid.Parse(strings.TrimSpace(catIdStr))
+			if err != nil {
+				return filters, 0, fmt.Errorf("Invalid category ID: %s", catIdStr)
+			}
+			filters.CategoryIds = append(filters.CategoryIds, catUUID)
+		}
+	}
+
+	if minPrice := ctx.Query("minPrice"); minPrice != "" {
+		price, err := strconv.ParseInt(minPrice, 10, 64)
+		if err != nil {
+			return filters, 0, errors.New("Invalid minPrice")
+		}
+		filters.MinPrice = pgtype.Int8{Int64: price, Valid: true}
+	}
+
+	if maxPrice := ctx.Query("maxPrice"); maxPrice != "" {
+		price, err := strconv.ParseInt(maxPrice, 10, 64)
+		if err != nil {
+			return filters, 0, errors.New("Invalid maxPrice")
+		}
+		filters.MaxPrice = pgtype.Int8{Int64: price, Valid: true}
+	}
+
+	condition := ctx.Query("condition")
+	filters.Condition = pgtype.Text{String: condition, Valid: condition != ""}
+	// Only available products are listed unless another public state is asked for
+	state := ctx.DefaultQuery("state", productStateAvailable)
+	if !slices.Contains(publicProductStates, state) {
+		return filters, 0, fmt.Errorf("Invalid state: %s", state)
+	}
+	filters.State = pgtype.Text{String: state, Valid: true}
+	negotiable := ctx.Query("negotiable")
+	filters.Negotiable = pgtype.Text{String: negotiable, Valid: negotiable != ""}
+	region := ctx.Query("region")
+	filters.Region = pgtype.Text{String: region, Valid: region != ""}
+	city := ctx.Query("city")
+	filters.City = pgtype.Text{String: city, Valid: city != ""}
+
+	return filters, pageSize, nil
+}
+
+func encodeSearchCursor(offset int32) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("offset|%d", offset)))
+}
+
+func decodeSearchCursor(cursor string) (int32, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+
+	offsetStr, found := strings.CutPrefix(string(raw), "offset|")
+	if !found {
+		return 0, errors.New("malformed cursor")
+	}
+
+	offset, err := strconv.ParseInt(offsetStr, 10, 32)
+	if err != nil || offset < 0 {
+		return 0, errors.New("malformed cursor")
+	}
+
+	return int32(offset), nil
+}
+
+// loadProductsImagesAndCategories attaches images and categories to the given
+// products, querying only the rows that belong to them
+func loadProductsImagesAndCategories(ctx context.Context, products []client.Product) ([]ProductsWithImagesAndCategories, error) {
+	productList := []ProductsWithImagesAndCategories{}
+	if len(products) == 0 {
+		return productList, nil
+	}
+
+	productIds := make([]uuid.UUID, 0, len(products))
+	for _, product := range products {
+		productIds = append(productIds, product.ID)
+	}
+
+	images, err := db.Queries.GetProductsImagesByProductIds(ctx, productIds)
+	if err != nil {
+		return nil, err
+	}
+
+	categories, err := db.Queries.GetProductsCategoriesByProductIds(ctx, productIds)
+	if err != nil {
+		return nil, err
+	}
+
+	favoriteCounts, err := db.Queries.GetFavoriteCountsByProductIds(ctx, productIds)
+	if err != nil {
+		return nil, err
+	}
+
 	productsImages := make(map[uuid.UUID][]client.ProductImage)
-	productsCategories := make(map[uuid.UUID][]client.GetProductsCategoriesRow)
+	productsCategories := make(map[uuid.UUID][]client.GetProductsCategoriesByProductIdsRow)
+	productsFavoriteCounts := make(map[uuid.UUID]int64)
 
 	for _, image := range images {
 		productsImages[image.ProductID] = append(productsImages[image.ProductID], image)
@@ -59,17 +320,20 @@ func getProductsHandler(ctx *gin.Context) {
 		productsCategories[category.ProductID] = append(productsCategories[category.ProductID], category)
 	}
 
-	for _, product := range products {
-		productToAdd := ProductsWithImagesAndCategories{
-			Product:    product,
-			Images:     productsImages[product.ID],
-			Categories: productsCategories[product.ID],
-		}
+	for _, count := range favoriteCounts {
+		productsFavoriteCounts[count.ProductID] = count.FavoriteCount
+	}
 
-		productList = append(productList, productToAdd)
+	for _, product := range products {
+		productList = append(productList, ProductsWithImagesAndCategories{
+			Product:       product,
+			Images:        productsImages[product.ID],
+			Categories:    productsCategories[product.ID],
+			FavoriteCount: productsFavoriteCounts[product.ID],
+		})
 	}
 
-	ctx.JSON(http.StatusOK, productList)
+	return productList, nil
 }
 
 func createProductHandler(ctx *gin.Context) {
@@ -83,13 +347,52 @@ func createProductHandler(ctx *gin.Context) {
 		return
 	}
 
-	createdProduct, errDB := db.Queries.CreateProduct(ctx, productToCreate)
+	if productToCreate.State == "" {
+		productToCreate.State = productStateAvailable
+	}
+	if productToCreate.State != productStateDraft && productToCreate.State != productStateAvailable {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "New products must be draft or available"})
+		return
+	}
+
+	userUUID, err := uuid.Parse(ctx.GetString("userId"))
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	productToCreate.UserID = pgtype.UUID{Bytes: userUUID, Valid: true}
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		log.Error("Failed to begin transaction", "error", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create product"})
+		return
+	}
+	defer tx.Rollback(context.Background())
+
+	qtx := db.Queries.WithTx(tx)
+
+	createdProduct, errDB := qtx.CreateProduct(ctx, productToCreate)
 	if errDB != nil {
 		log.Error("Error creating product in db", errDB)
 		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Failed to create product"})
 		return
 	}
 
+	err = qtx.CreateProductStateHistory(ctx, client.CreateProductStateHistoryParams{
+		ProductID: createdProduct.ID,
+		ToState:   createdProduct.State,
+		ChangedBy: productToCreate.UserID,
+	})
+	if err == nil {
+		err = tx.Commit(ctx)
+	}
+	if err != nil {
+		log.Error("Error recording product state", "error", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create product"})
+		return
+	}
+
 	ctx.JSON(http.StatusCreated, gin.H{
 		"message": "product created successfully",
 		"product": createdProduct,
@@ -111,6 +414,12 @@ func getProductByIdHandler(ctx *gin.Context) {
 		return
 	}
 
+	// Drafts, paused and expired listings are only visible to their seller
+	if !CanViewProduct(ctx, product) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+
 	category, err := db.Queries.GetProductCategoriesById(ctx, productUUID)
 	if err != nil {
 		log.Error("Could not retrieve product categories", err)
@@ -125,29 +434,130 @@ func getProductByIdHandler(ctx *gin.Context) {
 		return
 	}
 
+	favoriteCount, err := db.Queries.GetProductFavoriteCount(ctx, productUUID)
+	if err != nil {
+		log.Error("Could not retrieve product favorite count", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get product"})
+		return
+	}
+
 	var seller *SellerInfo
 	if product.UserID.Valid {
 		user, err := db.Queries.GetUserById(ctx, product.UserID.Bytes)
 		if err == nil {
-			seller = &SellerInfo{
-				Name:   user.Name,
-				Image:  user.Image.String,
-				Region: user.Region.String,
-				City:   user.City.String,
+			sellerInfo, err := loadSellerInfo(ctx, user)
+			if err != nil {
+				log.Error("Could not retrieve seller rating", err)
+				ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get product"})
+				return
 			}
+			seller = &sellerInfo
 		}
 	}
 
 	productData := ProductWithImagesAndCategories{
-		Product:    product,
-		Categories: category,
-		Images:     images,
-		Seller:     seller,
+		Product:       product,
+		Categories:    category,
+		Images:        images,
+		Seller:        seller,
+		FavoriteCount: favoriteCount,
 	}
 
 	ctx.JSON(http.StatusOK, productData)
 }
 
+// isProductOwner reports whether the optionally signed-in user published the product
+func isProductOwner(ctx *gin.Context, product client.Product) bool {
+	userUUID, err := uuid.Parse(ctx.GetString("userId"))
+	return err == nil && product.UserID.Valid && product.UserID.Bytes == userUUID
+}
+
+func loadSellerInfo(ctx context.Context, user client.User) (SellerInfo, error) {
+	rating, err := db.Queries.GetSellerRatingSummary(ctx, user.ID)
+	if err != nil {
+		return SellerInfo{}, err
+	}
+
+	return SellerInfo{
+		Name:          user.Name,
+		Image:         user.Image.String,
+		Region:        user.Region.String,
+		City:          user.City.String,
+		AverageRating: rating.AverageRating,
+		ReviewCount:   rating.ReviewCount,
+	}, nil
+}
+
+func getSellerProfileHandler(ctx *gin.Context) {
+	userUUID, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	user, err := db.Queries.GetUserById(ctx, userUUID)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	sellerInfo, err := loadSellerInfo(ctx, user)
+	if err != nil {
+		log.Error("Could not retrieve seller rating", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get profile"})
+		return
+	}
+
+	stateCounts, err := db.Queries.CountUserProductsByState(ctx, pgtype.UUID{Bytes: userUUID, Valid: true})
+	if err != nil {
+		log.Error("Could not count seller products", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get profile"})
+		return
+	}
+
+	// Drafts, paused and expired listings are private to the seller
+	listingCounts := map[string]int64{}
+	for _, stateCount := range stateCounts {
+		if slices.Contains(publicProductStates, stateCount.State) {
+			listingCounts[stateCount.State] = stateCount.ProductCount
+		}
+	}
+
+	memberSince := ""
+	if user.CreatedAt.Valid {
+		memberSince = user.CreatedAt.Time.Format(time.RFC3339)
+	}
+
+	ctx.JSON(http.StatusOK, SellerProfile{
+		ID:            user.ID.String(),
+		SellerInfo:    sellerInfo,
+		MemberSince:   memberSince,
+		EmailVerified: user.EmailVerified.Bool,
+		ListingCounts: listingCounts,
+	})
+}
+
+// getSellerProductsHandler is the seller's storefront: their available
+// listings, paginated like GET /products
+func getSellerProductsHandler(ctx *gin.Context) {
+	userUUID, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	_, pageSize, err := parseProductsFilters(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	listProducts(ctx, client.ListProductsParams{
+		SellerID: pgtype.UUID{Bytes: userUUID, Valid: true},
+		State:    pgtype.Text{String: productStateAvailable, Valid: true},
+	}, pageSize)
+}
+
 func getMyProductsHandler(ctx *gin.Context) {
 	userID := ctx.GetString("userId")
 	if userID == "" {
@@ -167,25 +577,11 @@ func getMyProductsHandler(ctx *gin.Context) {
 		return
 	}
 
-	productList := []ProductsWithImagesAndCategories{}
-	for _, product := range products {
-		images, _ := db.Queries.GetProductImagesById(ctx, product.ID)
-		categories, _ := db.Queries.GetProductCategoriesById(ctx, product.ID)
-
-		catRows := []client.GetProductsCategoriesRow{}
-		for _, c := range categories {
-			catRows = append(catRows, client.GetProductsCategoriesRow{
-				ID:        c.ID,
-				ProductID: c.ProductID,
-				Name:      c.Name,
-			})
-		}
-
-		productList = append(productList, ProductsWithImagesAndCategories{
-			Product:    product,
-			Images:     images,
-			Categories: catRows,
-		})
+	productList, err := loadProductsImagesAndCategories(ctx, products)
+	if err != nil {
+		log.Error("Could not retrieve images and categories of user products", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get products"})
+		return
 	}
 
 	ctx.JSON(http.StatusOK, productList)
@@ -224,44 +620,39 @@ func deleteMyProductHandler(ctx *gin.Context) {
 }
 
 func updateMyProductHandler(ctx *gin.Context) {
-	userID := ctx.GetString("userId")
-	if userID == "" {
-		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+	product, ok := getMyProduct(ctx)
+	if !ok {
 		return
 	}
-	userUUID, err := uuid.Parse(userID)
-	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+
+	productToUpdate := client.UpdateProductParams{}
+	decoder := json.NewDecoder(ctx.Request.Body)
+	if err := decoder.Decode(&productToUpdate); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Bad request"})
 		return
 	}
+	productToUpdate.ID = product.ID
 
-	productIdParam := ctx.Param("id")
-	productUUID, err := uuid.Parse(productIdParam)
-	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+	// State changes go through the state machine, the rest is a plain update
+	newState := productToUpdate.State
+	productToUpdate.State = pgtype.Text{}
+	if newState.Valid && newState.String != product.State && !canTransitionProductState(product.State, newState.String) {
+		ctx.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Cannot change product state from %s to %s", product.State, newState.String)})
 		return
 	}
 
-	// Verify ownership
-	product, err := db.Queries.GetProductById(ctx, productUUID)
+	// Field edits and the state change are saved together or not at all
+	tx, err := db.Pool.Begin(ctx)
 	if err != nil {
-		ctx.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
-		return
-	}
-	if product.UserID.Bytes != userUUID {
-		ctx.JSON(http.StatusForbidden, gin.H{"error": "Not your product"})
+		log.Error("Failed to begin transaction", "error", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update product"})
 		return
 	}
+	defer tx.Rollback(context.Background())
 
-	productToUpdate := client.UpdateProductParams{}
-	decoder := json.NewDecoder(ctx.Request.Body)
-	if err := decoder.Decode(&productToUpdate); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Bad request"})
-		return
-	}
-	productToUpdate.ID = productUUID
+	qtx := db.Queries.WithTx(tx)
 
-	updated, err := db.Queries.UpdateProduct(ctx, productToUpdate)
+	updated, err := qtx.UpdateProduct(ctx, productToUpdate)
 	if err != nil {
 		log.Error("Error updating product", err)
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update product"})
@@ -273,6 +664,27 @@ func updateMyProductHandler(ctx *gin.Context) {
 		return
 	}
 
+	if newState.Valid && newState.String != product.State {
+		updated[0], err = changeProductState(ctx, qtx, updated[0], newState.String, product.UserID.Bytes)
+		if err == ErrInvalidStateTransition {
+			ctx.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Cannot change product state from %s to %s", product.State, newState.String)})
+			return
+		}
+		if err != nil {
+			log.Error("Error changing product state", err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update product"})
+			return
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Error("Error updating product", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update product"})
+		return
+	}
+
+	enqueueFavoriteNotifications(ctx, product, updated[0])
+
 	ctx.JSON(http.StatusOK, gin.H{"message": "Product updated successfully", "product": updated[0]})
 }
 
@@ -284,56 +696,53 @@ type PublishProductRequest struct {
 	Negotiable  string   `json:"negotiable"`
 	Categories  []string `json:"categories"`
 	ImageUrls   []string `json:"imageUrls"`
+	Draft       bool     `json:"draft"`
 }
 
-func publishProductHandler(ctx *gin.Context) {
-	userID := ctx.GetString("userId")
-	if userID == "" {
-		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-		return
-	}
-	userUUID, err := uuid.Parse(userID)
-	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
-		return
-	}
-
-	var req PublishProductRequest
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
-		return
-	}
+var (
+	ErrProductNameRequired = errors.New("Name is required")
+	ErrProductPriceInvalid = errors.New("Price must be greater than 0")
+	ErrProductImageMissing = errors.New("At least one image is required")
+	ErrProductImageLimit   = fmt.Errorf("A product can have at most %d images", maxProductImages)
+	ErrProductImageForeign = errors.New("Images must be uploaded through /upload/presign")
+)
 
+// validatePublishRequest checks a listing and fills in the defaults. The
+// errors are meant to be shown to the seller as they are
+func validatePublishRequest(req *PublishProductRequest) error {
 	if req.Name == "" {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Name is required"})
-		return
+		return ErrProductNameRequired
 	}
 	if req.Price <= 0 {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Price must be greater than 0"})
-		return
+		return ErrProductPriceInvalid
 	}
 	if len(req.ImageUrls) == 0 {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "At least one image is required"})
-		return
+		return ErrProductImageMissing
 	}
-
-	tx, err := db.Pool.Begin(context.Background())
-	if err != nil {
-		log.Error("Failed to begin transaction", "error", err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
-		return
+	if len(req.ImageUrls) > maxProductImages {
+		return ErrProductImageLimit
+	}
+	for _, imageUrl := range req.ImageUrls {
+		if !storage.IsUploadedURL(imageUrl) {
+			return ErrProductImageForeign
+		}
 	}
-	defer tx.Rollback(context.Background())
-
-	qtx := db.Queries.WithTx(tx)
 
-	condition := req.Condition
-	if condition == "" {
-		condition = "Nuevo"
+	if req.Condition == "" {
+		req.Condition = "Nuevo"
 	}
-	negotiable := req.Negotiable
-	if negotiable == "" {
-		negotiable = "No conversable"
+	if req.Negotiable == "" {
+		req.Negotiable = "No conversable"
+	}
+	return nil
+}
+
+// publishProduct creates a validated listing with its state history, images
+// and categories using the given queries, so callers decide the transaction
+func publishProduct(ctx context.Context, qtx *client.Queries, userUUID uuid.UUID, req PublishProductRequest, categoryIDs []uuid.UUID) (client.Product, []client.ProductImage, error) {
+	state := productStateAvailable
+	if req.Draft {
+		state = productStateDraft
 	}
 
 	product, err := qtx.CreateProduct(ctx, client.CreateProductParams{
@@ -341,47 +750,97 @@ func publishProductHandler(ctx *gin.Context) {
 		Description: pgtype.Text{String: req.Description, Valid: req.Description != ""},
 		Price:       req.Price,
 		UserID:      pgtype.UUID{Bytes: userUUID, Valid: true},
-		Condition:   condition,
-		State:       "Disponible",
-		Negotiable:  negotiable,
+		Condition:   req.Condition,
+		State:       state,
+		Negotiable:  req.Negotiable,
 	})
 	if err != nil {
-		log.Error("Failed to create product", "error", err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create product"})
-		return
+		return client.Product{}, nil, fmt.Errorf("create product: %w", err)
 	}
 
+	err = qtx.CreateProductStateHistory(ctx, client.CreateProductStateHistoryParams{
+		ProductID: product.ID,
+		ToState:   state,
+		ChangedBy: pgtype.UUID{Bytes: userUUID, Valid: true},
+	})
+	if err != nil {
+		return client.Product{}, nil, fmt.Errorf("record product state: %w", err)
+	}
+
+	// Images keep the order they were sent in, the first one is the cover
 	var images []client.ProductImage
-	for _, imageUrl := range req.ImageUrls {
+	for i, imageUrl := range req.ImageUrls {
 		image, err := qtx.CreateProductImage(ctx, client.CreateProductImageParams{
 			ProductID: product.ID,
 			ImageUrl:  imageUrl,
+			Position:  int32(i),
+			IsCover:   i == 0,
 		})
 		if err != nil {
-			log.Error("Failed to create product image", "error", err)
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create product image"})
-			return
+			return client.Product{}, nil, fmt.Errorf("create product image: %w", err)
 		}
 		images = append(images, image)
 	}
 
-	var categories []client.ProductsCategory
+	for _, categoryID := range categoryIDs {
+		_, err := qtx.CreateProductCategory(ctx, client.CreateProductCategoryParams{
+			ProductID:  product.ID,
+			CategoryID: categoryID,
+		})
+		if err != nil {
+			return client.Product{}, nil, fmt.Errorf("create product category: %w", err)
+		}
+	}
+
+	return product, images, nil
+}
+
+func publishProductHandler(ctx *gin.Context) {
+	userID := ctx.GetString("userId")
+	if userID == "" {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req PublishProductRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	if err := validatePublishRequest(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	categoryIDs := make([]uuid.UUID, 0, len(req.Categories))
 	for _, catIdStr := range req.Categories {
 		catUUID, err := uuid.Parse(catIdStr)
 		if err != nil {
 			ctx.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid category ID: %s", catIdStr)})
 			return
 		}
-		cat, err := qtx.CreateProductCategory(ctx, client.CreateProductCategoryParams{
-			ProductID:  product.ID,
-			CategoryID: catUUID,
-		})
-		if err != nil {
-			log.Error("Failed to create product category", "error", err)
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create product category"})
-			return
-		}
-		categories = append(categories, cat)
+		categoryIDs = append(categoryIDs, catUUID)
+	}
+
+	tx, err := db.Pool.Begin(context.Background())
+	if err != nil {
+		log.Error("Failed to begin transaction", "error", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	defer tx.Rollback(context.Background())
+
+	product, images, err := publishProduct(ctx, db.Queries.WithTx(tx), userUUID, req, categoryIDs)
+	if err != nil {
+		log.Error("Failed to publish product", "error", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create product"})
+		return
 	}
 
 	if err := tx.Commit(context.Background()); err != nil {
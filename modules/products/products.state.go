@@ -0,0 +1,152 @@
+package products
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"restorapp/db"
+	"restorapp/db/client"
+
+	"github.com/charmbracelet/log"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	productStateDraft     = "draft"
+	productStateAvailable = "available"
+	productStateReserved  = "reserved"
+	productStateSold      = "sold"
+	productStatePaused    = "paused"
+	productStateExpired   = "expired"
+)
+
+var ErrInvalidStateTransition = errors.New("invalid product state transition")
+
+// productStateTransitions lists, for each state, the states a product can
+// move to. Relisting brings anything that is not a draft back to available
+var productStateTransitions = map[string][]string{
+	productStateDraft:     {productStateAvailable},
+	productStateAvailable: {productStateReserved, productStateSold, productStatePaused, productStateExpired},
+	productStateReserved:  {productStateAvailable, productStateSold},
+	productStateSold:      {productStateAvailable},
+	productStatePaused:    {productStateAvailable},
+	productStateExpired:   {productStateAvailable},
+}
+
+// States anyone can see a listing in and filter the public listing by
+var publicProductStates = []string{productStateAvailable, productStateReserved, productStateSold}
+
+// CanViewProduct reports whether the current user may see the product.
+// Listings outside publicProductStates are only visible to their seller
+func CanViewProduct(ctx *gin.Context, product client.Product) bool {
+	return slices.Contains(publicProductStates, product.State) || isProductOwner(ctx, product)
+}
+
+func canTransitionProductState(from string, to string) bool {
+	return slices.Contains(productStateTransitions[from], to)
+}
+
+// changeProductState moves a product to a new state and records who did it,
+// using the given queries so callers decide the transaction. The update only
+// applies if the product is still in the state it was read in, so concurrent
+// changes cannot skip the transition check
+func changeProductState(ctx context.Context, qtx *client.Queries, product client.Product, to string, changedBy uuid.UUID) (client.Product, error) {
+	if !canTransitionProductState(product.State, to) {
+		return client.Product{}, ErrInvalidStateTransition
+	}
+
+	updated, err := qtx.UpdateProductState(ctx, client.UpdateProductStateParams{
+		ToState:   to,
+		ID:        product.ID,
+		FromState: product.State,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return client.Product{}, ErrInvalidStateTransition
+	}
+	if err != nil {
+		return client.Product{}, err
+	}
+
+	err = qtx.CreateProductStateHistory(ctx, client.CreateProductStateHistoryParams{
+		ProductID: product.ID,
+		FromState: pgtype.Text{String: product.State, Valid: true},
+		ToState:   to,
+		ChangedBy: pgtype.UUID{Bytes: changedBy, Valid: true},
+	})
+	if err != nil {
+		return client.Product{}, err
+	}
+
+	return updated, nil
+}
+
+func reserveMyProductHandler(ctx *gin.Context) {
+	transitionMyProduct(ctx, productStateReserved, "Product reserved successfully")
+}
+
+func markMyProductSoldHandler(ctx *gin.Context) {
+	transitionMyProduct(ctx, productStateSold, "Product marked as sold")
+}
+
+func relistMyProductHandler(ctx *gin.Context) {
+	transitionMyProduct(ctx, productStateAvailable, "Product relisted successfully")
+}
+
+func transitionMyProduct(ctx *gin.Context, to string, message string) {
+	product, ok := getMyProduct(ctx)
+	if !ok {
+		return
+	}
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		log.Error("Failed to begin transaction", "error", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update product"})
+		return
+	}
+	defer tx.Rollback(context.Background())
+
+	updated, err := changeProductState(ctx, db.Queries.WithTx(tx), product, to, product.UserID.Bytes)
+	if err == ErrInvalidStateTransition {
+		ctx.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Cannot change product state from %s to %s", product.State, to)})
+		return
+	}
+	if err == nil {
+		err = tx.Commit(ctx)
+	}
+	if err != nil {
+		log.Error("Error changing product state", "error", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update product"})
+		return
+	}
+
+	enqueueFavoriteNotifications(ctx, product, updated)
+
+	ctx.JSON(http.StatusOK, gin.H{"message": message, "product": updated})
+}
+
+func getMyProductStateHistoryHandler(ctx *gin.Context) {
+	product, ok := getMyProduct(ctx)
+	if !ok {
+		return
+	}
+
+	history, err := db.Queries.GetProductStateHistory(ctx, product.ID)
+	if err != nil {
+		log.Error("Could not retrieve product state history", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get product history"})
+		return
+	}
+	if history == nil {
+		history = []client.ProductStateHistory{}
+	}
+
+	ctx.JSON(http.StatusOK, history)
+}
@@ -0,0 +1,60 @@
+package realtime
+
+import (
+	"context"
+	"sync"
+)
+
+// Events are dropped for subscribers that fall this far behind instead of
+// blocking the publisher
+const subscriberBufferSize = 16
+
+type MemoryHub struct {
+	mu          sync.RWMutex
+	subscribers map[string]map[chan Event]struct{}
+}
+
+func NewMemoryHub() *MemoryHub {
+	return &MemoryHub{
+		subscribers: make(map[string]map[chan Event]struct{}),
+	}
+}
+
+func (h *MemoryHub) Publish(ctx context.Context, topic string, event Event) error {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for ch := range h.subscribers[topic] {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+	return nil
+}
+
+func (h *MemoryHub) Subscribe(topic string) (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBufferSize)
+
+	h.mu.Lock()
+	if h.subscribers[topic] == nil {
+		h.subscribers[topic] = make(map[chan Event]struct{})
+	}
+	h.subscribers[topic][ch] = struct{}{}
+	h.mu.Unlock()
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			h.mu.Lock()
+			delete(h.subscribers[topic], ch)
+			if len(h.subscribers[topic]) == 0 {
+				delete(h.subscribers, topic)
+			}
+			h.mu.Unlock()
+			close(ch)
+		})
+	}
+
+	return ch, unsubscribe
+}
@@ -0,0 +1,97 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	notifyChannel = "realtime_events"
+	// NOTIFY payloads are capped at 8000 bytes by Postgres
+	maxNotifyPayload = 8000
+	listenRetryDelay = 5 * time.Second
+)
+
+type notification struct {
+	Topic string `json:"topic"`
+	Event Event  `json:"event"`
+}
+
+// PostgresHub publishes with pg_notify and keeps a dedicated connection
+// listening on the channel, re-dispatching everything it receives to local
+// subscribers. Our own notifications come back through LISTEN as well, so
+// Publish never delivers locally
+type PostgresHub struct {
+	pool  *pgxpool.Pool
+	local *MemoryHub
+}
+
+func NewPostgresHub(ctx context.Context, pool *pgxpool.Pool) *PostgresHub {
+	h := &PostgresHub{
+		pool:  pool,
+		local: NewMemoryHub(),
+	}
+	go h.listen(ctx)
+	return h
+}
+
+func (h *PostgresHub) Publish(ctx context.Context, topic string, event Event) error {
+	payload, err := json.Marshal(notification{Topic: topic, Event: event})
+	if err != nil {
+		return err
+	}
+	if len(payload) > maxNotifyPayload {
+		log.Warn("Realtime event too large for NOTIFY, delivering locally only", "topic", topic, "type", event.Type)
+		return h.local.Publish(ctx, topic, event)
+	}
+
+	_, err = h.pool.Exec(ctx, "SELECT pg_notify($1, $2)", notifyChannel, string(payload))
+	return err
+}
+
+func (h *PostgresHub) Subscribe(topic string) (<-chan Event, func()) {
+	return h.local.Subscribe(topic)
+}
+
+func (h *PostgresHub) listen(ctx context.Context) {
+	for {
+		err := h.listenOnce(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Error("Realtime listener disconnected, retrying", "error", err)
+		time.Sleep(listenRetryDelay)
+	}
+}
+
+func (h *PostgresHub) listenOnce(ctx context.Context) error {
+	pooled, err := h.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	// The connection is left in LISTEN state, never return it to the pool
+	conn := pooled.Hijack()
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+notifyChannel); err != nil {
+		return err
+	}
+
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+
+		var msg notification
+		if err := json.Unmarshal([]byte(n.Payload), &msg); err != nil {
+			log.Error("Invalid realtime notification", "error", err)
+			continue
+		}
+		h.local.Publish(ctx, msg.Topic, msg.Event)
+	}
+}
@@ -0,0 +1,62 @@
+package realtime
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/charmbracelet/log"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	EventCommentCreated = "comment-created"
+	EventCommentDeleted = "comment-deleted"
+	EventVoteChanged    = "vote-changed"
+)
+
+// Event is what gets pushed to subscribers of a topic. Data must be JSON
+// serializable so it can travel through Postgres NOTIFY
+type Event struct {
+	Type string `json:"type"`
+	Data any    `json:"data"`
+}
+
+// Hub fans out events to every subscriber of a topic. The in-process hub
+// only reaches clients connected to this instance, the Postgres hub relays
+// events through LISTEN/NOTIFY so every instance receives them
+type Hub interface {
+	Publish(ctx context.Context, topic string, event Event) error
+	// Subscribe returns a channel of events for the topic and a function
+	// that must be called to release the subscription
+	Subscribe(topic string) (<-chan Event, func())
+}
+
+var DefaultHub Hub = NewMemoryHub()
+
+// InitRealtime picks the hub backend. REALTIME_BACKEND=postgres is needed
+// when running more than one instance behind a load balancer
+func InitRealtime(pool *pgxpool.Pool) {
+	switch os.Getenv("REALTIME_BACKEND") {
+	case "postgres":
+		DefaultHub = NewPostgresHub(context.Background(), pool)
+		log.Info("Realtime hub initialized with Postgres LISTEN/NOTIFY")
+	default:
+		DefaultHub = NewMemoryHub()
+		log.Info("Realtime hub initialized in-process")
+	}
+}
+
+func ProductTopic(productID uuid.UUID) string {
+	return fmt.Sprintf("product:%s", productID.String())
+}
+
+// Publish sends an event through the default hub. Delivery is best effort,
+// failures are logged and never surface to the request that triggered them
+func Publish(ctx context.Context, topic string, eventType string, data any) {
+	err := DefaultHub.Publish(ctx, topic, Event{Type: eventType, Data: data})
+	if err != nil {
+		log.Error("Failed to publish realtime event", "topic", topic, "type", eventType, "error", err)
+	}
+}
@@ -0,0 +1,17 @@
+package reviews
+
+import (
+	"restorapp/modules/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+func ReviewsController(router *gin.Engine) {
+	router.GET("/users/:id/reviews", getSellerReviewsHandler)
+
+	// Buyers review the seller of a product they asked about
+	productReviews := router.Group("/products/:id/reviews")
+	productReviews.Use(auth.AuthMiddleware())
+	productReviews.Use(auth.EmailVerifiedMiddleware())
+	productReviews.POST("/", createReviewHandler)
+}
@@ -0,0 +1,34 @@
+package reviews
+
+type Reviewer struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Image string `json:"image"`
+}
+
+type ReviewResponse struct {
+	ID          string   `json:"id"`
+	ProductID   *string  `json:"productId"`
+	ProductName *string  `json:"productName"`
+	Rating      int16    `json:"rating"`
+	Content     string   `json:"content"`
+	CreatedAt   string   `json:"createdAt"`
+	UpdatedAt   string   `json:"updatedAt"`
+	Reviewer    Reviewer `json:"reviewer"`
+}
+
+type RatingSummary struct {
+	AverageRating float64 `json:"averageRating"`
+	ReviewCount   int64   `json:"reviewCount"`
+}
+
+type SellerReviews struct {
+	Summary    RatingSummary    `json:"summary"`
+	Reviews    []ReviewResponse `json:"reviews"`
+	NextCursor *string          `json:"nextCursor"`
+}
+
+type CreateReviewRequest struct {
+	Rating  int16  `json:"rating" binding:"required,min=1,max=5"`
+	Content string `json:"content" binding:"max=2000"`
+}
@@ -0,0 +1,215 @@
+package reviews
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"restorapp/db"
+	"restorapp/db/client"
+	"restorapp/modules/pagination"
+
+	"github.com/charmbracelet/log"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	defaultReviewsPageSize = 20
+	maxReviewsPageSize     = 100
+)
+
+func formatTimestamp(t pgtype.Timestamp) string {
+	if !t.Valid {
+		return ""
+	}
+	return t.Time.Format(time.RFC3339)
+}
+
+func getSellerReviewsHandler(ctx *gin.Context) {
+	sellerUUID, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	summary, err := db.Queries.GetSellerRatingSummary(ctx, sellerUUID)
+	if err != nil {
+		log.Error("Failed to get rating summary", "error", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get reviews"})
+		return
+	}
+
+	pageSize := int32(defaultReviewsPageSize)
+	if limitParam := ctx.Query("limit"); limitParam != "" {
+		limit, err := strconv.Atoi(limitParam)
+		if err != nil || limit <= 0 {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+		pageSize = int32(min(limit, maxReviewsPageSize))
+	}
+
+	params := client.GetSellerReviewsParams{
+		SellerID: sellerUUID,
+		// Fetch one extra row to know whether there is a next page
+		PageLimit: pageSize + 1,
+	}
+
+	if cursor := ctx.Query("cursor"); cursor != "" {
+		createdAt, id, err := pagination.DecodeCursor(cursor)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
+		params.CursorCreatedAt = createdAt
+		params.CursorID = id
+	}
+
+	rows, err := db.Queries.GetSellerReviews(ctx, params)
+	if err != nil {
+		log.Error("Failed to get reviews", "error", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get reviews"})
+		return
+	}
+
+	var nextCursor *string
+	if len(rows) > int(pageSize) {
+		rows = rows[:pageSize]
+		last := rows[len(rows)-1]
+		cursor := pagination.EncodeCursor(last.CreatedAt, last.ID)
+		nextCursor = &cursor
+	}
+
+	reviews := []ReviewResponse{}
+	for _, row := range rows {
+		// The listing may have been deleted since, the review stays
+		var productID, productName *string
+		if row.ProductID.Valid {
+			id := uuid.UUID(row.ProductID.Bytes).String()
+			name := row.ProductName.String
+			productID = &id
+			productName = &name
+		}
+		reviews = append(reviews, ReviewResponse{
+			ID:          row.ID.String(),
+			ProductID:   productID,
+			ProductName: productName,
+			Rating:      row.Rating,
+			Content:     row.Content,
+			CreatedAt:   formatTimestamp(row.CreatedAt),
+			UpdatedAt:   formatTimestamp(row.UpdatedAt),
+			Reviewer: Reviewer{
+				ID:    row.ReviewerID.String(),
+				Name:  row.ReviewerName,
+				Image: row.ReviewerImage.String,
+			},
+		})
+	}
+
+	ctx.JSON(http.StatusOK, SellerReviews{
+		Summary: RatingSummary{
+			AverageRating: summary.AverageRating,
+			ReviewCount:   summary.ReviewCount,
+		},
+		Reviews:    reviews,
+		NextCursor: nextCursor,
+	})
+}
+
+// createReviewHandler creates or replaces the current user's review for a
+// product. Only buyers who contacted the seller about it can review
+func createReviewHandler(ctx *gin.Context) {
+	productUUID, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return
+	}
+
+	userID := ctx.GetString("userId")
+	if userID == "" {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req CreateReviewRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	product, err := db.Queries.GetProductById(ctx, productUUID)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+	if !product.UserID.Valid {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "This product has no seller to review"})
+		return
+	}
+	sellerUUID := uuid.UUID(product.UserID.Bytes)
+	if sellerUUID == userUUID {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "You cannot review yourself"})
+		return
+	}
+
+	// Starting a conversation is free, only an actual exchange counts as
+	// having dealt with the seller
+	hasTwoWayConversation, err := db.Queries.HasTwoWayConversation(ctx, client.HasTwoWayConversationParams{
+		ProductID: productUUID,
+		BuyerID:   userUUID,
+	})
+	if err != nil {
+		log.Error("Failed to check conversation", "error", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save review"})
+		return
+	}
+	if !hasTwoWayConversation {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "You can only review sellers you have exchanged messages with about this product"})
+		return
+	}
+
+	review, err := db.Queries.UpsertReview(ctx, client.UpsertReviewParams{
+		ProductID:  pgtype.UUID{Bytes: productUUID, Valid: true},
+		ReviewerID: userUUID,
+		SellerID:   sellerUUID,
+		Rating:     req.Rating,
+		Content:    strings.TrimSpace(req.Content),
+	})
+	if err != nil {
+		log.Error("Failed to save review", "error", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save review"})
+		return
+	}
+
+	user, err := db.Queries.GetUserById(ctx, userUUID)
+	if err != nil {
+		log.Error("Failed to get user", "error", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user info"})
+		return
+	}
+
+	productID := productUUID.String()
+
+	ctx.JSON(http.StatusCreated, ReviewResponse{
+		ID:          review.ID.String(),
+		ProductID:   &productID,
+		ProductName: &product.Name,
+		Rating:      review.Rating,
+		Content:     review.Content,
+		CreatedAt:   formatTimestamp(review.CreatedAt),
+		UpdatedAt:   formatTimestamp(review.UpdatedAt),
+		Reviewer: Reviewer{
+			ID:    user.ID.String(),
+			Name:  user.Name,
+			Image: user.Image.String,
+		},
+	})
+}
@@ -67,9 +67,23 @@ func GeneratePresignedURL(ctx context.Context, filename string, folder string) (
 		return "", "", err
 	}
 
-	publicURL := fmt.Sprintf("https://%s.%s/%s", bucketName, endpointHost, key)
+	return presignedReq.URL, publicURLPrefix() + key, nil
+}
+
+// publicURLPrefix is where uploaded objects are served from
+func publicURLPrefix() string {
+	return fmt.Sprintf("https://%s.%s/", bucketName, endpointHost)
+}
+
+// IsUploadedURL reports whether url points to an object in our bucket, as
+// returned by the presign endpoint
+func IsUploadedURL(url string) bool {
+	if bucketName == "" {
+		return false
+	}
 
-	return presignedReq.URL, publicURL, nil
+	key, found := strings.CutPrefix(url, publicURLPrefix())
+	return found && key != "" && !strings.ContainsAny(key, "?#") && !strings.Contains(key, "..")
 }
 
 func StorageController(router *gin.Engine) {
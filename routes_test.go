@@ -6,7 +6,9 @@ import (
 	"restorapp/modules/categories"
 	"restorapp/modules/comments"
 	"restorapp/modules/locations"
+	"restorapp/modules/messages"
 	"restorapp/modules/products"
+	"restorapp/modules/reviews"
 
 	"github.com/gin-gonic/gin"
 )
@@ -17,6 +19,8 @@ func setupRouter() *gin.Engine {
 	products.ProductsController(router)
 	categories.CategoriesController(router)
 	comments.CommentsController(router)
+	messages.MessagesController(router)
+	reviews.ReviewsController(router)
 	locations.LocationsController(router)
 	return router
 }
@@ -36,12 +40,28 @@ func TestProductRoutes(t *testing.T) {
 
 	expected := []routeEntry{
 		{"GET", "/products"},
+		{"GET", "/products/suggestions"},
 		{"GET", "/products/:id"},
 		{"POST", "/products/"},
 		{"GET", "/products/me"},
 		{"DELETE", "/products/me/:id"},
 		{"PUT", "/products/me/:id"},
+		{"GET", "/products/me/:id/history"},
+		{"POST", "/products/me/:id/reserve"},
+		{"POST", "/products/me/:id/mark-sold"},
+		{"POST", "/products/me/:id/relist"},
+		{"GET", "/products/me/:id/images"},
+		{"POST", "/products/me/:id/images"},
+		{"PUT", "/products/me/:id/images"},
+		{"DELETE", "/products/me/:id/images/:imageId"},
 		{"POST", "/products/publish"},
+		{"POST", "/products/me/import"},
+		{"GET", "/products/me/import/:importId"},
+		{"POST", "/products/:id/favorite"},
+		{"DELETE", "/products/:id/favorite"},
+		{"GET", "/me/favorites"},
+		{"GET", "/users/:id/profile"},
+		{"GET", "/users/:id/products"},
 	}
 
 	for _, e := range expected {
@@ -101,6 +121,7 @@ func TestCommentRoutes(t *testing.T) {
 
 	expected := []routeEntry{
 		{"GET", "/products/:id/comments"},
+		{"GET", "/products/:id/comments/stream"},
 		{"POST", "/products/:id/comments/"},
 		{"DELETE", "/comments/:id"},
 		{"PUT", "/comments/:id/vote"},
@@ -119,6 +140,49 @@ func TestCommentRoutes(t *testing.T) {
 	}
 }
 
+func TestMessageRoutes(t *testing.T) {
+	router := setupRouter()
+	routes := router.Routes()
+	routeSet := make(map[routeEntry]bool)
+	for _, r := range routes {
+		routeSet[routeEntry{r.Method, r.Path}] = true
+	}
+
+	expected := []routeEntry{
+		{"POST", "/products/:id/conversations/"},
+		{"GET", "/conversations"},
+		{"GET", "/conversations/:id/messages"},
+		{"POST", "/conversations/:id/messages"},
+		{"POST", "/conversations/:id/read"},
+	}
+
+	for _, e := range expected {
+		if !routeSet[e] {
+			t.Errorf("expected route %s %s not found", e.method, e.path)
+		}
+	}
+}
+
+func TestReviewRoutes(t *testing.T) {
+	router := setupRouter()
+	routes := router.Routes()
+	routeSet := make(map[routeEntry]bool)
+	for _, r := range routes {
+		routeSet[routeEntry{r.Method, r.Path}] = true
+	}
+
+	expected := []routeEntry{
+		{"GET", "/users/:id/reviews"},
+		{"POST", "/products/:id/reviews/"},
+	}
+
+	for _, e := range expected {
+		if !routeSet[e] {
+			t.Errorf("expected route %s %s not found", e.method, e.path)
+		}
+	}
+}
+
 func TestLocationRoutes(t *testing.T) {
 	router := setupRouter()
 	routes := router.Routes()